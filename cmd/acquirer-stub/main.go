@@ -0,0 +1,102 @@
+// Command acquirer-stub serves the HTTPAcquirer contract backed by the simulator,
+// so the HTTP adapter can be exercised locally without a real acquirer.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/infrastructure/acquirer"
+)
+
+func main() {
+	simulator := acquirer.NewSimulator(acquirer.SimulatorConfig{})
+
+	router := chi.NewRouter()
+	router.Use(middleware.Logger)
+
+	router.Post("/authorizations", func(w http.ResponseWriter, r *http.Request) {
+		var input acquirer.AuthorizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		amount, err := entity.NewMoney(input.AmountMinor, input.Currency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := simulator.Authorize(r.Context(), processor.AuthorizeRequest{
+			PaymentID:   input.PaymentID,
+			OrderID:     input.OrderID,
+			Amount:      amount,
+			Method:      input.Method,
+			SourceToken: input.SourceToken,
+			Capture:     input.Capture,
+		})
+		respond(w, result, err)
+	})
+
+	router.Post("/authorizations/{reference}/{operation}", func(w http.ResponseWriter, r *http.Request) {
+		var input acquirer.OperationRequest
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reference := chi.URLParam(r, "reference")
+		amount := entity.Money{Amount: input.AmountMinor, Currency: input.Currency}
+
+		var result *processor.Result
+		var err error
+		ctx := r.Context()
+		switch chi.URLParam(r, "operation") {
+		case "capture":
+			result, err = simulator.Capture(ctx, processor.CaptureRequest{PaymentID: input.PaymentID, Reference: reference, Amount: amount})
+		case "refunds":
+			result, err = simulator.Refund(ctx, processor.RefundRequest{PaymentID: input.PaymentID, RefundID: input.RefundID, Reference: reference, Amount: amount})
+		case "void":
+			result, err = simulator.Void(ctx, processor.VoidRequest{PaymentID: input.PaymentID, Reference: reference})
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		respond(w, result, err)
+	})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8090"
+	}
+
+	log.Printf("Acquirer stub listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
+
+func respond(w http.ResponseWriter, result *processor.Result, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Approved {
+		status = http.StatusPaymentRequired
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(acquirer.OperationResponse{
+		Approved:      result.Approved,
+		Reference:     result.Reference,
+		DeclineCode:   result.DeclineCode,
+		DeclineReason: result.DeclineReason,
+	})
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway-payments/internal/infrastructure/config"
+	"gateway-payments/internal/infrastructure/database/migrations"
+	mysqlRepo "gateway-payments/internal/infrastructure/database/mysql"
+	"gateway-payments/internal/infrastructure/schema"
+	"gateway-payments/internal/interface/dto"
+	"gateway-payments/internal/usecase"
+)
+
+const usage = `Usage:
+  app                                  start the API
+  app dlq list [-status S] [-page N] [-limit N]
+  app dlq show ID
+  app dlq replay [-actor NAME] (-all | ID...)
+  app dlq purge [-actor NAME] (-all | ID...)
+  app migrate up                       apply every pending migration
+  app migrate down [-steps N]          roll back the last N migrations (default 1)
+  app migrate status                   list migrations and whether they were applied
+  app migrate to VERSION               apply or roll back migrations until VERSION (0 drops everything)
+  app schemas                          list the JSON schemas of events and request bodies
+  app schemas NAME...                  print schemas
+  app schemas -out DIR                 write every schema to DIR/NAME.json
+`
+
+// runCommand runs an administrative subcommand and returns the process exit code.
+func runCommand(cfg *config.Config, db *sql.DB, args []string) int {
+	switch args[0] {
+	case "dlq":
+		return runDLQCommand(cfg, db, args[1:])
+	case "migrate":
+		return runMigrateCommand(cfg, db, args[1:])
+	case "schemas":
+		return runSchemasCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+func runDLQCommand(cfg *config.Config, db *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	deadLetterRepo := mysqlRepo.NewDeadLetterRepository(db)
+	auditRepo := mysqlRepo.NewAuditRepository(db)
+
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	status := flags.String("status", "", "only list messages with this status (PENDING or REPLAYED)")
+	page := flags.Int("page", 1, "page to list")
+	limit := flags.Int("limit", 20, "messages per page")
+	all := flags.Bool("all", false, "act on every message")
+	actor := flags.String("actor", defaultActor(), "operator recorded in the audit log")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var result interface{}
+	var err error
+
+	switch args[0] {
+	case "list":
+		deadLetters, listErr := usecase.NewGetDeadLettersUseCase(deadLetterRepo).Execute(usecase.GetDeadLettersInput{
+			Status: strings.ToUpper(*status),
+			Page:   *page,
+			Limit:  *limit,
+		})
+		responses := make([]*dto.DeadLetterResponse, len(deadLetters))
+		for i, deadLetter := range deadLetters {
+			responses[i] = dto.CreateDeadLetterResponse(deadLetter)
+		}
+		result, err = responses, listErr
+
+	case "show":
+		if flags.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		deadLetter, showErr := usecase.NewGetDeadLetterUseCase(deadLetterRepo).Execute(flags.Arg(0))
+		if showErr == nil {
+			result = dto.CreateDeadLetterResponse(deadLetter)
+		}
+		err = showErr
+
+	case "replay":
+		messageBroker, brokerErr := newBroker(cfg)
+		if brokerErr != nil {
+			fmt.Fprintf(os.Stderr, "error connecting to the message broker: %v\n", brokerErr)
+			return 1
+		}
+		defer messageBroker.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		output, replayErr := usecase.NewReplayDeadLettersUseCase(deadLetterRepo, auditRepo, messageBroker).Execute(ctx, usecase.DeadLetterActionInput{
+			IDs:   flags.Args(),
+			All:   *all,
+			Actor: *actor,
+		})
+		result, err = deadLetterActionResponse(output), replayErr
+
+	case "purge":
+		output, purgeErr := usecase.NewPurgeDeadLettersUseCase(deadLetterRepo, auditRepo).Execute(usecase.DeadLetterActionInput{
+			IDs:   flags.Args(),
+			All:   *all,
+			Actor: *actor,
+		})
+		result, err = deadLetterActionResponse(output), purgeErr
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown dlq command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+
+	if response, ok := result.(*dto.DeadLetterActionResponse); ok && len(response.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runMigrateCommand(cfg *config.Config, db *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "migrations to roll back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	migrator, err := migrations.NewMigrator(db, cfg.MigrationLockTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.MigrationLockTimeout+30*time.Minute)
+	defer cancel()
+
+	var changes []migrations.Change
+	switch args[0] {
+	case "up":
+		changes, err = migrator.Up(ctx)
+
+	case "down":
+		changes, err = migrator.Down(ctx, *steps)
+
+	case "to":
+		if flags.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		version, parseErr := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", flags.Arg(0))
+			return 2
+		}
+		changes, err = migrator.To(ctx, version)
+
+	case "status":
+		statuses, statusErr := migrator.Status(ctx)
+		if statusErr != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", statusErr)
+			return 1
+		}
+		for _, status := range statuses {
+			name, applied := status.Name, "pending"
+			if status.Unknown {
+				name = "(unknown to this binary)"
+			}
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-32s %s\n", status.Version, name, applied)
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return 0
+}
+
+// runSchemasCommand prints the embedded JSON schemas, so producers can validate their
+// events and requests with the same rules as the gateway.
+func runSchemasCommand(args []string) int {
+	flags := flag.NewFlagSet("schemas", flag.ContinueOnError)
+	out := flags.String("out", "", "directory to write every schema to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	registry, err := schema.NewRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		for _, name := range registry.Names() {
+			raw, _ := registry.Raw(name)
+			if err := os.WriteFile(filepath.Join(*out, name+".json"), raw, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+			fmt.Println(filepath.Join(*out, name+".json"))
+		}
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		for _, name := range registry.Names() {
+			fmt.Printf("%-28s %s\n", name, registry.Title(name))
+		}
+		return 0
+	}
+
+	for _, name := range flags.Args() {
+		raw, ok := registry.Raw(name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown schema %q\n", name)
+			return 1
+		}
+		os.Stdout.Write(raw)
+	}
+	return 0
+}
+
+func deadLetterActionResponse(output *usecase.DeadLetterActionOutput) *dto.DeadLetterActionResponse {
+	if output == nil {
+		return nil
+	}
+	response := &dto.DeadLetterActionResponse{
+		Succeeded: output.Succeeded,
+		Failed:    make([]dto.DeadLetterActionFailure, len(output.Failed)),
+	}
+	for i, failure := range output.Failed {
+		response.Failed[i] = dto.DeadLetterActionFailure{ID: failure.ID, Error: failure.Error}
+	}
+	return response
+}
+
+// defaultActor names the operator running the CLI for the audit log.
+func defaultActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "cli:" + user
+	}
+	return "cli"
+}
@@ -0,0 +1,159 @@
+// Package apperr is the catalog of failures the gateway reports to its clients. Each
+// failure has a stable, machine-readable code; the HTTP handlers map codes to statuses
+// and problem documents, and the consumers map them to ack, retry or dead-letter.
+package apperr
+
+import (
+	"errors"
+	"sort"
+)
+
+type Code string
+
+const (
+	// CodeMalformedRequest is a body or message that cannot be read at all, such as invalid JSON
+	CodeMalformedRequest Code = "malformed_request"
+	// CodeValidationFailed is a document that breaks its JSON schema
+	CodeValidationFailed Code = "validation_failed"
+	CodeInvalidParameter Code = "invalid_parameter"
+	CodeInvalidAmount    Code = "invalid_amount"
+	// CodeUnsupportedCurrency is a currency outside ISO-4217 or one the gateway does not handle
+	CodeUnsupportedCurrency Code = "unsupported_currency"
+	CodeUnknownStatus       Code = "unknown_status"
+	// CodeUnsupportedEvent is an event of another type or of a newer version than the consumer understands
+	CodeUnsupportedEvent Code = "unsupported_event"
+
+	CodeNotFound Code = "not_found"
+	// CodeConflict is a write that collides with a record that already exists
+	CodeConflict          Code = "conflict"
+	CodeInvalidTransition Code = "invalid_transition"
+	// CodeAttemptNotAllowed is a new payment for an order whose last attempt is open or succeeded
+	CodeAttemptNotAllowed Code = "attempt_not_allowed"
+	// CodeDeletionNotAllowed is a deletion of a payment that must be kept, because it
+	// moved money or is under legal hold
+	CodeDeletionNotAllowed Code = "deletion_not_allowed"
+	// CodeConcurrentModification is an update of a record that changed since it was read
+	CodeConcurrentModification Code = "concurrent_modification"
+	// CodeInsufficientFunds is an amount above what is available: the authorization on
+	// a capture, the captured amount on a refund, or the customer's balance on a decline
+	CodeInsufficientFunds    Code = "insufficient_funds"
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	// CodeInProgress is a request or message that is being handled by someone else
+	CodeInProgress Code = "in_progress"
+	// CodeAlreadyProcessed is a message that was already handled successfully
+	CodeAlreadyProcessed Code = "already_processed"
+
+	CodeProcessorDeclined    Code = "processor_declined"
+	CodeProcessorUnavailable Code = "processor_unavailable"
+	// CodeUnreconciledCharge is money moved at the processor that could neither be
+	// recorded nor reversed; an operator has to reconcile it
+	CodeUnreconciledCharge Code = "unreconciled_charge"
+
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+
+	// CodeInternal is any failure outside the catalog
+	CodeInternal Code = "internal_error"
+)
+
+// Entry describes a code. Retryable failures may succeed when the same request or
+// message is sent again later, unchanged.
+type Entry struct {
+	Code      Code
+	Title     string
+	Retryable bool
+}
+
+var catalog = map[Code]Entry{
+	CodeMalformedRequest:       {Title: "Malformed request"},
+	CodeValidationFailed:       {Title: "Validation failed"},
+	CodeInvalidParameter:       {Title: "Invalid parameter"},
+	CodeInvalidAmount:          {Title: "Invalid amount"},
+	CodeUnsupportedCurrency:    {Title: "Unsupported currency"},
+	CodeUnknownStatus:          {Title: "Unknown payment status"},
+	CodeUnsupportedEvent:       {Title: "Unsupported event"},
+	CodeNotFound:               {Title: "Not found"},
+	CodeConflict:               {Title: "Conflict"},
+	CodeInvalidTransition:      {Title: "Invalid status transition"},
+	CodeAttemptNotAllowed:      {Title: "New payment attempt not allowed"},
+	CodeDeletionNotAllowed:     {Title: "Deletion not allowed"},
+	CodeConcurrentModification: {Title: "Concurrent modification", Retryable: true},
+	CodeInsufficientFunds:      {Title: "Insufficient funds"},
+	CodeIdempotencyKeyReused:   {Title: "Idempotency key reused"},
+	CodeInProgress:             {Title: "Already in progress", Retryable: true},
+	CodeAlreadyProcessed:       {Title: "Already processed"},
+	CodeProcessorDeclined:      {Title: "Declined by the payment processor"},
+	CodeProcessorUnavailable:   {Title: "Payment processor unavailable", Retryable: true},
+	CodeUnreconciledCharge:     {Title: "Charge not reconciled"},
+	CodeUnauthorized:           {Title: "Unauthorized"},
+	CodeForbidden:              {Title: "Forbidden"},
+	CodeInternal:               {Title: "Internal error", Retryable: true},
+}
+
+// Lookup returns the entry of code; unknown codes are reported as CodeInternal.
+func Lookup(code Code) Entry {
+	entry, ok := catalog[code]
+	if !ok {
+		code = CodeInternal
+		entry = catalog[code]
+	}
+	entry.Code = code
+	return entry
+}
+
+// Entries lists the catalog by code, for documentation.
+func Entries() []Entry {
+	entries := make([]Entry, 0, len(catalog))
+	for code := range catalog {
+		entries = append(entries, Lookup(code))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
+
+// Coder is implemented by the errors that belong to the catalog.
+type Coder interface {
+	ErrorCode() Code
+}
+
+// CodeOf returns the code of the first error in the chain of err that has one, or
+// CodeInternal.
+func CodeOf(err error) Code {
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return CodeInternal
+}
+
+// Error is a failure with a code and a message for the client, for errors that have
+// no type of their own. It wraps the error that caused it, if any.
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New returns an error with code, usable as a sentinel with errors.Is.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap gives err a code, keeping its message.
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records an administrative action: who did what to which resource.
+type AuditEntry struct {
+	ID         string
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Details    string
+	CreatedAt  time.Time
+}
+
+func NewAuditEntry(actor, action, targetType, targetID, details string) *AuditEntry {
+	return &AuditEntry{
+		ID:         uuid.NewString(),
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}
+}
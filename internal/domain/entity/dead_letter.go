@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+const (
+	DeadLetterStatusPending  = "PENDING"
+	DeadLetterStatusReplayed = "REPLAYED"
+)
+
+// DeadLetter is a message read from the dead-letter queue and kept until an operator
+// replays or purges it. Exchange and RoutingKey are where it was originally published.
+type DeadLetter struct {
+	ID            string
+	MessageID     string
+	Exchange      string
+	RoutingKey    string
+	ContentType   string
+	Headers       map[string]interface{}
+	Body          []byte
+	FailureKind   string
+	FailureReason string
+	Attempts      int
+	Status        string
+	ReplayCount   int
+	ReceivedAt    time.Time
+	ReplayedAt    *time.Time
+}
+
+// MarkReplayed records that the message was published again to its original routing key.
+func (d *DeadLetter) MarkReplayed(at time.Time) {
+	d.Status = DeadLetterStatusReplayed
+	d.ReplayCount++
+	d.ReplayedAt = &at
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// IdempotencyKey stores the fingerprint of the first request sent with a given
+// Idempotency-Key header and the response produced for it, so retries can be replayed.
+type IdempotencyKey struct {
+	Key          string
+	RequestHash  string
+	StatusCode   int // 0 while the original request is still being processed
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+func NewIdempotencyKey(key string, requestHash string) *IdempotencyKey {
+	return &IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+// Stale reports whether the original request has held the key for longer than lease
+// without completing, which happens when the process handling it crashed.
+func (k *IdempotencyKey) Stale(now time.Time, lease time.Duration) bool {
+	return !k.Completed() && !now.Before(k.CreatedAt.Add(lease))
+}
@@ -0,0 +1,195 @@
+package entity
+
+import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed when a producer does not inform the currency.
+const DefaultCurrency = "BRL"
+
+// currencyExponents maps ISO-4217 codes to the number of digits after the decimal separator.
+var currencyExponents = map[string]int{
+	"ARS": 2,
+	"AUD": 2,
+	"BHD": 3,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"CNY": 2,
+	"COP": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"IQD": 3,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"LYD": 3,
+	"MXN": 2,
+	"OMR": 3,
+	"PEN": 2,
+	"PYG": 0,
+	"TND": 3,
+	"USD": 2,
+	"UYU": 2,
+	"VND": 0,
+}
+
+// ErrCurrencyMismatch is returned by arithmetic between amounts of different currencies.
+var ErrCurrencyMismatch = apperr.New(apperr.CodeInvalidAmount, "cannot combine amounts in different currencies")
+
+type ErrUnsupportedCurrency struct {
+	Currency string
+}
+
+func (e *ErrUnsupportedCurrency) ErrorCode() apperr.Code {
+	return apperr.CodeUnsupportedCurrency
+}
+
+func (e *ErrUnsupportedCurrency) Error() string {
+	return fmt.Sprintf("unsupported currency %q", e.Currency)
+}
+
+type ErrInvalidAmount struct {
+	Amount string
+	Reason string
+}
+
+func (e *ErrInvalidAmount) ErrorCode() apperr.Code {
+	return apperr.CodeInvalidAmount
+}
+
+func (e *ErrInvalidAmount) Error() string {
+	return fmt.Sprintf("invalid amount %q: %s", e.Amount, e.Reason)
+}
+
+// Money is an amount in the minor unit of its currency (cents for BRL, yen for JPY, fils for KWD).
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := currencyExponents[currency]; !ok {
+		return Money{}, &ErrUnsupportedCurrency{Currency: currency}
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseMoney converts a decimal string such as "100.50" into minor units, refusing
+// values with more fractional digits than the currency allows.
+func ParseMoney(amount string, currency string) (Money, error) {
+	money, err := NewMoney(0, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exponent := money.Exponent()
+
+	value := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+
+	integerPart, fractionPart, _ := strings.Cut(value, ".")
+	if integerPart == "" || !isDigits(integerPart) || !isDigits(fractionPart) {
+		return Money{}, &ErrInvalidAmount{Amount: amount, Reason: "not a decimal number"}
+	}
+	if len(fractionPart) > exponent {
+		return Money{}, &ErrInvalidAmount{Amount: amount, Reason: fmt.Sprintf("%s allows at most %d decimal places", money.Currency, exponent)}
+	}
+	fractionPart += strings.Repeat("0", exponent-len(fractionPart))
+
+	minor, err := strconv.ParseInt(integerPart+fractionPart, 10, 64)
+	if err != nil {
+		return Money{}, &ErrInvalidAmount{Amount: amount, Reason: "out of range"}
+	}
+	if negative {
+		minor = -minor
+	}
+
+	money.Amount = minor
+	return money, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Exponent is the number of minor unit digits of the currency.
+func (m Money) Exponent() int {
+	return currencyExponents[m.Currency]
+}
+
+// Decimal formats the amount in major units, e.g. "100.50" for 10050 BRL.
+func (m Money) Decimal() string {
+	exponent := m.Exponent()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, &ErrInvalidAmount{Amount: m.Decimal(), Reason: "out of range"}
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, &ErrInvalidAmount{Amount: other.Decimal(), Reason: "out of range"}
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
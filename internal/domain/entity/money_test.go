@@ -0,0 +1,152 @@
+package entity
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{amount: "100.50", currency: "BRL", want: Money{Amount: 10050, Currency: "BRL"}},
+		{amount: "100", currency: "BRL", want: Money{Amount: 10000, Currency: "BRL"}},
+		{amount: "100.5", currency: "BRL", want: Money{Amount: 10050, Currency: "BRL"}},
+		{amount: "0.01", currency: "BRL", want: Money{Amount: 1, Currency: "BRL"}},
+		{amount: "  7.00 ", currency: " usd ", want: Money{Amount: 700, Currency: "USD"}},
+		{amount: "+3", currency: "BRL", want: Money{Amount: 300, Currency: "BRL"}},
+		{amount: "-3.25", currency: "BRL", want: Money{Amount: -325, Currency: "BRL"}},
+		{amount: "1500", currency: "JPY", want: Money{Amount: 1500, Currency: "JPY"}},
+		{amount: "1.234", currency: "KWD", want: Money{Amount: 1234, Currency: "KWD"}},
+		{amount: "10.", currency: "BRL", want: Money{Amount: 1000, Currency: "BRL"}},
+
+		{amount: "100.505", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "1500.5", currency: "JPY", wantErr: &ErrInvalidAmount{}},
+		{amount: "", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: ".50", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "1,50", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "1e3", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "--1", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "92233720368547758.08", currency: "BRL", wantErr: &ErrInvalidAmount{}},
+		{amount: "10.00", currency: "XYZ", wantErr: &ErrUnsupportedCurrency{}},
+		{amount: "10.00", currency: "", wantErr: &ErrUnsupportedCurrency{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := ParseMoney(tt.amount, tt.currency)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("ParseMoney(%q, %q) = %v", tt.amount, tt.currency, err)
+				}
+				if got != tt.want {
+					t.Errorf("ParseMoney(%q, %q) = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+				}
+			case *ErrInvalidAmount:
+				if !errors.As(err, &want) {
+					t.Errorf("ParseMoney(%q, %q) = %v, want *ErrInvalidAmount", tt.amount, tt.currency, err)
+				}
+			case *ErrUnsupportedCurrency:
+				if !errors.As(err, &want) {
+					t.Errorf("ParseMoney(%q, %q) = %v, want *ErrUnsupportedCurrency", tt.amount, tt.currency, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: Money{Amount: 10050, Currency: "BRL"}, want: "100.50"},
+		{money: Money{Amount: 5, Currency: "BRL"}, want: "0.05"},
+		{money: Money{Amount: 0, Currency: "BRL"}, want: "0.00"},
+		{money: Money{Amount: -325, Currency: "BRL"}, want: "-3.25"},
+		{money: Money{Amount: 1500, Currency: "JPY"}, want: "1500"},
+		{money: Money{Amount: 1234, Currency: "KWD"}, want: "1.234"},
+		{money: Money{Amount: 7, Currency: "KWD"}, want: "0.007"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+
+		// Formatting and parsing back must give the same amount
+		parsed, err := ParseMoney(tt.money.Decimal(), tt.money.Currency)
+		if err != nil || parsed != tt.money {
+			t.Errorf("ParseMoney(%q) = %+v, %v, want %+v", tt.money.Decimal(), parsed, err, tt.money)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	usd := Money{Amount: 100, Currency: "USD"}
+
+	tests := []struct {
+		name    string
+		op      func() (Money, error)
+		want    Money
+		wantErr bool
+	}{
+		{name: "add", op: func() (Money, error) { return brl(150).Add(brl(50)) }, want: brl(200)},
+		{name: "add negative", op: func() (Money, error) { return brl(150).Add(brl(-200)) }, want: brl(-50)},
+		{name: "sub", op: func() (Money, error) { return brl(150).Sub(brl(50)) }, want: brl(100)},
+		{name: "sub below zero", op: func() (Money, error) { return brl(50).Sub(brl(150)) }, want: brl(-100)},
+		{name: "add currency mismatch", op: func() (Money, error) { return brl(150).Add(usd) }, wantErr: true},
+		{name: "sub currency mismatch", op: func() (Money, error) { return brl(150).Sub(usd) }, wantErr: true},
+		{name: "add overflow", op: func() (Money, error) { return brl(math.MaxInt64).Add(brl(1)) }, wantErr: true},
+		{name: "add underflow", op: func() (Money, error) { return brl(math.MinInt64).Add(brl(-1)) }, wantErr: true},
+		{name: "sub overflow", op: func() (Money, error) { return brl(math.MaxInt64).Sub(brl(-1)) }, wantErr: true},
+		{name: "sub min int", op: func() (Money, error) { return brl(0).Sub(brl(math.MinInt64)) }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyCmp(t *testing.T) {
+	tests := []struct {
+		a, b Money
+		want int
+	}{
+		{a: brl(100), b: brl(200), want: -1},
+		{a: brl(200), b: brl(200), want: 0},
+		{a: brl(300), b: brl(200), want: 1},
+		{a: brl(-1), b: brl(0), want: -1},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.a.Cmp(tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("%s.Cmp(%s) = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	if _, err := brl(100).Cmp(Money{Amount: 100, Currency: "USD"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp across currencies = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is an event recorded in the same transaction as the change that
+// produced it and published to the broker afterwards by the outbox relay.
+type OutboxMessage struct {
+	ID          string
+	AggregateID string
+	Exchange    string
+	RoutingKey  string
+	Payload     []byte
+	Attempts    int
+	LastError   string
+	AvailableAt time.Time
+	CreatedAt   time.Time
+	SentAt      *time.Time
+}
+
+func NewOutboxMessage(aggregateID string, exchange string, routingKey string, payload interface{}) (*OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &OutboxMessage{
+		ID:          uuid.NewString(),
+		AggregateID: aggregateID,
+		Exchange:    exchange,
+		RoutingKey:  routingKey,
+		Payload:     body,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}, nil
+}
+
+// OutboxStats summarizes the messages still waiting to be published.
+type OutboxStats struct {
+	Pending       int
+	OldestPending *time.Time
+}
@@ -1,42 +1,465 @@
 package entity
 
-import "time"
+import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"strings"
+	"time"
+)
 
 const (
-	StatusPending  = "PENDING"
-	StatusRejected = "REJECTED"
-	StatusApproved = "APPROVED"
+	StatusPending    = "PENDING"
+	StatusAuthorized = "AUTHORIZED"
+	StatusApproved   = "APPROVED"
+	StatusCaptured   = "CAPTURED"
+	StatusVoided     = "VOIDED"
+	StatusRejected   = "REJECTED"
+	StatusRefunded   = "REFUNDED"
+	StatusExpired    = "EXPIRED"
+
+	StatusPartiallyRefunded = "PARTIALLY_REFUNDED"
 )
 
-type Payment struct {
-	ID        string
+// transitions lists, for each status, the statuses a payment may move to.
+// APPROVED is a one-step authorization and capture; AUTHORIZED/CAPTURED is the two-step flow.
+var transitions = map[string][]string{
+	StatusPending:    {StatusAuthorized, StatusApproved, StatusRejected},
+	StatusAuthorized: {StatusCaptured, StatusVoided, StatusExpired},
+	StatusApproved:   {StatusPartiallyRefunded, StatusRefunded},
+	StatusCaptured:   {StatusPartiallyRefunded, StatusRefunded},
+
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+}
+
+// ErrInvalidTransition is returned when a payment cannot move from its current status to the requested one.
+type ErrInvalidTransition struct {
+	PaymentID string
+	From      string
+	To        string
+	Reason    string
+}
+
+func (e *ErrInvalidTransition) ErrorCode() apperr.Code {
+	return apperr.CodeInvalidTransition
+}
+
+func (e *ErrInvalidTransition) Error() string {
+	message := fmt.Sprintf("payment %s cannot move from %s to %s", e.PaymentID, e.From, e.To)
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	return message
+}
+
+// ErrCaptureExceedsAuthorized is returned when a capture asks for more than was authorized.
+type ErrCaptureExceedsAuthorized struct {
+	PaymentID  string
+	Requested  Money
+	Authorized Money
+}
+
+func (e *ErrCaptureExceedsAuthorized) ErrorCode() apperr.Code {
+	return apperr.CodeInsufficientFunds
+}
+
+func (e *ErrCaptureExceedsAuthorized) Error() string {
+	return fmt.Sprintf("capture of %s exceeds the %s authorized for payment %s", e.Requested, e.Authorized, e.PaymentID)
+}
+
+// ErrUnknownStatus is returned when a status is not part of the payment lifecycle.
+type ErrUnknownStatus struct {
+	Status string
+}
+
+func (e *ErrUnknownStatus) ErrorCode() apperr.Code {
+	return apperr.CodeUnknownStatus
+}
+
+func (e *ErrUnknownStatus) Error() string {
+	return fmt.Sprintf("unknown payment status %q", e.Status)
+}
+
+// ParseStatus normalizes a status name, ignoring case, and refuses names outside the lifecycle.
+func ParseStatus(status string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(status))
+	switch normalized {
+	case StatusPending, StatusAuthorized, StatusApproved, StatusCaptured, StatusVoided,
+		StatusRejected, StatusRefunded, StatusPartiallyRefunded, StatusExpired:
+		return normalized, nil
+	}
+	return "", &ErrUnknownStatus{Status: status}
+}
+
+// ErrAttemptNotAllowed is returned when a new attempt is started for an order whose
+// last attempt is still open or succeeded.
+type ErrAttemptNotAllowed struct {
 	OrderID   string
-	Amount    float64 // Melhor usar float64 ou int (centavos) para cálculos
-	Method    string
+	PaymentID string
 	Status    string
-	CreatedAt time.Time
 }
 
-func NewPayment(id string, orderID string, amount float64, method string) *Payment {
+func (e *ErrAttemptNotAllowed) ErrorCode() apperr.Code {
+	return apperr.CodeAttemptNotAllowed
+}
+
+func (e *ErrAttemptNotAllowed) Error() string {
+	return fmt.Sprintf("order %s cannot be paid again: payment %s is %s", e.OrderID, e.PaymentID, e.Status)
+}
+
+// retainedStatuses are the statuses of payments that are part of the books: money
+// moved, or is still held by an open authorization. They are never deleted.
+var retainedStatuses = []string{StatusAuthorized, StatusApproved, StatusCaptured, StatusPartiallyRefunded, StatusRefunded}
+
+// RetainedStatuses lists the statuses of payments that cannot be deleted.
+func RetainedStatuses() []string {
+	return append([]string(nil), retainedStatuses...)
+}
+
+// ErrDeletionNotAllowed is returned when a payment that must be kept is deleted.
+type ErrDeletionNotAllowed struct {
+	PaymentID string
+	Status    string
+	LegalHold bool
+}
+
+func (e *ErrDeletionNotAllowed) ErrorCode() apperr.Code {
+	return apperr.CodeDeletionNotAllowed
+}
+
+func (e *ErrDeletionNotAllowed) Error() string {
+	if e.LegalHold {
+		return fmt.Sprintf("payment %s cannot be deleted: it is under legal hold", e.PaymentID)
+	}
+	return fmt.Sprintf("payment %s cannot be deleted: it is %s", e.PaymentID, e.Status)
+}
+
+// Payment is one attempt to pay an order. Attempts are numbered from 1, and a new
+// one may only start once the previous attempt failed (see AllowsNewAttempt).
+type Payment struct {
+	ID           string
+	OrderID      string
+	Attempt      int
+	Amount       Money
+	Captured     Money
+	Refunded     Money
+	Method       string
+	Status       string
+	StatusReason string
+	// Processor is the name of the processor that authorized the payment and
+	// ProcessorReference its identifier there; both are empty for manual approvals
+	Processor          string
+	ProcessorReference string
+	AuthorizedAt       *time.Time
+	// ProcessingUntil is set while a worker waits for the processor to decide a PENDING
+	// payment; other requests for the order leave it alone until then
+	ProcessingUntil *time.Time
+	// ExpiryAttempts counts the failed attempts to release an expired authorization;
+	// the next one waits until NextExpiryAttemptAt
+	ExpiryAttempts      int
+	NextExpiryAttemptAt *time.Time
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	// Version is incremented on every update and used to detect concurrent changes
+	Version int64
+	// LegalHold keeps the payment from being deleted or purged
+	LegalHold bool
+	// DeletedAt is set when the payment is soft-deleted by DeletedBy. Deleted payments
+	// are hidden from the API and purged once the retention period is over
+	DeletedAt *time.Time
+	DeletedBy string
+}
+
+func NewPayment(id string, orderID string, amount Money, method string) *Payment {
 	location := time.FixedZone("America/Sao_Paulo", -3*60*60)
 	return &Payment{
 		ID:        id,
 		OrderID:   orderID,
 		Amount:    amount,
+		Captured:  Money{Currency: amount.Currency},
+		Refunded:  Money{Currency: amount.Currency},
 		Method:    method,
+		Attempt:   1,
 		Status:    StatusPending,
 		CreatedAt: time.Now().In(location),
 	}
 }
 
-func UpdatePayment(id string, orderID string, amount float64, method string) *Payment {
+// NewPaymentAttempt starts the attempt that follows previous, the last attempt of the order.
+func NewPaymentAttempt(id string, previous *Payment, amount Money, method string) (*Payment, error) {
+	if !previous.AllowsNewAttempt() {
+		return nil, &ErrAttemptNotAllowed{OrderID: previous.OrderID, PaymentID: previous.ID, Status: previous.Status}
+	}
+	payment := NewPayment(id, previous.OrderID, amount, method)
+	payment.Attempt = previous.Attempt + 1
+	return payment, nil
+}
+
+func UpdatePayment(id string, orderID string, amount Money, method string) *Payment {
 	location := time.FixedZone("America/Sao_Paulo", -3*60*60)
 	return &Payment{
 		ID:        id,
 		OrderID:   orderID,
 		Amount:    amount,
+		Captured:  Money{Currency: amount.Currency},
+		Refunded:  Money{Currency: amount.Currency},
 		Method:    method,
 		Status:    StatusPending,
 		CreatedAt: time.Now().In(location),
 	}
 }
+
+// CanTransitionTo reports whether the payment may move to status.
+func (p *Payment) CanTransitionTo(status string) bool {
+	for _, allowed := range transitions[p.Status] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the payment to status, dispatching to the matching lifecycle method.
+// Status names are case-insensitive; reason is only kept for rejections and voids.
+// Captures through this method always settle the full authorized amount.
+func (p *Payment) TransitionTo(status string, reason string) error {
+	switch strings.ToUpper(status) {
+	case StatusAuthorized:
+		return p.Authorize()
+	case StatusApproved:
+		return p.Approve()
+	case StatusRejected:
+		return p.Reject(reason)
+	case StatusCaptured:
+		return p.Capture(p.Amount)
+	case StatusVoided:
+		return p.Void(reason)
+	case StatusRefunded, StatusPartiallyRefunded:
+		return &ErrInvalidTransition{
+			PaymentID: p.ID,
+			From:      p.Status,
+			To:        strings.ToUpper(status),
+			Reason:    "refunds must be requested through the refunds API",
+		}
+	case StatusExpired:
+		return &ErrInvalidTransition{
+			PaymentID: p.ID,
+			From:      p.Status,
+			To:        StatusExpired,
+			Reason:    "authorizations expire on their own",
+		}
+	case StatusPending:
+		return p.transition(StatusPending)
+	}
+	return &ErrUnknownStatus{Status: status}
+}
+
+// Authorize places a hold on the funds without capturing them.
+func (p *Payment) Authorize() error {
+	if err := p.transition(StatusAuthorized); err != nil {
+		return err
+	}
+	now := time.Now()
+	p.AuthorizedAt = &now
+	return nil
+}
+
+// Approve authorizes and captures the full amount in a single step.
+func (p *Payment) Approve() error {
+	if err := p.transition(StatusApproved); err != nil {
+		return err
+	}
+	p.Captured = p.Amount
+	return nil
+}
+
+func (p *Payment) Reject(reason string) error {
+	if err := p.transition(StatusRejected); err != nil {
+		return err
+	}
+	p.StatusReason = reason
+	return nil
+}
+
+// Capture settles amount of a previously authorized payment. Capturing less than
+// the authorized amount releases the remainder.
+func (p *Payment) Capture(amount Money) error {
+	if !amount.IsPositive() {
+		return &ErrInvalidAmount{Amount: amount.Decimal(), Reason: "must be greater than zero"}
+	}
+	if !p.CanTransitionTo(StatusCaptured) {
+		return &ErrInvalidTransition{PaymentID: p.ID, From: p.Status, To: StatusCaptured}
+	}
+	cmp, err := amount.Cmp(p.Amount)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return &ErrCaptureExceedsAuthorized{PaymentID: p.ID, Requested: amount, Authorized: p.Amount}
+	}
+
+	if err := p.transition(StatusCaptured); err != nil {
+		return err
+	}
+	p.Captured = amount
+	return nil
+}
+
+// Void releases a previously authorized payment.
+func (p *Payment) Void(reason string) error {
+	if err := p.transition(StatusVoided); err != nil {
+		return err
+	}
+	p.StatusReason = reason
+	return nil
+}
+
+// Expire releases an authorization that was not captured in time.
+func (p *Payment) Expire(reason string) error {
+	if err := p.transition(StatusExpired); err != nil {
+		return err
+	}
+	p.StatusReason = reason
+	return nil
+}
+
+// ExpiryFailed records that the authorization could not be released and postpones
+// the next attempt to at.
+func (p *Payment) ExpiryFailed(at time.Time) {
+	p.ExpiryAttempts++
+	p.NextExpiryAttemptAt = &at
+}
+
+// AllowsNewAttempt reports whether the order may be paid again: the attempt failed
+// and will not move anymore, or was deleted. Open and successful attempts keep the order.
+func (p *Payment) AllowsNewAttempt() bool {
+	if p.IsDeleted() {
+		return true
+	}
+	switch p.Status {
+	case StatusRejected, StatusVoided, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// AwaitsProcessor reports whether the payment was sent to its processor and the
+// outcome was not recorded yet, either because the call is running or because it failed.
+func (p *Payment) AwaitsProcessor() bool {
+	return p.Status == StatusPending && p.Processor != "" && !p.IsDeleted()
+}
+
+// Processing reports whether a worker is still waiting for the processor.
+func (p *Payment) Processing(now time.Time) bool {
+	return p.AwaitsProcessor() && p.ProcessingUntil != nil && now.Before(*p.ProcessingUntil)
+}
+
+// AuthorizationExpired reports whether the payment has been held for longer than window.
+func (p *Payment) AuthorizationExpired(window time.Duration, now time.Time) bool {
+	return p.Status == StatusAuthorized && p.AuthorizedAt != nil && now.Sub(*p.AuthorizedAt) > window
+}
+
+// RefundableAmount is how much of the captured amount has not been refunded yet.
+func (p *Payment) RefundableAmount() Money {
+	available, err := p.Captured.Sub(p.Refunded)
+	if err != nil {
+		return Money{Currency: p.Amount.Currency}
+	}
+	return available
+}
+
+// CheckRefund reports whether amount can be refunded while pending is already being
+// refunded and was not applied yet.
+func (p *Payment) CheckRefund(amount Money, pending Money) error {
+	if !amount.IsPositive() {
+		return &ErrInvalidAmount{Amount: amount.Decimal(), Reason: "must be greater than zero"}
+	}
+	if !p.CanTransitionTo(StatusRefunded) {
+		return &ErrInvalidTransition{PaymentID: p.ID, From: p.Status, To: StatusRefunded}
+	}
+
+	available, err := p.RefundableAmount().Sub(pending)
+	if err != nil {
+		return err
+	}
+	cmp, err := amount.Cmp(available)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return &ErrRefundExceedsCaptured{PaymentID: p.ID, Requested: amount, Available: available}
+	}
+	return nil
+}
+
+// ApplyRefund adds amount to the refunded total, moving the payment to
+// PARTIALLY_REFUNDED or, once everything was given back, REFUNDED.
+func (p *Payment) ApplyRefund(amount Money) error {
+	if err := p.CheckRefund(amount, Money{Currency: p.Amount.Currency}); err != nil {
+		return err
+	}
+	cmp, err := amount.Cmp(p.RefundableAmount())
+	if err != nil {
+		return err
+	}
+
+	next := StatusPartiallyRefunded
+	if cmp == 0 {
+		next = StatusRefunded
+	}
+	if err := p.transition(next); err != nil {
+		return err
+	}
+
+	refunded, err := p.Refunded.Add(amount)
+	if err != nil {
+		return err
+	}
+	p.Refunded = refunded
+	return nil
+}
+
+// CanDelete returns *ErrDeletionNotAllowed when the payment must be kept: it is under
+// legal hold or has one of the retained statuses.
+func (p *Payment) CanDelete() error {
+	if p.LegalHold {
+		return &ErrDeletionNotAllowed{PaymentID: p.ID, Status: p.Status, LegalHold: true}
+	}
+	for _, status := range retainedStatuses {
+		if p.Status == status {
+			return &ErrDeletionNotAllowed{PaymentID: p.ID, Status: p.Status}
+		}
+	}
+	return nil
+}
+
+// Delete soft-deletes the payment on behalf of actor.
+func (p *Payment) Delete(actor string, at time.Time) error {
+	if err := p.CanDelete(); err != nil {
+		return err
+	}
+	p.DeletedAt = &at
+	p.DeletedBy = actor
+	return nil
+}
+
+func (p *Payment) IsDeleted() bool {
+	return p.DeletedAt != nil
+}
+
+// IsDecided reports whether the outcome of the payment is known to the rest of the system.
+func (p *Payment) IsDecided() bool {
+	switch p.Status {
+	case StatusApproved, StatusRejected, StatusCaptured:
+		return true
+	}
+	return false
+}
+
+func (p *Payment) transition(to string) error {
+	if !p.CanTransitionTo(to) {
+		return &ErrInvalidTransition{PaymentID: p.ID, From: p.Status, To: to}
+	}
+	p.Status = to
+	p.ProcessingUntil = nil
+	return nil
+}
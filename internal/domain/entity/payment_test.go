@@ -0,0 +1,227 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var allStatuses = []string{
+	StatusPending,
+	StatusAuthorized,
+	StatusApproved,
+	StatusCaptured,
+	StatusVoided,
+	StatusRejected,
+	StatusRefunded,
+	StatusExpired,
+	StatusPartiallyRefunded,
+}
+
+func brl(amount int64) Money {
+	return Money{Amount: amount, Currency: "BRL"}
+}
+
+func newTestPayment(status string) *Payment {
+	payment := NewPayment("payment-1", "order-1", brl(10000), "PIX")
+	payment.Status = status
+	return payment
+}
+
+func TestPaymentCanTransitionTo(t *testing.T) {
+	allowed := map[string][]string{
+		StatusPending:           {StatusAuthorized, StatusApproved, StatusRejected},
+		StatusAuthorized:        {StatusCaptured, StatusVoided, StatusExpired},
+		StatusApproved:          {StatusPartiallyRefunded, StatusRefunded},
+		StatusCaptured:          {StatusPartiallyRefunded, StatusRefunded},
+		StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+		StatusVoided:            nil,
+		StatusRejected:          nil,
+		StatusRefunded:          nil,
+		StatusExpired:           nil,
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := false
+			for _, status := range allowed[from] {
+				if status == to {
+					want = true
+				}
+			}
+
+			payment := newTestPayment(from)
+			if got := payment.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: CanTransitionTo = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestPaymentTransitionTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		want     string
+		wantErr  interface{}
+		captured int64
+	}{
+		{name: "approve captures the amount", from: StatusPending, to: StatusApproved, want: StatusApproved, captured: 10000},
+		{name: "status is case-insensitive", from: StatusPending, to: "authorized", want: StatusAuthorized},
+		{name: "reject", from: StatusPending, to: StatusRejected, want: StatusRejected},
+		{name: "capture settles the authorized amount", from: StatusAuthorized, to: StatusCaptured, want: StatusCaptured, captured: 10000},
+		{name: "void", from: StatusAuthorized, to: StatusVoided, want: StatusVoided},
+		{name: "capture without authorization", from: StatusPending, to: StatusCaptured, want: StatusPending, wantErr: &ErrInvalidTransition{}},
+		{name: "decided payments do not move back", from: StatusApproved, to: StatusPending, want: StatusApproved, wantErr: &ErrInvalidTransition{}},
+		{name: "refunds need the refunds API", from: StatusApproved, to: StatusRefunded, want: StatusApproved, wantErr: &ErrInvalidTransition{}},
+		{name: "expiry is not requested", from: StatusAuthorized, to: StatusExpired, want: StatusAuthorized, wantErr: &ErrInvalidTransition{}},
+		{name: "unknown status", from: StatusPending, to: "PAID", want: StatusPending, wantErr: &ErrUnknownStatus{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := newTestPayment(tt.from)
+			err := payment.TransitionTo(tt.to, "reason")
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("TransitionTo(%s) = %v, want no error", tt.to, err)
+				}
+			case *ErrInvalidTransition:
+				if !errors.As(err, &want) {
+					t.Fatalf("TransitionTo(%s) = %v, want *ErrInvalidTransition", tt.to, err)
+				}
+			case *ErrUnknownStatus:
+				if !errors.As(err, &want) {
+					t.Fatalf("TransitionTo(%s) = %v, want *ErrUnknownStatus", tt.to, err)
+				}
+			}
+			if payment.Status != tt.want {
+				t.Errorf("status = %s, want %s", payment.Status, tt.want)
+			}
+			if payment.Captured.Amount != tt.captured {
+				t.Errorf("captured = %d, want %d", payment.Captured.Amount, tt.captured)
+			}
+		})
+	}
+}
+
+func TestPaymentApplyRefund(t *testing.T) {
+	payment := newTestPayment(StatusPending)
+	if err := payment.Approve(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		amount   int64
+		status   string
+		refunded int64
+		wantErr  bool
+	}{
+		{amount: 3000, status: StatusPartiallyRefunded, refunded: 3000},
+		{amount: 8000, status: StatusPartiallyRefunded, refunded: 3000, wantErr: true},
+		{amount: 2000, status: StatusPartiallyRefunded, refunded: 5000},
+		{amount: 5000, status: StatusRefunded, refunded: 10000},
+		{amount: 1, status: StatusRefunded, refunded: 10000, wantErr: true},
+	}
+
+	for i, step := range steps {
+		err := payment.ApplyRefund(brl(step.amount))
+		if (err != nil) != step.wantErr {
+			t.Fatalf("step %d: ApplyRefund(%d) = %v, want error %v", i, step.amount, err, step.wantErr)
+		}
+		if payment.Status != step.status || payment.Refunded.Amount != step.refunded {
+			t.Fatalf("step %d: got %s with %d refunded, want %s with %d", i, payment.Status, payment.Refunded.Amount, step.status, step.refunded)
+		}
+	}
+}
+
+func TestPaymentCheckRefundCountsPendingRefunds(t *testing.T) {
+	payment := newTestPayment(StatusCaptured)
+	payment.Captured = brl(10000)
+
+	if err := payment.CheckRefund(brl(4000), brl(6000)); err != nil {
+		t.Errorf("CheckRefund of the remaining amount = %v", err)
+	}
+	var exceeds *ErrRefundExceedsCaptured
+	if err := payment.CheckRefund(brl(4001), brl(6000)); !errors.As(err, &exceeds) {
+		t.Errorf("CheckRefund over the remaining amount = %v, want *ErrRefundExceedsCaptured", err)
+	}
+}
+
+func TestPaymentCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Money
+		wantErr bool
+	}{
+		{name: "partial", amount: brl(4000)},
+		{name: "full", amount: brl(10000)},
+		{name: "more than authorized", amount: brl(10001), wantErr: true},
+		{name: "zero", amount: brl(0), wantErr: true},
+		{name: "other currency", amount: Money{Amount: 100, Currency: "USD"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := newTestPayment(StatusAuthorized)
+			err := payment.Capture(tt.amount)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Capture(%s) succeeded", tt.amount)
+				}
+				if payment.Status != StatusAuthorized {
+					t.Errorf("status = %s after a failed capture", payment.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Capture(%s) = %v", tt.amount, err)
+			}
+			if payment.Status != StatusCaptured || payment.Captured != tt.amount {
+				t.Errorf("got %s with %s captured", payment.Status, payment.Captured)
+			}
+		})
+	}
+}
+
+func TestPaymentTransitionClearsProcessingLease(t *testing.T) {
+	payment := newTestPayment(StatusPending)
+	payment.Processor = "simulator"
+	until := time.Now().Add(time.Minute)
+	payment.ProcessingUntil = &until
+
+	if !payment.Processing(time.Now()) {
+		t.Fatal("Processing = false before the lease ends")
+	}
+	if err := payment.Approve(); err != nil {
+		t.Fatal(err)
+	}
+	if payment.ProcessingUntil != nil || payment.AwaitsProcessor() {
+		t.Errorf("approved payment still awaits the processor")
+	}
+}
+
+func TestPaymentAllowsNewAttempt(t *testing.T) {
+	allowed := map[string]bool{
+		StatusRejected: true,
+		StatusVoided:   true,
+		StatusExpired:  true,
+	}
+
+	for _, status := range allStatuses {
+		payment := newTestPayment(status)
+		if got := payment.AllowsNewAttempt(); got != allowed[status] {
+			t.Errorf("%s: AllowsNewAttempt = %v, want %v", status, got, allowed[status])
+		}
+	}
+
+	deleted := newTestPayment(StatusPending)
+	now := time.Now()
+	deleted.DeletedAt = &now
+	if !deleted.AllowsNewAttempt() {
+		t.Error("a deleted attempt does not allow a new one")
+	}
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+const (
+	ProcessedMessageStatusProcessing = "PROCESSING"
+	ProcessedMessageStatusProcessed  = "PROCESSED"
+)
+
+// ProcessedMessage records that a consumer handled, or is handling, the message with
+// MessageID, so redeliveries and duplicate publishes are not processed twice.
+// A PROCESSING claim can be taken over by another worker once LockedUntil has passed.
+type ProcessedMessage struct {
+	Consumer    string
+	MessageID   string
+	Status      string
+	LockedUntil time.Time
+	ProcessedAt *time.Time
+	CreatedAt   time.Time
+}
+
+func NewProcessedMessage(consumer string, messageID string, lease time.Duration) *ProcessedMessage {
+	now := time.Now()
+	return &ProcessedMessage{
+		Consumer:    consumer,
+		MessageID:   messageID,
+		Status:      ProcessedMessageStatusProcessing,
+		LockedUntil: now.Add(lease),
+		CreatedAt:   now,
+	}
+}
+
+func (m *ProcessedMessage) Processed() bool {
+	return m.Status == ProcessedMessageStatusProcessed
+}
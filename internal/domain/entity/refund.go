@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"time"
+)
+
+// A refund is PENDING from the moment it is recorded until the processor answers;
+// pending refunds count against the refundable amount so they cannot be exceeded.
+const (
+	RefundStatusPending   = "PENDING"
+	RefundStatusSucceeded = "SUCCEEDED"
+	RefundStatusFailed    = "FAILED"
+)
+
+// ErrRefundExceedsCaptured is returned when a refund would take the refunded total above the captured amount.
+type ErrRefundExceedsCaptured struct {
+	PaymentID string
+	Requested Money
+	Available Money
+}
+
+func (e *ErrRefundExceedsCaptured) ErrorCode() apperr.Code {
+	return apperr.CodeInsufficientFunds
+}
+
+func (e *ErrRefundExceedsCaptured) Error() string {
+	return fmt.Sprintf("refund of %s exceeds the %s still refundable for payment %s", e.Requested, e.Available, e.PaymentID)
+}
+
+type Refund struct {
+	ID        string
+	PaymentID string
+	Amount    Money
+	Reason    string
+	Status    string
+	CreatedAt time.Time
+
+	// Failed attempts to learn the outcome of a PENDING refund from the processor
+	ReconcileAttempts int
+	NextReconcileAt   *time.Time
+}
+
+func NewRefund(id string, paymentID string, amount Money, reason string) *Refund {
+	return &Refund{
+		ID:        id,
+		PaymentID: paymentID,
+		Amount:    amount,
+		Reason:    reason,
+		Status:    RefundStatusPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (r *Refund) Succeed() {
+	r.Status = RefundStatusSucceeded
+}
+
+// Fail records that the processor did not give the money back.
+func (r *Refund) Fail() {
+	r.Status = RefundStatusFailed
+}
+
+// ReconcileFailed records that the outcome of the refund is still unknown, keeping it
+// PENDING, and postpones the next attempt to at.
+func (r *Refund) ReconcileFailed(at time.Time) {
+	r.Status = RefundStatusPending
+	r.ReconcileAttempts++
+	r.NextReconcileAt = &at
+}
+
+// PendingRefunds sums the amounts of the refunds still waiting for the processor.
+func PendingRefunds(refunds []*Refund, currency string) (Money, error) {
+	pending := Money{Currency: currency}
+	for _, refund := range refunds {
+		if refund.Status != RefundStatusPending {
+			continue
+		}
+		sum, err := pending.Add(refund.Amount)
+		if err != nil {
+			return Money{}, err
+		}
+		pending = sum
+	}
+	return pending, nil
+}
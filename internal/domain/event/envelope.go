@@ -0,0 +1,223 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Producer identifies this service in the events it publishes.
+const Producer = "gateway-payments"
+
+// Event types, which match the routing keys they are published with.
+const (
+	TypePaymentRequested = PaymentRequestedRoutingKey
+	TypePaymentProcessed = PaymentProcessedRoutingKey
+	TypePaymentRefunded  = PaymentRefundedRoutingKey
+	TypePaymentVoided    = PaymentVoidedRoutingKey
+)
+
+// EnvelopeSchema names the JSON Schema of the envelope. The schema of the data of
+// each event is named after its type.
+const EnvelopeSchema = "envelope"
+
+// LegacyVersion is reported for events published before the envelope existed, as
+// bare JSON objects with an "event" field naming their type.
+const LegacyVersion = 0
+
+// currentVersions is the newest data version of each event type. Decoders accept
+// this version and every older one.
+var currentVersions = map[string]int{
+	TypePaymentRequested: 1,
+	TypePaymentProcessed: 1,
+	TypePaymentRefunded:  1,
+	TypePaymentVoided:    1,
+}
+
+// CurrentVersion returns the data version this service publishes for eventType.
+func CurrentVersion(eventType string) int {
+	return currentVersions[eventType]
+}
+
+// Envelope wraps the data of every event with the metadata needed to identify,
+// order and trace it.
+type Envelope struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// CorrelationID is shared by every event of the same business flow, and
+	// CausationID is the ID of the event or request that caused this one
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	CausationID   string          `json:"causation_id,omitempty"`
+	Producer      string          `json:"producer,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// MarshalLegacyJSON encodes the envelope with the "event" field and the fields of its
+// data repeated at the top level, where consumers of bare events read them. Envelope
+// fields win when a data field has the same name.
+func (e *Envelope) MarshalLegacyJSON() ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(e.Data, &fields); err != nil {
+		// Data that is not an object has no fields to repeat
+		return body, nil
+	}
+	eventType, err := json.Marshal(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	fields["event"] = eventType
+
+	var envelopeFields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelopeFields); err != nil {
+		return nil, err
+	}
+	for key, value := range envelopeFields {
+		fields[key] = value
+	}
+	return json.Marshal(fields)
+}
+
+// ErrUnsupportedVersion is returned when an event is newer than this service understands.
+type ErrUnsupportedVersion struct {
+	Type    string
+	Version int
+}
+
+func (e *ErrUnsupportedVersion) ErrorCode() apperr.Code {
+	return apperr.CodeUnsupportedEvent
+}
+
+func (e *ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported version %d of event %s (up to %d is supported)", e.Version, e.Type, CurrentVersion(e.Type))
+}
+
+// ErrUnexpectedType is returned when decoding an event of a different type than requested.
+type ErrUnexpectedType struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrUnexpectedType) ErrorCode() apperr.Code {
+	return apperr.CodeUnsupportedEvent
+}
+
+func (e *ErrUnexpectedType) Error() string {
+	return fmt.Sprintf("expected event %s, got %s", e.Expected, e.Actual)
+}
+
+// Metadata carries the correlation of the flow being handled, so events produced
+// while handling it can be traced back to it.
+type Metadata struct {
+	CorrelationID string
+	CausationID   string
+}
+
+type metadataKey struct{}
+
+func WithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+func MetadataFromContext(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(metadataKey{}).(Metadata)
+	return metadata
+}
+
+// NewEnvelope wraps data as the current version of eventType, correlated with the
+// metadata in ctx. An event without a correlation starts a new one with its own ID.
+func NewEnvelope(ctx context.Context, eventType string, data interface{}) (*Envelope, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding %s data: %w", eventType, err)
+	}
+
+	metadata := MetadataFromContext(ctx)
+	envelope := &Envelope{
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		Version:       CurrentVersion(eventType),
+		OccurredAt:    time.Now().UTC(),
+		CorrelationID: metadata.CorrelationID,
+		CausationID:   metadata.CausationID,
+		Producer:      Producer,
+		Data:          body,
+	}
+	if envelope.CorrelationID == "" {
+		envelope.CorrelationID = envelope.ID
+	}
+
+	return envelope, nil
+}
+
+// Metadata returns the metadata for events caused by this one.
+func (e *Envelope) Metadata() Metadata {
+	correlationID := e.CorrelationID
+	if correlationID == "" {
+		correlationID = e.ID
+	}
+	return Metadata{CorrelationID: correlationID, CausationID: e.ID}
+}
+
+// PeekEnvelope parses body as an envelope, reporting false for legacy events.
+func PeekEnvelope(body []byte) (*Envelope, bool) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Type == "" || envelope.Data == nil {
+		return nil, false
+	}
+	return &envelope, true
+}
+
+// Decode reads an event of eventType into data, accepting both envelopes up to the
+// current version and legacy bare events. messageID identifies legacy events, which
+// carry no ID of their own.
+func Decode(body []byte, eventType string, messageID string, data interface{}) (*Envelope, error) {
+	envelope, ok := PeekEnvelope(body)
+	if !ok {
+		return decodeLegacy(body, eventType, messageID, data)
+	}
+
+	if envelope.Type != eventType {
+		return nil, &ErrUnexpectedType{Expected: eventType, Actual: envelope.Type}
+	}
+	if envelope.Version < 1 || envelope.Version > CurrentVersion(eventType) {
+		return nil, &ErrUnsupportedVersion{Type: eventType, Version: envelope.Version}
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		return nil, fmt.Errorf("error decoding %s v%d data: %w", eventType, envelope.Version, err)
+	}
+
+	return envelope, nil
+}
+
+func decodeLegacy(body []byte, eventType string, messageID string, data interface{}) (*Envelope, error) {
+	var legacy struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, fmt.Errorf("error decoding %s event: %w", eventType, err)
+	}
+	if legacy.Event != "" && legacy.Event != eventType {
+		return nil, &ErrUnexpectedType{Expected: eventType, Actual: legacy.Event}
+	}
+	if err := json.Unmarshal(body, data); err != nil {
+		return nil, fmt.Errorf("error decoding %s event: %w", eventType, err)
+	}
+
+	return &Envelope{
+		ID:      messageID,
+		Type:    eventType,
+		Version: LegacyVersion,
+		Data:    json.RawMessage(body),
+	}, nil
+}
@@ -0,0 +1,173 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type testPaymentRequested struct {
+	OrderID string `json:"order_id"`
+	Amount  string `json:"amount"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantID      string
+		wantVersion int
+		wantOrder   string
+		wantErr     interface{}
+	}{
+		{
+			name:        "envelope",
+			body:        `{"id":"event-1","type":"payment.requested","version":1,"data":{"order_id":"order-1","amount":"10.00"}}`,
+			wantID:      "event-1",
+			wantVersion: 1,
+			wantOrder:   "order-1",
+		},
+		{
+			name:        "legacy bare event",
+			body:        `{"event":"payment.requested","order_id":"order-1","amount":"10.00"}`,
+			wantID:      "message-1",
+			wantVersion: LegacyVersion,
+			wantOrder:   "order-1",
+		},
+		{
+			name:        "legacy event without an event field",
+			body:        `{"order_id":"order-1","amount":"10.00"}`,
+			wantID:      "message-1",
+			wantVersion: LegacyVersion,
+			wantOrder:   "order-1",
+		},
+		{
+			name:        "envelope with legacy fields reads data",
+			body:        `{"id":"event-1","type":"payment.requested","version":1,"data":{"order_id":"order-1"},"event":"payment.requested","order_id":"stale"}`,
+			wantID:      "event-1",
+			wantVersion: 1,
+			wantOrder:   "order-1",
+		},
+		{
+			name:    "envelope of another type",
+			body:    `{"id":"event-1","type":"payment.processed","version":1,"data":{}}`,
+			wantErr: &ErrUnexpectedType{},
+		},
+		{
+			name:    "legacy event of another type",
+			body:    `{"event":"payment.processed","order_id":"order-1"}`,
+			wantErr: &ErrUnexpectedType{},
+		},
+		{
+			name:    "newer version",
+			body:    `{"id":"event-1","type":"payment.requested","version":2,"data":{}}`,
+			wantErr: &ErrUnsupportedVersion{},
+		},
+		{
+			name:    "envelope without a version",
+			body:    `{"id":"event-1","type":"payment.requested","data":{}}`,
+			wantErr: &ErrUnsupportedVersion{},
+		},
+		{
+			name:    "malformed",
+			body:    `{"event":`,
+			wantErr: errors.New("malformed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data testPaymentRequested
+			envelope, err := Decode([]byte(tt.body), TypePaymentRequested, "message-1", &data)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Decode() = %v", err)
+				}
+			case *ErrUnexpectedType:
+				if !errors.As(err, &want) {
+					t.Fatalf("Decode() = %v, want *ErrUnexpectedType", err)
+				}
+				return
+			case *ErrUnsupportedVersion:
+				if !errors.As(err, &want) {
+					t.Fatalf("Decode() = %v, want *ErrUnsupportedVersion", err)
+				}
+				return
+			default:
+				if err == nil {
+					t.Fatal("Decode() succeeded")
+				}
+				return
+			}
+
+			if envelope.ID != tt.wantID || envelope.Type != TypePaymentRequested || envelope.Version != tt.wantVersion {
+				t.Errorf("envelope = %s %s v%d, want %s %s v%d", envelope.ID, envelope.Type, envelope.Version, tt.wantID, TypePaymentRequested, tt.wantVersion)
+			}
+			if data.OrderID != tt.wantOrder {
+				t.Errorf("order_id = %q, want %q", data.OrderID, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestMarshalLegacyJSON(t *testing.T) {
+	envelope, err := NewEnvelope(context.Background(), TypePaymentProcessed, map[string]interface{}{
+		"order_id": "order-1",
+		"status":   "APPROVED",
+		// Data fields named like envelope fields lose to them
+		"id":      "data-id",
+		"version": 7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := envelope.MarshalLegacyJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"event":    TypePaymentProcessed,
+		"order_id": "order-1",
+		"status":   "APPROVED",
+		"id":       envelope.ID,
+		"type":     TypePaymentProcessed,
+		"version":  float64(1),
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("%s = %v, want %v", key, fields[key], value)
+		}
+	}
+	data, _ := fields["data"].(map[string]interface{})
+	if data["id"] != "data-id" {
+		t.Errorf("data = %v, want it unchanged", fields["data"])
+	}
+
+	// Consumers of envelopes read the same event
+	decoded, ok := PeekEnvelope(body)
+	if !ok || decoded.ID != envelope.ID || string(decoded.Data) != string(envelope.Data) {
+		t.Errorf("PeekEnvelope() = %+v, %v", decoded, ok)
+	}
+}
+
+func TestMarshalLegacyJSONWithoutObjectData(t *testing.T) {
+	envelope := &Envelope{ID: "event-1", Type: TypePaymentProcessed, Version: 1, Data: json.RawMessage(`[1,2]`)}
+
+	body, err := envelope.MarshalLegacyJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := json.Marshal(envelope)
+	if string(body) != string(plain) {
+		t.Errorf("body = %s, want the plain envelope %s", body, plain)
+	}
+}
@@ -1,10 +1,31 @@
 package event
 
-import "time"
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
 
 type PaymentProcessed struct {
-	Event       string    `json:"event"`
-	OrderID     string    `json:"order_id"`
-	Status      string    `json:"status"`
-	ProcessedAt time.Time `json:"processed_at"`
+	PaymentID   string      `json:"payment_id"`
+	OrderID     string      `json:"order_id"`
+	Attempt     int         `json:"attempt"`
+	Amount      json.Number `json:"amount"`
+	AmountMinor int64       `json:"amount_minor"`
+	Currency    string      `json:"currency"`
+	Status      string      `json:"status"`
+	ProcessedAt time.Time   `json:"processed_at"`
+}
+
+func NewPaymentProcessed(payment *entity.Payment) PaymentProcessed {
+	return PaymentProcessed{
+		PaymentID:   payment.ID,
+		OrderID:     payment.OrderID,
+		Attempt:     payment.Attempt,
+		Amount:      json.Number(payment.Amount.Decimal()),
+		AmountMinor: payment.Amount.Amount,
+		Currency:    payment.Amount.Currency,
+		Status:      payment.Status,
+		ProcessedAt: time.Now(),
+	}
 }
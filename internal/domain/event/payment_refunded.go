@@ -0,0 +1,37 @@
+package event
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type PaymentRefunded struct {
+	PaymentID          string      `json:"payment_id"`
+	OrderID            string      `json:"order_id"`
+	RefundID           string      `json:"refund_id"`
+	Amount             json.Number `json:"amount"`
+	AmountMinor        int64       `json:"amount_minor"`
+	TotalRefunded      json.Number `json:"total_refunded"`
+	TotalRefundedMinor int64       `json:"total_refunded_minor"`
+	Currency           string      `json:"currency"`
+	Reason             string      `json:"reason,omitempty"`
+	PaymentStatus      string      `json:"payment_status"`
+	RefundedAt         time.Time   `json:"refunded_at"`
+}
+
+func NewPaymentRefunded(payment *entity.Payment, refund *entity.Refund) PaymentRefunded {
+	return PaymentRefunded{
+		PaymentID:          payment.ID,
+		OrderID:            payment.OrderID,
+		RefundID:           refund.ID,
+		Amount:             json.Number(refund.Amount.Decimal()),
+		AmountMinor:        refund.Amount.Amount,
+		TotalRefunded:      json.Number(payment.Refunded.Decimal()),
+		TotalRefundedMinor: payment.Refunded.Amount,
+		Currency:           refund.Amount.Currency,
+		Reason:             refund.Reason,
+		PaymentStatus:      payment.Status,
+		RefundedAt:         refund.CreatedAt,
+	}
+}
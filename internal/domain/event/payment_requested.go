@@ -1,11 +1,26 @@
 package event
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type PaymentRequested struct {
-	Event       string    `json:"event"`
-	OrderID     string    `json:"order_id"`
-	Amount      float64   `json:"amount"`
-	Currency    string    `json:"currency"`
-	RequestedAt time.Time `json:"requested_at"`
+	OrderID     string      `json:"order_id"`
+	Amount      json.Number `json:"amount"`
+	Currency    string      `json:"currency"`
+	Method      string      `json:"method,omitempty"`
+	Capture     *bool       `json:"capture,omitempty"` // false requests an authorization hold only
+	CardToken   string      `json:"card_token,omitempty"`
+	RequestedAt time.Time   `json:"requested_at"`
+}
+
+// DecodePaymentRequested reads a payment.requested event, enveloped or legacy.
+func DecodePaymentRequested(body []byte, messageID string) (*PaymentRequested, *Envelope, error) {
+	var requested PaymentRequested
+	envelope, err := Decode(body, TypePaymentRequested, messageID, &requested)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &requested, envelope, nil
 }
@@ -0,0 +1,31 @@
+package event
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type PaymentVoided struct {
+	PaymentID   string      `json:"payment_id"`
+	OrderID     string      `json:"order_id"`
+	Amount      json.Number `json:"amount"`
+	AmountMinor int64       `json:"amount_minor"`
+	Currency    string      `json:"currency"`
+	Status      string      `json:"status"` // VOIDED, or EXPIRED when the authorization timed out
+	Reason      string      `json:"reason,omitempty"`
+	VoidedAt    time.Time   `json:"voided_at"`
+}
+
+func NewPaymentVoided(payment *entity.Payment) PaymentVoided {
+	return PaymentVoided{
+		PaymentID:   payment.ID,
+		OrderID:     payment.OrderID,
+		Amount:      json.Number(payment.Amount.Decimal()),
+		AmountMinor: payment.Amount.Amount,
+		Currency:    payment.Amount.Currency,
+		Status:      payment.Status,
+		Reason:      payment.StatusReason,
+		VoidedAt:    time.Now(),
+	}
+}
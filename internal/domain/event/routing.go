@@ -0,0 +1,11 @@
+package event
+
+// Exchange and routing keys used to publish payment events.
+const (
+	PaymentsExchange = "payments.exchange"
+
+	PaymentRequestedRoutingKey = "payment.requested"
+	PaymentProcessedRoutingKey = "payment.processed"
+	PaymentRefundedRoutingKey  = "payment.refunded"
+	PaymentVoidedRoutingKey    = "payment.voided"
+)
@@ -0,0 +1,34 @@
+package messaging
+
+import "context"
+
+// Handler processes a delivered message and must Ack or Nack it.
+type Handler func(msg *Message)
+
+// SubscribeOptions controls how many messages of a queue are processed at once.
+type SubscribeOptions struct {
+	// Workers is the number of messages handled concurrently (default 1)
+	Workers int
+	// Prefetch caps the unacknowledged messages delivered to the consumer (default 2 × Workers)
+	Prefetch int
+}
+
+// WithDefaults fills in the zero values.
+func (o SubscribeOptions) WithDefaults() SubscribeOptions {
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	if o.Prefetch <= 0 {
+		o.Prefetch = 2 * o.Workers
+	}
+	return o
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+type EventSubscriber interface {
+	// Subscribe delivers the messages of queue to handler until the subscriber is closed.
+	Subscribe(queue string, consumer string, handler Handler, options SubscribeOptions) error
+}
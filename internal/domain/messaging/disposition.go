@@ -0,0 +1,15 @@
+package messaging
+
+// Disposition is what a consumer does with a message it could not handle.
+type Disposition string
+
+const (
+	// DispositionAck drops the message, since there is nothing left to do with it
+	DispositionAck Disposition = "ack"
+	// DispositionRequeue puts the message back on its queue right away
+	DispositionRequeue Disposition = "requeue"
+	// DispositionRetry schedules another delivery through the retry tiers
+	DispositionRetry Disposition = "retry"
+	// DispositionDeadLetter moves the message to the dead-letter queue
+	DispositionDeadLetter Disposition = "dead_letter"
+)
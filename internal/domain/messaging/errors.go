@@ -0,0 +1,28 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNacked is returned when the broker refuses responsibility for a published message.
+var ErrNacked = errors.New("message nacked by the broker")
+
+// ErrConfirmTimeout is returned when the broker does not confirm a publish in time.
+// The message may or may not have been accepted.
+var ErrConfirmTimeout = errors.New("timed out waiting for the broker to confirm the message")
+
+// ErrUnroutable is returned when a published message does not match any queue.
+type ErrUnroutable struct {
+	Exchange   string
+	RoutingKey string
+	Reason     string
+}
+
+func (e *ErrUnroutable) Error() string {
+	message := fmt.Sprintf("message to exchange %q with routing key %q was not routed to any queue", e.Exchange, e.RoutingKey)
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	return message
+}
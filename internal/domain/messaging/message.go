@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"errors"
+	"time"
+)
+
+// Headers carrying the event metadata that has no AMQP property of its own.
+const (
+	HeaderEventVersion = "x-event-version"
+	HeaderCausationID  = "x-causation-id"
+)
+
+// ErrAlreadyAcknowledged is returned when a message is acked or nacked twice.
+var ErrAlreadyAcknowledged = errors.New("message already acknowledged")
+
+// Acknowledger settles a delivered message with the broker it came from.
+type Acknowledger interface {
+	Ack() error
+	// Nack rejects the message. With requeue it is delivered again; otherwise it is
+	// dead-lettered when the queue has a dead-letter exchange, or dropped.
+	Nack(requeue bool) error
+}
+
+// Message is a broker-agnostic message, used both to publish and to deliver.
+type Message struct {
+	ID          string
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	// Type, CorrelationID and AppID map to the AMQP properties of the same names
+	Type          string
+	CorrelationID string
+	AppID         string
+	Headers       map[string]interface{}
+	Body          []byte
+	Timestamp     time.Time
+	Redelivered   bool
+
+	acknowledger Acknowledger
+}
+
+// NewDelivery binds msg to the acknowledger of the broker that delivered it.
+func NewDelivery(msg Message, acknowledger Acknowledger) *Message {
+	msg.acknowledger = acknowledger
+	return &msg
+}
+
+func (m *Message) Ack() error {
+	if m.acknowledger == nil {
+		return nil
+	}
+	return m.acknowledger.Ack()
+}
+
+func (m *Message) Nack(requeue bool) error {
+	if m.acknowledger == nil {
+		return nil
+	}
+	return m.acknowledger.Nack(requeue)
+}
+
+// Header returns the header value as a string, or "" when missing.
+func (m *Message) Header(key string) string {
+	value, ok := m.Headers[key].(string)
+	if !ok {
+		return ""
+	}
+	return value
+}
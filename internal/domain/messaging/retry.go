@@ -0,0 +1,94 @@
+package messaging
+
+import "time"
+
+// Headers recording the delivery attempts of a message and, once it is dead-lettered, why it failed.
+const (
+	HeaderAttempt            = "x-attempt"
+	HeaderFailureReason      = "x-failure-reason"
+	HeaderFailureKind        = "x-failure-kind"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+	HeaderFailedAt           = "x-failed-at"
+	HeaderReplayedFrom       = "x-replayed-from"
+	HeaderDeath              = "x-death"
+	// HeaderValidationErrors holds the schema violations of a poison message, as a JSON array
+	HeaderValidationErrors = "x-validation-errors"
+	// HeaderErrorCode holds the apperr code of the failure that dead-lettered the message
+	HeaderErrorCode            = "x-error-code"
+	FailureKindPoison          = "poison"
+	FailureKindRetriesExceeded = "retries_exhausted"
+)
+
+// FailureHeaders are the headers that record the attempts and failures of a message.
+// A replayed message is published without them, so it starts over with a fresh set of attempts.
+var FailureHeaders = []string{
+	HeaderAttempt,
+	HeaderFailureReason,
+	HeaderFailureKind,
+	HeaderOriginalExchange,
+	HeaderOriginalRoutingKey,
+	HeaderFailedAt,
+	HeaderReplayedFrom,
+	HeaderDeath,
+	HeaderValidationErrors,
+	HeaderErrorCode,
+}
+
+// IsFailureHeader reports whether key is one of FailureHeaders.
+func IsFailureHeader(key string) bool {
+	for _, header := range FailureHeaders {
+		if header == key {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryTier is a queue that holds messages for Delay before dead-lettering them
+// back to the queue they came from.
+type RetryTier struct {
+	Queue string
+	Delay time.Duration
+}
+
+// RetryPolicy lists the retry tiers of a queue, in order, and where messages go
+// once every tier was tried or they cannot be processed at all.
+type RetryPolicy struct {
+	Tiers                []RetryTier
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+}
+
+// MaxAttempts is the number of deliveries before a message is dead-lettered.
+func (p RetryPolicy) MaxAttempts() int {
+	return len(p.Tiers) + 1
+}
+
+// Next returns the tier a message that failed its attempt-th delivery should wait in,
+// or false when the retries are exhausted.
+func (p RetryPolicy) Next(attempt int) (RetryTier, bool) {
+	if attempt < 1 || attempt > len(p.Tiers) {
+		return RetryTier{}, false
+	}
+	return p.Tiers[attempt-1], true
+}
+
+// Attempt returns the delivery attempt of the message, starting at 1.
+func (m *Message) Attempt() int {
+	var attempt int64
+	switch value := m.Headers[HeaderAttempt].(type) {
+	case int:
+		attempt = int64(value)
+	case int32:
+		attempt = int64(value)
+	case int64:
+		attempt = value
+	case float64:
+		attempt = int64(value)
+	}
+	if attempt < 1 {
+		return 1
+	}
+	return int(attempt)
+}
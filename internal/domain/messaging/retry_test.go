@@ -0,0 +1,33 @@
+package messaging
+
+import "testing"
+
+func TestRetryPolicyNext(t *testing.T) {
+	policy := RetryPolicy{
+		Tiers: []RetryTier{
+			{Queue: "retry.5s"},
+			{Queue: "retry.30s"},
+		},
+	}
+
+	tests := []struct {
+		attempt int
+		queue   string
+		ok      bool
+	}{
+		{attempt: 0},
+		{attempt: 1, queue: "retry.5s", ok: true},
+		{attempt: 2, queue: "retry.30s", ok: true},
+		{attempt: 3},
+	}
+
+	for _, tt := range tests {
+		tier, ok := policy.Next(tt.attempt)
+		if ok != tt.ok || tier.Queue != tt.queue {
+			t.Errorf("Next(%d) = %q, %v, want %q, %v", tt.attempt, tier.Queue, ok, tt.queue, tt.ok)
+		}
+	}
+	if policy.MaxAttempts() != 3 {
+		t.Errorf("MaxAttempts() = %d, want 3", policy.MaxAttempts())
+	}
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+)
+
+// ErrUnavailable is returned when the processor could not be reached or failed
+// transiently; the operation may be retried.
+var ErrUnavailable = apperr.New(apperr.CodeProcessorUnavailable, "payment processor unavailable")
+
+// DeclineInsufficientFunds is the decline code processors use when the customer lacks funds.
+const DeclineInsufficientFunds = "insufficient_funds"
+
+// DeclineAlreadyReleased is the decline code of a void of an authorization that was
+// already voided or expired at the processor. Callers treat it as a successful void.
+const DeclineAlreadyReleased = "already_released"
+
+// ErrDeclined is returned when the processor refuses a capture, refund or void.
+type ErrDeclined struct {
+	Operation string
+	Code      string
+	Reason    string
+}
+
+// ErrorCode reports declines for lack of funds apart, since the customer can act on them.
+func (e *ErrDeclined) ErrorCode() apperr.Code {
+	if e.Code == DeclineInsufficientFunds {
+		return apperr.CodeInsufficientFunds
+	}
+	return apperr.CodeProcessorDeclined
+}
+
+func (e *ErrDeclined) Error() string {
+	return fmt.Sprintf("%s declined by payment processor: %s (%s)", e.Operation, e.Reason, e.Code)
+}
+
+type AuthorizeRequest struct {
+	PaymentID string
+	OrderID   string
+	Amount    entity.Money
+	Method    string
+	// SourceToken identifies the card or wallet; it is passed through and never stored
+	SourceToken string
+	// Capture settles the funds in the same call instead of only placing a hold
+	Capture bool
+}
+
+type CaptureRequest struct {
+	PaymentID string
+	Reference string
+	Amount    entity.Money
+}
+
+type RefundRequest struct {
+	PaymentID string
+	// RefundID is sent again when the outcome of a refund is reconciled, so processors
+	// must give the money back once per refund ID
+	RefundID  string
+	Reference string
+	Amount    entity.Money
+}
+
+type VoidRequest struct {
+	PaymentID string
+	Reference string
+}
+
+type Result struct {
+	Approved bool
+	// Reference is the processor's identifier for the payment, used by later operations
+	Reference     string
+	DeclineCode   string
+	DeclineReason string
+}
+
+// Processor talks to whoever actually moves the money: an acquirer, a PSP or a simulator.
+type Processor interface {
+	Authorize(ctx context.Context, request AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, request CaptureRequest) (*Result, error)
+	Refund(ctx context.Context, request RefundRequest) (*Result, error)
+	Void(ctx context.Context, request VoidRequest) (*Result, error)
+}
+
+// Declined converts a declined result into an ErrDeclined for operation.
+func Declined(operation string, result *Result) error {
+	return &ErrDeclined{Operation: operation, Code: result.DeclineCode, Reason: result.DeclineReason}
+}
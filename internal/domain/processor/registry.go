@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultRoute matches any payment method without a route of its own.
+const DefaultRoute = "*"
+
+// Registry holds the configured processors by name and which one handles each payment method.
+type Registry struct {
+	processors map[string]Processor
+	routes     map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		processors: make(map[string]Processor),
+		routes:     make(map[string]string),
+	}
+}
+
+func (r *Registry) Register(name string, processor Processor) {
+	r.processors[name] = processor
+}
+
+// Route sends payments of method to the processor registered as name.
+func (r *Registry) Route(method string, name string) error {
+	if _, ok := r.processors[name]; !ok {
+		return fmt.Errorf("payment processor %q is not registered", name)
+	}
+	r.routes[normalizeMethod(method)] = name
+	return nil
+}
+
+// Get returns the processor registered as name.
+func (r *Registry) Get(name string) (Processor, bool) {
+	processor, ok := r.processors[name]
+	return processor, ok
+}
+
+// ForMethod returns the name and processor routed to method. When no processor is
+// routed the payment is left for manual approval.
+func (r *Registry) ForMethod(method string) (string, Processor, bool) {
+	name, ok := r.routes[normalizeMethod(method)]
+	if !ok {
+		name, ok = r.routes[DefaultRoute]
+	}
+	if !ok {
+		return "", nil, false
+	}
+	return name, r.processors[name], true
+}
+
+// normalizeMethod makes "Credit Card", "credit_card" and "CREDITCARD" the same route.
+func normalizeMethod(method string) string {
+	if method == DefaultRoute {
+		return method
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, method)
+}
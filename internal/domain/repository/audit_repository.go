@@ -0,0 +1,7 @@
+package repository
+
+import "gateway-payments/internal/domain/entity"
+
+type AuditRepository interface {
+	Create(entry *entity.AuditEntry) error
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "gateway-payments/internal/domain/entity"
+
+type DeadLetterRepository interface {
+	Create(deadLetter *entity.DeadLetter) error
+	FindByID(id string) (*entity.DeadLetter, error)
+	// FindAll lists the messages with the given status, or every message when status is empty, oldest first.
+	FindAll(status string, page, limit int) ([]*entity.DeadLetter, error)
+	Update(deadLetter *entity.DeadLetter) error
+	Delete(id string) error
+}
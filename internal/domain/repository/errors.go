@@ -1,9 +1,37 @@
 package repository
 
+import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+)
+
+// ErrDuplicateKey is returned when a unique constraint would be violated.
+var ErrDuplicateKey = apperr.New(apperr.CodeConflict, "duplicate key")
+
 type ErrNotFound struct {
 	Message string
 }
 
+func (e *ErrNotFound) ErrorCode() apperr.Code {
+	return apperr.CodeNotFound
+}
+
 func (e *ErrNotFound) Error() string {
 	return e.Message
 }
+
+// ErrConcurrentModification is returned when a record changed after it was read, so
+// writing it would overwrite the other change. Read it again and retry.
+type ErrConcurrentModification struct {
+	Entity  string
+	ID      string
+	Version int64
+}
+
+func (e *ErrConcurrentModification) ErrorCode() apperr.Code {
+	return apperr.CodeConcurrentModification
+}
+
+func (e *ErrConcurrentModification) Error() string {
+	return fmt.Sprintf("%s %s was modified concurrently (expected version %d), retry the request", e.Entity, e.ID, e.Version)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	// Create stores a new key and returns ErrDuplicateKey if it already exists.
+	Create(record *entity.IdempotencyKey) error
+	FindByKey(key string) (*entity.IdempotencyKey, error)
+	Complete(record *entity.IdempotencyKey) error
+	// TakeOver restarts a key still in progress that was created before staleBefore,
+	// setting its created_at to now. It returns false when the key is no longer stale.
+	TakeOver(key string, staleBefore time.Time, now time.Time) (bool, error)
+	Delete(key string) error
+	// DeleteCreatedBefore deletes up to limit keys older than before and returns how many were deleted.
+	DeleteCreatedBefore(before time.Time, limit int) (int64, error)
+}
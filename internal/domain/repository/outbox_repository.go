@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type OutboxRepository interface {
+	// Add stores messages to be published by the relay.
+	Add(messages ...*entity.OutboxMessage) error
+	// Claim leases up to limit unsent messages to owner for the lease duration so
+	// that concurrent relays do not publish the same message at the same time.
+	Claim(owner string, limit int, lease time.Duration) ([]*entity.OutboxMessage, error)
+	MarkSent(id string, sentAt time.Time) error
+	// MarkFailed records the error and makes the message available again at retryAt.
+	MarkFailed(id string, lastError string, retryAt time.Time) error
+	Stats() (*entity.OutboxStats, error)
+	// DeleteSentBefore deletes up to limit messages sent before before and returns how many were deleted.
+	DeleteSentBefore(before time.Time, limit int) (int64, error)
+}
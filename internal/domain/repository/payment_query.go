@@ -0,0 +1,49 @@
+package repository
+
+import "time"
+
+// Fields payments can be sorted by.
+const (
+	PaymentSortCreatedAt = "created_at"
+	PaymentSortUpdatedAt = "updated_at"
+	PaymentSortAmount    = "amount"
+	PaymentSortStatus    = "status"
+)
+
+// PaymentFilter narrows a payment listing. Empty fields do not filter; slices match
+// any of their values and amounts are in minor units, inclusive.
+type PaymentFilter struct {
+	Statuses    []string
+	Methods     []string
+	OrderID     string
+	Currency    string
+	MinAmount   *int64
+	MaxAmount   *int64
+	CreatedFrom *time.Time // inclusive
+	CreatedTo   *time.Time // exclusive
+	// IncludeDeleted also lists soft-deleted payments
+	IncludeDeleted bool
+}
+
+type PaymentSort struct {
+	Field      string
+	Descending bool
+}
+
+// PaymentKey is the position of a payment in a listing sorted by creation time.
+type PaymentKey struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// PaymentQuery selects a page of payments. Ties in Sort are broken by ID, so pages
+// are stable.
+type PaymentQuery struct {
+	Filter PaymentFilter
+	Sort   []PaymentSort
+	Page   int
+	Limit  int
+	// After selects the payments that follow the key in Sort order instead of a
+	// page number (keyset pagination). It requires Sort to be created_at alone.
+	After *PaymentKey
+}
@@ -1,11 +1,37 @@
 package repository
 
-import "gateway-payments/internal/domain/entity"
+import (
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
 
 type PaymentRepository interface {
-	Save(payment *entity.Payment) error
+	// Create inserts the payment and the given outbox messages in a single transaction.
+	// It returns ErrDuplicateKey when the order already has an attempt with the same
+	// number, or one that does not allow new attempts.
+	Create(payment *entity.Payment, messages ...*entity.OutboxMessage) error
+	// Update writes the payment and the given outbox messages in a single transaction if
+	// its version did not change since it was read, and increments the version. Otherwise
+	// it returns *ErrConcurrentModification. Payments are soft-deleted through Update.
+	Update(payment *entity.Payment, messages ...*entity.OutboxMessage) error
+	// FindByID returns ErrNotFound for soft-deleted payments too
 	FindByID(id string) (*entity.Payment, error)
-	FindAll(page, limit int) ([]*entity.Payment, error)
-	Delete(id string) error
+	FindByIDIncludingDeleted(id string) (*entity.Payment, error)
+	// FindAll returns a page of the payments matching the query.
+	FindAll(query PaymentQuery) ([]*entity.Payment, error)
+	// Count returns how many payments match the filter.
+	Count(filter PaymentFilter) (int, error)
+	// FindByOrderID returns the last attempt of the order, even if it was deleted, since
+	// attempts are numbered after it
 	FindByOrderID(orderID string) (*entity.Payment, error)
+	// FindAllByOrderID lists the attempts of an order in order; an order without
+	// payments gives an empty list
+	FindAllByOrderID(orderID string, includeDeleted bool) ([]*entity.Payment, error)
+	// FindAuthorizedBefore returns up to limit AUTHORIZED payments whose hold started before
+	// the given time, skipping those whose next expiry attempt is after now.
+	FindAuthorizedBefore(before time.Time, now time.Time, limit int) ([]*entity.Payment, error)
+	// PurgeDeletedBefore permanently deletes up to limit payments soft-deleted before the
+	// given time, skipping those under legal hold or with a retained status, and returns
+	// how many were deleted.
+	PurgeDeletedBefore(before time.Time, limit int) (int64, error)
 }
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type ProcessedMessageRepository interface {
+	// Claim stores a PROCESSING record, taking over an expired claim for the same message.
+	// It returns ErrDuplicateKey when the message was processed or is claimed by another worker.
+	Claim(message *entity.ProcessedMessage) error
+	Find(consumer string, messageID string) (*entity.ProcessedMessage, error)
+	MarkProcessed(consumer string, messageID string, at time.Time) error
+	// Release deletes a claim so the message can be processed again.
+	Release(consumer string, messageID string) error
+	// DeleteCreatedBefore deletes up to limit records older than before and returns how many were deleted.
+	DeleteCreatedBefore(before time.Time, limit int) (int64, error)
+}
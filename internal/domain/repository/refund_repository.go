@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+type RefundRepository interface {
+	// Create inserts the refund. Run it in a UnitOfWork with the update of the payment.
+	Create(refund *entity.Refund) error
+	// Update writes the status of a PENDING refund once the processor answered, or its
+	// postponed reconciliation. It returns ErrConcurrentModification if the refund is no longer PENDING.
+	Update(refund *entity.Refund) error
+	FindByPaymentID(paymentID string) ([]*entity.Refund, error)
+	// FindPendingBefore returns PENDING refunds created before the given time whose
+	// reconciliation is not postponed past now, oldest first.
+	FindPendingBefore(before time.Time, now time.Time, limit int) ([]*entity.Refund, error)
+}
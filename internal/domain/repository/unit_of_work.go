@@ -0,0 +1,19 @@
+package repository
+
+import "context"
+
+// Repositories gives access to repositories that share the transaction of a unit of work.
+type Repositories interface {
+	Payments() PaymentRepository
+	Refunds() RefundRepository
+	Outbox() OutboxRepository
+	ProcessedMessages() ProcessedMessageRepository
+	DeadLetters() DeadLetterRepository
+}
+
+// UnitOfWork runs several repository calls atomically.
+type UnitOfWork interface {
+	// Do runs fn in a transaction, which is committed when fn returns nil and rolled
+	// back otherwise. The repositories must not be used after fn returns.
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"strings"
+)
+
+// Violation is a rule broken by one field of a document. Field is a JSON path such
+// as "amount" or "data.items[0].sku", empty for the document itself.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Field == "" {
+		return v.Message
+	}
+	return v.Field + ": " + v.Message
+}
+
+// ErrValidation is returned when a document does not match its schema.
+type ErrValidation struct {
+	Schema     string
+	Violations []Violation
+}
+
+func (e *ErrValidation) ErrorCode() apperr.Code {
+	return apperr.CodeValidationFailed
+}
+
+func (e *ErrValidation) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.String()
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Schema, strings.Join(messages, "; "))
+}
+
+// Validator checks JSON documents against named schemas. Validate returns an
+// *ErrValidation when the document breaks the schema and another error when it is
+// not valid JSON or the schema does not exist.
+type Validator interface {
+	Validate(schema string, document []byte) error
+}
@@ -0,0 +1,140 @@
+package acquirer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway-payments/internal/domain/processor"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AuthorizationRequest is the body sent to POST {base}/authorizations.
+type AuthorizationRequest struct {
+	PaymentID   string `json:"payment_id"`
+	OrderID     string `json:"order_id"`
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+	Method      string `json:"method"`
+	SourceToken string `json:"source_token,omitempty"`
+	Capture     bool   `json:"capture"`
+}
+
+// OperationRequest is the body sent to capture, refund and void a previous authorization.
+type OperationRequest struct {
+	PaymentID   string `json:"payment_id"`
+	RefundID    string `json:"refund_id,omitempty"`
+	AmountMinor int64  `json:"amount_minor,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+}
+
+// OperationResponse is returned by every acquirer endpoint.
+type OperationResponse struct {
+	Approved      bool   `json:"approved"`
+	Reference     string `json:"reference"`
+	DeclineCode   string `json:"decline_code,omitempty"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
+
+// HTTPAcquirer is a generic JSON-over-HTTP adapter. Any acquirer, or a local stub,
+// exposing the endpoints below can be plugged in:
+//
+//	POST {base}/authorizations
+//	POST {base}/authorizations/{reference}/capture
+//	POST {base}/authorizations/{reference}/refunds
+//	POST {base}/authorizations/{reference}/void
+type HTTPAcquirer struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPAcquirer(baseURL string, timeout time.Duration) *HTTPAcquirer {
+	return &HTTPAcquirer{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *HTTPAcquirer) Authorize(ctx context.Context, request processor.AuthorizeRequest) (*processor.Result, error) {
+	return a.post(ctx, "/authorizations", AuthorizationRequest{
+		PaymentID:   request.PaymentID,
+		OrderID:     request.OrderID,
+		AmountMinor: request.Amount.Amount,
+		Currency:    request.Amount.Currency,
+		Method:      request.Method,
+		SourceToken: request.SourceToken,
+		Capture:     request.Capture,
+	})
+}
+
+func (a *HTTPAcquirer) Capture(ctx context.Context, request processor.CaptureRequest) (*processor.Result, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(request.Reference)+"/capture", OperationRequest{
+		PaymentID:   request.PaymentID,
+		AmountMinor: request.Amount.Amount,
+		Currency:    request.Amount.Currency,
+	})
+}
+
+func (a *HTTPAcquirer) Refund(ctx context.Context, request processor.RefundRequest) (*processor.Result, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(request.Reference)+"/refunds", OperationRequest{
+		PaymentID:   request.PaymentID,
+		RefundID:    request.RefundID,
+		AmountMinor: request.Amount.Amount,
+		Currency:    request.Amount.Currency,
+	})
+}
+
+func (a *HTTPAcquirer) Void(ctx context.Context, request processor.VoidRequest) (*processor.Result, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(request.Reference)+"/void", OperationRequest{
+		PaymentID: request.PaymentID,
+	})
+}
+
+// post sends body to path. Network failures and 5xx responses are reported as
+// processor.ErrUnavailable so callers can retry; declines come back as 200 or 402.
+func (a *HTTPAcquirer) post(ctx context.Context, path string, body interface{}) (*processor.Result, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := a.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", processor.ErrUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading response: %v", processor.ErrUnavailable, err)
+	}
+
+	if response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: acquirer returned %d: %s", processor.ErrUnavailable, response.StatusCode, responseBody)
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusPaymentRequired {
+		return nil, fmt.Errorf("acquirer returned %d for %s: %s", response.StatusCode, path, responseBody)
+	}
+
+	var result OperationResponse
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, fmt.Errorf("error decoding acquirer response: %w", err)
+	}
+
+	return &processor.Result{
+		Approved:      result.Approved,
+		Reference:     result.Reference,
+		DeclineCode:   result.DeclineCode,
+		DeclineReason: result.DeclineReason,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package acquirer
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/processor"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type simulatedOutcome struct {
+	approved    bool
+	unavailable bool
+	code        string
+	reason      string
+}
+
+// testCards mirrors the Stripe test cards and tokens, so the same fixtures work against both.
+var testCards = map[string]simulatedOutcome{
+	"4242424242424242":                    {approved: true},
+	"tok_visa":                            {approved: true},
+	"5555555555554444":                    {approved: true},
+	"tok_mastercard":                      {approved: true},
+	"4000000000000002":                    {code: "card_declined", reason: "generic decline"},
+	"tok_chargeDeclined":                  {code: "card_declined", reason: "generic decline"},
+	"4000000000009995":                    {code: processor.DeclineInsufficientFunds, reason: "insufficient funds"},
+	"tok_chargeDeclinedInsufficientFunds": {code: processor.DeclineInsufficientFunds, reason: "insufficient funds"},
+	"4000000000000069":                    {code: "expired_card", reason: "expired card"},
+	"tok_chargeDeclinedExpiredCard":       {code: "expired_card", reason: "expired card"},
+	"4000000000000127":                    {code: "incorrect_cvc", reason: "incorrect CVC"},
+	"4000000000000119":                    {unavailable: true},
+	"tok_chargeDeclinedProcessingError":   {unavailable: true},
+}
+
+// magicAmounts drive the outcome by the last three digits of the amount in minor
+// units (e.g. 10.51 BRL or 1051 JPY), for producers that cannot send a card token.
+var magicAmounts = map[int64]simulatedOutcome{
+	51: {code: processor.DeclineInsufficientFunds, reason: "insufficient funds"},
+	54: {code: "expired_card", reason: "expired card"},
+	57: {code: "card_declined", reason: "transaction not permitted"},
+	91: {unavailable: true},
+}
+
+type SimulatorConfig struct {
+	Latency   time.Duration
+	ErrorRate float64 // fraction of calls, between 0 and 1, that fail with processor.ErrUnavailable
+	Seed      int64
+}
+
+// Simulator is a deterministic processor for development and tests. Outcomes are
+// chosen by test card or magic amount; anything else is approved.
+type Simulator struct {
+	Config SimulatorConfig
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+func NewSimulator(config SimulatorConfig) *Simulator {
+	return &Simulator{
+		Config: config,
+		random: rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+func (s *Simulator) Authorize(ctx context.Context, request processor.AuthorizeRequest) (*processor.Result, error) {
+	if err := s.simulateCall(ctx); err != nil {
+		return nil, err
+	}
+
+	outcome, ok := testCards[request.SourceToken]
+	if !ok {
+		outcome, ok = magicAmounts[request.Amount.Amount%1000]
+	}
+	if !ok {
+		outcome = simulatedOutcome{approved: true}
+	}
+
+	if outcome.unavailable {
+		return nil, fmt.Errorf("%w: simulated processing error", processor.ErrUnavailable)
+	}
+	if !outcome.approved {
+		return &processor.Result{DeclineCode: outcome.code, DeclineReason: outcome.reason}, nil
+	}
+
+	return &processor.Result{Approved: true, Reference: "sim_" + uuid.NewString()}, nil
+}
+
+func (s *Simulator) Capture(ctx context.Context, request processor.CaptureRequest) (*processor.Result, error) {
+	return s.followUp(ctx, request.Reference)
+}
+
+func (s *Simulator) Refund(ctx context.Context, request processor.RefundRequest) (*processor.Result, error) {
+	return s.followUp(ctx, request.Reference)
+}
+
+func (s *Simulator) Void(ctx context.Context, request processor.VoidRequest) (*processor.Result, error) {
+	return s.followUp(ctx, request.Reference)
+}
+
+// followUp approves any operation on a payment the simulator authorized.
+func (s *Simulator) followUp(ctx context.Context, reference string) (*processor.Result, error) {
+	if err := s.simulateCall(ctx); err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		return &processor.Result{DeclineCode: "unknown_reference", DeclineReason: "payment has no processor reference"}, nil
+	}
+	return &processor.Result{Approved: true, Reference: reference}, nil
+}
+
+// simulateCall applies the configured latency and transient error rate.
+func (s *Simulator) simulateCall(ctx context.Context) error {
+	if s.Config.Latency > 0 {
+		select {
+		case <-time.After(s.Config.Latency):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", processor.ErrUnavailable, ctx.Err())
+		}
+	}
+
+	if s.Config.ErrorRate > 0 {
+		s.mu.Lock()
+		failed := s.random.Float64() < s.Config.ErrorRate
+		s.mu.Unlock()
+		if failed {
+			return fmt.Errorf("%w: simulated outage", processor.ErrUnavailable)
+		}
+	}
+
+	return nil
+}
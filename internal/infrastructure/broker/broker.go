@@ -0,0 +1,18 @@
+package broker
+
+import (
+	"context"
+	"gateway-payments/internal/domain/messaging"
+)
+
+// Client is implemented by every broker driver.
+type Client interface {
+	messaging.EventPublisher
+	messaging.EventSubscriber
+	SetupTopology() error
+	// Connected reports whether the broker can currently publish and deliver messages.
+	Connected() bool
+	// Shutdown stops consuming, waits for the handlers in flight until ctx ends and closes the client.
+	Shutdown(ctx context.Context) error
+	Close()
+}
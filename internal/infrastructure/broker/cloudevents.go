@@ -0,0 +1,317 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/messaging"
+	"log"
+	"strings"
+	"time"
+)
+
+// Event formats on the wire, selected by EVENT_FORMAT.
+const (
+	// EventFormatEnvelope publishes the event envelope as the JSON body
+	EventFormatEnvelope = "envelope"
+	// EventFormatStructured publishes a CloudEvents 1.0 JSON document, data included
+	EventFormatStructured = "cloudevents-structured"
+	// EventFormatBinary publishes the event data as the body and the CloudEvents attributes as ce- headers
+	EventFormatBinary = "cloudevents-binary"
+)
+
+const (
+	CloudEventsContentType  = "application/cloudevents+json"
+	cloudEventsSpecVersion  = "1.0"
+	cloudEventsHeaderPrefix = "ce-"
+)
+
+// cloudEvent is the structured mode document. The envelope version, correlation and
+// causation travel as the eventversion, correlationid and causationid extensions.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            *time.Time      `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	EventVersion    int             `json:"eventversion,omitempty"`
+	CorrelationID   string          `json:"correlationid,omitempty"`
+	CausationID     string          `json:"causationid,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// eventFormatClient converts published envelopes to the configured format and turns
+// incoming CloudEvents, in either mode, back into envelopes before calling handlers.
+type eventFormatClient struct {
+	Client
+	format       string
+	legacyFields bool
+}
+
+// NewEventFormatClient wraps client so it publishes events in format. Incoming messages
+// are decoded whatever their format, so producers can switch formats independently.
+// With legacyFields, envelopes also carry the top-level fields of bare events; CloudEvents never do.
+func NewEventFormatClient(client Client, format string, legacyFields bool) (Client, error) {
+	switch format {
+	case EventFormatEnvelope, EventFormatStructured, EventFormatBinary:
+	default:
+		return nil, fmt.Errorf("unknown event format %q", format)
+	}
+	return &eventFormatClient{Client: client, format: format, legacyFields: legacyFields}, nil
+}
+
+func (c *eventFormatClient) Publish(ctx context.Context, msg messaging.Message) error {
+	encoded, err := encodeCloudEvent(msg, c.format)
+	if err != nil {
+		return err
+	}
+	if c.format == EventFormatEnvelope && c.legacyFields {
+		if encoded, err = withLegacyFields(encoded); err != nil {
+			return err
+		}
+	}
+	return c.Client.Publish(ctx, encoded)
+}
+
+// withLegacyFields repeats the fields of bare events at the top level of an envelope
+// body. Messages that are not envelopes are published unchanged.
+func withLegacyFields(msg messaging.Message) (messaging.Message, error) {
+	envelope, ok := event.PeekEnvelope(msg.Body)
+	if !ok {
+		return msg, nil
+	}
+	body, err := envelope.MarshalLegacyJSON()
+	if err != nil {
+		return msg, fmt.Errorf("error encoding legacy fields of event %s: %w", envelope.ID, err)
+	}
+	msg.Body = body
+	return msg, nil
+}
+
+func (c *eventFormatClient) Subscribe(queueName, consumerName string, handler messaging.Handler, options messaging.SubscribeOptions) error {
+	return c.Client.Subscribe(queueName, consumerName, func(msg *messaging.Message) {
+		// Malformed CloudEvents are handed over as they are, so the handler rejects them
+		if err := decodeCloudEvent(msg); err != nil {
+			log.Printf("Error decoding CloudEvent %s from %s: %v", msg.ID, queueName, err)
+		}
+		handler(msg)
+	}, options)
+}
+
+// encodeCloudEvent converts a message carrying an event envelope to format. Other
+// messages, such as legacy events being replayed, are published unchanged.
+func encodeCloudEvent(msg messaging.Message, format string) (messaging.Message, error) {
+	if format == EventFormatEnvelope {
+		return msg, nil
+	}
+	envelope, ok := event.PeekEnvelope(msg.Body)
+	if !ok {
+		return msg, nil
+	}
+
+	ce := newCloudEvent(envelope)
+	if format == EventFormatStructured {
+		body, err := json.Marshal(ce)
+		if err != nil {
+			return msg, fmt.Errorf("error encoding CloudEvent %s: %w", envelope.ID, err)
+		}
+		msg.ContentType = CloudEventsContentType
+		msg.Body = body
+		return msg, nil
+	}
+
+	headers := make(map[string]interface{}, len(msg.Headers)+8)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	for key, value := range ce.binaryHeaders() {
+		headers[key] = value
+	}
+	msg.Headers = headers
+	msg.ContentType = ce.DataContentType
+	msg.Body = ce.Data
+	return msg, nil
+}
+
+func newCloudEvent(envelope *event.Envelope) *cloudEvent {
+	source := envelope.Producer
+	if source == "" {
+		source = event.Producer
+	}
+	ce := &cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              envelope.ID,
+		Source:          source,
+		Type:            envelope.Type,
+		DataContentType: "application/json",
+		EventVersion:    envelope.Version,
+		CorrelationID:   envelope.CorrelationID,
+		CausationID:     envelope.CausationID,
+		Data:            envelope.Data,
+	}
+	if !envelope.OccurredAt.IsZero() {
+		occurredAt := envelope.OccurredAt.UTC()
+		ce.Time = &occurredAt
+	}
+	return ce
+}
+
+func (ce *cloudEvent) binaryHeaders() map[string]interface{} {
+	headers := map[string]interface{}{
+		cloudEventsHeaderPrefix + "specversion": ce.SpecVersion,
+		cloudEventsHeaderPrefix + "id":          ce.ID,
+		cloudEventsHeaderPrefix + "source":      ce.Source,
+		cloudEventsHeaderPrefix + "type":        ce.Type,
+	}
+	if ce.Time != nil {
+		headers[cloudEventsHeaderPrefix+"time"] = ce.Time.Format(time.RFC3339Nano)
+	}
+	if ce.EventVersion != 0 {
+		headers[cloudEventsHeaderPrefix+"eventversion"] = int32(ce.EventVersion)
+	}
+	if ce.CorrelationID != "" {
+		headers[cloudEventsHeaderPrefix+"correlationid"] = ce.CorrelationID
+	}
+	if ce.CausationID != "" {
+		headers[cloudEventsHeaderPrefix+"causationid"] = ce.CausationID
+	}
+	return headers
+}
+
+// decodeCloudEvent detects a CloudEvent by its content type (structured mode) or its
+// ce-specversion header (binary mode) and replaces it in msg with the equivalent envelope.
+func decodeCloudEvent(msg *messaging.Message) error {
+	var ce *cloudEvent
+	switch {
+	case strings.HasPrefix(msg.ContentType, CloudEventsContentType):
+		ce = &cloudEvent{}
+		if err := json.Unmarshal(msg.Body, ce); err != nil {
+			return fmt.Errorf("invalid structured CloudEvent: %w", err)
+		}
+	case msg.Headers[cloudEventsHeaderPrefix+"specversion"] != nil:
+		var err error
+		if ce, err = binaryCloudEvent(msg); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	if ce.SpecVersion != cloudEventsSpecVersion {
+		return fmt.Errorf("unsupported CloudEvents specversion %q", ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Type == "" {
+		return fmt.Errorf("CloudEvent without id or type")
+	}
+
+	body, err := json.Marshal(ce.envelope())
+	if err != nil {
+		return fmt.Errorf("error encoding envelope of CloudEvent %s: %w", ce.ID, err)
+	}
+	msg.ContentType = "application/json"
+	msg.Body = body
+	if msg.ID == "" {
+		msg.ID = ce.ID
+	}
+	return nil
+}
+
+// binaryCloudEvent reads the ce- headers of msg, removing them so republished copies
+// are encoded again from the envelope.
+func binaryCloudEvent(msg *messaging.Message) (*cloudEvent, error) {
+	attributes := make(map[string]interface{})
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for key, value := range msg.Headers {
+		if name, ok := strings.CutPrefix(key, cloudEventsHeaderPrefix); ok {
+			attributes[name] = value
+			continue
+		}
+		headers[key] = value
+	}
+
+	ce := &cloudEvent{
+		SpecVersion:     stringAttribute(attributes["specversion"]),
+		ID:              stringAttribute(attributes["id"]),
+		Source:          stringAttribute(attributes["source"]),
+		Type:            stringAttribute(attributes["type"]),
+		DataContentType: msg.ContentType,
+		CorrelationID:   stringAttribute(attributes["correlationid"]),
+		CausationID:     stringAttribute(attributes["causationid"]),
+		Data:            json.RawMessage(msg.Body),
+	}
+	if value := stringAttribute(attributes["time"]); value != "" {
+		occurredAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ce-time %q: %w", value, err)
+		}
+		ce.Time = &occurredAt
+	}
+	if version, ok := attributes["eventversion"]; ok {
+		number, err := intAttribute(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ce-eventversion: %w", err)
+		}
+		ce.EventVersion = number
+	}
+
+	msg.Headers = headers
+	return ce, nil
+}
+
+// envelope converts the CloudEvent back to an envelope. Events from producers that do
+// not set eventversion are taken as version 1.
+func (ce *cloudEvent) envelope() *event.Envelope {
+	envelope := &event.Envelope{
+		ID:            ce.ID,
+		Type:          ce.Type,
+		Version:       ce.EventVersion,
+		CorrelationID: ce.CorrelationID,
+		CausationID:   ce.CausationID,
+		Producer:      ce.Source,
+		Data:          ce.Data,
+	}
+	if envelope.Version == 0 {
+		envelope.Version = 1
+	}
+	if ce.Time != nil {
+		envelope.OccurredAt = *ce.Time
+	}
+	if envelope.Data == nil {
+		envelope.Data = json.RawMessage("null")
+	}
+	return envelope
+}
+
+func stringAttribute(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func intAttribute(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case string:
+		var number int
+		_, err := fmt.Sscan(v, &number)
+		return number, err
+	}
+	return 0, fmt.Errorf("unexpected value %v", value)
+}
@@ -0,0 +1,287 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/messaging"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testEnvelope(t *testing.T) (*event.Envelope, messaging.Message) {
+	t.Helper()
+	envelope := &event.Envelope{
+		ID:            "event-1",
+		Type:          event.TypePaymentProcessed,
+		Version:       1,
+		OccurredAt:    time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC),
+		CorrelationID: "correlation-1",
+		CausationID:   "cause-1",
+		Producer:      event.Producer,
+		Data:          json.RawMessage(`{"payment_id":"payment-1","status":"APPROVED"}`),
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelope, messaging.Message{
+		ID:          "event-1",
+		Exchange:    event.PaymentsExchange,
+		RoutingKey:  event.TypePaymentProcessed,
+		ContentType: "application/json",
+		Headers:     map[string]interface{}{"x-custom": "value"},
+		Body:        body,
+	}
+}
+
+func decodedEnvelope(t *testing.T, msg messaging.Message) *event.Envelope {
+	t.Helper()
+	envelope, ok := event.PeekEnvelope(msg.Body)
+	if !ok {
+		t.Fatalf("decoded body is not an envelope: %s", msg.Body)
+	}
+	return envelope
+}
+
+func TestCloudEventRoundTrip(t *testing.T) {
+	for _, format := range []string{EventFormatEnvelope, EventFormatStructured, EventFormatBinary} {
+		t.Run(format, func(t *testing.T) {
+			want, msg := testEnvelope(t)
+
+			encoded, err := encodeCloudEvent(msg, format)
+			if err != nil {
+				t.Fatalf("encodeCloudEvent() = %v", err)
+			}
+			if err := decodeCloudEvent(&encoded); err != nil {
+				t.Fatalf("decodeCloudEvent() = %v", err)
+			}
+
+			got := decodedEnvelope(t, encoded)
+			if got.ID != want.ID || got.Type != want.Type || got.Version != want.Version || got.CorrelationID != want.CorrelationID ||
+				got.CausationID != want.CausationID || got.Producer != want.Producer || !got.OccurredAt.Equal(want.OccurredAt) {
+				t.Errorf("envelope = %+v, want %+v", got, want)
+			}
+			if string(got.Data) != string(want.Data) {
+				t.Errorf("data = %s, want %s", got.Data, want.Data)
+			}
+			if encoded.ContentType != "application/json" || encoded.Header("x-custom") != "value" {
+				t.Errorf("content type %q and headers %v after decoding", encoded.ContentType, encoded.Headers)
+			}
+			for key := range encoded.Headers {
+				if key != "x-custom" {
+					t.Errorf("header %s kept after decoding", key)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeStructuredCloudEvent(t *testing.T) {
+	_, msg := testEnvelope(t)
+
+	encoded, err := encodeCloudEvent(msg, EventFormatStructured)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if encoded.ContentType != CloudEventsContentType {
+		t.Errorf("content type = %q, want %q", encoded.ContentType, CloudEventsContentType)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(encoded.Body, &document); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"specversion":     "1.0",
+		"id":              "event-1",
+		"source":          event.Producer,
+		"type":            event.TypePaymentProcessed,
+		"time":            "2024-05-01T10:00:00.123Z",
+		"datacontenttype": "application/json",
+		"eventversion":    float64(1),
+		"correlationid":   "correlation-1",
+		"causationid":     "cause-1",
+		"data":            map[string]interface{}{"payment_id": "payment-1", "status": "APPROVED"},
+	}
+	if !reflect.DeepEqual(document, want) {
+		t.Errorf("structured CloudEvent = %v, want %v", document, want)
+	}
+}
+
+func TestEncodeBinaryCloudEvent(t *testing.T) {
+	envelope, msg := testEnvelope(t)
+
+	encoded, err := encodeCloudEvent(msg, EventFormatBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"x-custom":         "value",
+		"ce-specversion":   "1.0",
+		"ce-id":            "event-1",
+		"ce-source":        event.Producer,
+		"ce-type":          event.TypePaymentProcessed,
+		"ce-time":          "2024-05-01T10:00:00.123Z",
+		"ce-eventversion":  int32(1),
+		"ce-correlationid": "correlation-1",
+		"ce-causationid":   "cause-1",
+	}
+	if !reflect.DeepEqual(encoded.Headers, want) {
+		t.Errorf("headers = %v, want %v", encoded.Headers, want)
+	}
+	// datacontenttype maps to the content type of the message, and the body is the data alone
+	if encoded.ContentType != "application/json" || string(encoded.Body) != string(envelope.Data) {
+		t.Errorf("binary CloudEvent has content type %q and body %s", encoded.ContentType, encoded.Body)
+	}
+	if msg.Header("ce-id") != "" {
+		t.Error("encoding changed the headers of the original message")
+	}
+}
+
+func TestEncodeCloudEventLeavesLegacyEventsAlone(t *testing.T) {
+	msg := messaging.Message{ContentType: "application/json", Body: []byte(`{"event":"payment.requested","order_id":"order-1"}`)}
+
+	for _, format := range []string{EventFormatStructured, EventFormatBinary} {
+		encoded, err := encodeCloudEvent(msg, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded.Body) != string(msg.Body) || encoded.ContentType != msg.ContentType || len(encoded.Headers) != 0 {
+			t.Errorf("%s: legacy event encoded as %+v", format, encoded)
+		}
+	}
+}
+
+func TestDecodeCloudEventFromOtherProducers(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     messaging.Message
+		want    event.Envelope
+		wantErr bool
+	}{
+		{
+			name: "structured without extensions",
+			msg: messaging.Message{
+				ContentType: CloudEventsContentType + "; charset=utf-8",
+				Body:        []byte(`{"specversion":"1.0","id":"event-1","source":"checkout","type":"payment.requested","data":{"order_id":"order-1"}}`),
+			},
+			want: event.Envelope{ID: "event-1", Type: "payment.requested", Version: 1, Producer: "checkout", Data: json.RawMessage(`{"order_id":"order-1"}`)},
+		},
+		{
+			name: "binary with string eventversion",
+			msg: messaging.Message{
+				ContentType: "application/json",
+				Headers: map[string]interface{}{
+					"ce-specversion":  []byte("1.0"),
+					"ce-id":           "event-1",
+					"ce-source":       "checkout",
+					"ce-type":         "payment.requested",
+					"ce-time":         "2024-05-01T10:00:00Z",
+					"ce-eventversion": "2",
+				},
+				Body: []byte(`{"order_id":"order-1"}`),
+			},
+			want: event.Envelope{ID: "event-1", Type: "payment.requested", Version: 2, Producer: "checkout", OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Data: json.RawMessage(`{"order_id":"order-1"}`)},
+		},
+		{
+			name: "binary without data",
+			msg: messaging.Message{
+				Headers: map[string]interface{}{"ce-specversion": "1.0", "ce-id": "event-1", "ce-source": "checkout", "ce-type": "payment.requested"},
+			},
+			want: event.Envelope{ID: "event-1", Type: "payment.requested", Version: 1, Producer: "checkout", Data: json.RawMessage("null")},
+		},
+		{
+			name:    "unsupported specversion",
+			msg:     messaging.Message{ContentType: CloudEventsContentType, Body: []byte(`{"specversion":"0.3","id":"event-1","type":"payment.requested"}`)},
+			wantErr: true,
+		},
+		{
+			name:    "without type",
+			msg:     messaging.Message{Headers: map[string]interface{}{"ce-specversion": "1.0", "ce-id": "event-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			msg:     messaging.Message{Headers: map[string]interface{}{"ce-specversion": "1.0", "ce-id": "event-1", "ce-type": "payment.requested", "ce-time": "yesterday"}},
+			wantErr: true,
+		},
+		{
+			name:    "malformed structured event",
+			msg:     messaging.Message{ContentType: CloudEventsContentType, Body: []byte(`{"specversion":`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			err := decodeCloudEvent(&msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("decodeCloudEvent() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCloudEvent() = %v", err)
+			}
+			got := decodedEnvelope(t, msg)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("envelope = %+v, want %+v", *got, tt.want)
+			}
+			// The message takes the ID of the event when it has none
+			if msg.ID != "event-1" {
+				t.Errorf("message ID = %q, want event-1", msg.ID)
+			}
+		})
+	}
+}
+
+func TestDecodeCloudEventIgnoresOtherMessages(t *testing.T) {
+	_, msg := testEnvelope(t)
+	body := string(msg.Body)
+
+	if err := decodeCloudEvent(&msg); err != nil {
+		t.Fatalf("decodeCloudEvent() = %v", err)
+	}
+	if string(msg.Body) != body {
+		t.Errorf("envelope changed to %s", msg.Body)
+	}
+}
+
+func TestEventFormatClientLegacyFields(t *testing.T) {
+	tests := []struct {
+		format       string
+		legacyFields bool
+		want         bool
+	}{
+		{format: EventFormatEnvelope, legacyFields: true, want: true},
+		{format: EventFormatEnvelope},
+		{format: EventFormatStructured, legacyFields: true},
+	}
+
+	for _, tt := range tests {
+		inner := newTestBroker(t)
+		deliveries := collect(t, inner, "payment.processed.queue", ack)
+		client, err := NewEventFormatClient(inner, tt.format, tt.legacyFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, msg := testEnvelope(t)
+		if err := client.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(receive(t, deliveries).Body, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if got := fields["event"] == event.TypePaymentProcessed && fields["payment_id"] == "payment-1"; got != tt.want {
+			t.Errorf("%s with legacy fields %v: published %v", tt.format, tt.legacyFields, fields)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/messaging"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBrokerClosed is returned when using an in-memory broker after Close.
+var ErrBrokerClosed = errors.New("broker closed")
+
+// InMemoryBroker is a process-local broker with the same routing, ack/nack and
+// dead-letter semantics as the RabbitMQ topology, for tests and local development.
+// Messages are lost when the process exits.
+type InMemoryBroker struct {
+	mu        sync.Mutex
+	exchanges map[string]string
+	bindings  []BindingSpec
+	queues    map[string]*memoryQueue
+	pools     []*workerPool
+	stopping  bool
+	closed    bool
+}
+
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		exchanges: make(map[string]string),
+		queues:    make(map[string]*memoryQueue),
+	}
+}
+
+func (b *InMemoryBroker) SetupTopology() error {
+	topology := PaymentsTopology()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, exchange := range topology.Exchanges {
+		b.exchanges[exchange.Name] = exchange.Kind
+	}
+	for _, queue := range topology.Queues {
+		b.declareQueue(queue)
+	}
+	for _, binding := range topology.Bindings {
+		if _, ok := b.queues[binding.Queue]; !ok {
+			return fmt.Errorf("cannot bind unknown queue %s", binding.Queue)
+		}
+		if _, ok := b.exchanges[binding.Exchange]; !ok {
+			return fmt.Errorf("cannot bind to unknown exchange %s", binding.Exchange)
+		}
+		b.bindings = append(b.bindings, binding)
+	}
+
+	return nil
+}
+
+// declareQueue must be called with b.mu held. Redeclaring a queue keeps its messages.
+func (b *InMemoryBroker) declareQueue(spec QueueSpec) *memoryQueue {
+	if queue, ok := b.queues[spec.Name]; ok {
+		return queue
+	}
+	queue := &memoryQueue{name: spec.Name, args: spec.Args, ttl: messageTTL(spec.Args), ready: make(chan struct{}, 1)}
+	queue.expire = func(msg messaging.Message) {
+		b.deadLetter(queue, msg, "expired")
+	}
+	b.queues[spec.Name] = queue
+	return queue
+}
+
+func (b *InMemoryBroker) Connected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.closed
+}
+
+func (b *InMemoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, queue := range b.queues {
+		queue.close()
+	}
+}
+
+func (b *InMemoryBroker) Publish(ctx context.Context, msg messaging.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg.Headers = copyHeaders(msg.Headers)
+
+	// Publishes are mandatory, as with RabbitMQ
+	queues := b.route(msg.Exchange, msg.RoutingKey)
+	if len(queues) == 0 {
+		return &messaging.ErrUnroutable{Exchange: msg.Exchange, RoutingKey: msg.RoutingKey, Reason: "NO_ROUTE"}
+	}
+	for _, queue := range queues {
+		queue.push(msg)
+	}
+	return nil
+}
+
+// route must be called with b.mu held. The default exchange ("") routes by queue name.
+func (b *InMemoryBroker) route(exchange, routingKey string) []*memoryQueue {
+	if exchange == "" {
+		if queue, ok := b.queues[routingKey]; ok {
+			return []*memoryQueue{queue}
+		}
+		return nil
+	}
+
+	kind := b.exchanges[exchange]
+	seen := make(map[string]bool)
+	var queues []*memoryQueue
+	for _, binding := range b.bindings {
+		if binding.Exchange != exchange || seen[binding.Queue] {
+			continue
+		}
+		matched := binding.RoutingKey == routingKey
+		if kind == "topic" {
+			matched = topicMatches(binding.RoutingKey, routingKey)
+		} else if kind == "fanout" {
+			matched = true
+		}
+		if matched {
+			seen[binding.Queue] = true
+			queues = append(queues, b.queues[binding.Queue])
+		}
+	}
+	return queues
+}
+
+// Subscribe handles the messages of queue with options.Workers concurrent handlers.
+// Messages stay in the queue until a worker is free, so Prefetch has no effect.
+func (b *InMemoryBroker) Subscribe(queueName, consumerName string, handler messaging.Handler, options messaging.SubscribeOptions) error {
+	options = options.WithDefaults()
+
+	b.mu.Lock()
+	queue, ok := b.queues[queueName]
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	if b.stopping {
+		b.mu.Unlock()
+		return ErrShuttingDown
+	}
+	if !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("queue %s not found", queueName)
+	}
+	pool := newWorkerPool(options.Workers, handler)
+	b.pools = append(b.pools, pool)
+	b.mu.Unlock()
+
+	go func() {
+		defer pool.Close()
+		for {
+			msg, ok := queue.pop()
+			if !ok {
+				log.Printf("In-memory consumer %s stopped", consumerName)
+				return
+			}
+			pool.Submit(msg, &memoryAcknowledger{broker: b, queue: queue, msg: msg})
+			if pool.Stopped() {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops delivering messages, waits for the handlers in flight and closes the
+// broker. Messages still being handled when ctx ends are requeued, and lost on exit.
+func (b *InMemoryBroker) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.stopping = true
+	pools := append([]*workerPool(nil), b.pools...)
+	b.mu.Unlock()
+
+	var drainErr error
+	for _, pool := range pools {
+		if err := pool.Drain(ctx); err != nil {
+			drainErr = err
+		}
+	}
+
+	b.Close()
+	return drainErr
+}
+
+// deadLetter routes a rejected message to the queue's dead-letter exchange, if any,
+// recording the rejection in the x-death header like RabbitMQ.
+func (b *InMemoryBroker) deadLetter(queue *memoryQueue, msg messaging.Message, reason string) {
+	exchange, ok := queue.args[ArgDeadLetterExchange].(string)
+	if !ok {
+		return
+	}
+	routingKey, ok := queue.args[ArgDeadLetterRoutingKey].(string)
+	if !ok {
+		routingKey = msg.RoutingKey
+	}
+
+	msg.Headers = copyHeaders(msg.Headers)
+	deaths, _ := msg.Headers["x-death"].([]interface{})
+	msg.Headers["x-death"] = append([]interface{}{map[string]interface{}{
+		"queue":        queue.name,
+		"reason":       reason,
+		"exchange":     msg.Exchange,
+		"routing-keys": []interface{}{msg.RoutingKey},
+		"time":         time.Now(),
+		"count":        int64(1),
+	}}, deaths...)
+	msg.Exchange = exchange
+	msg.RoutingKey = routingKey
+	msg.Redelivered = false
+
+	if err := b.Publish(context.Background(), msg); err != nil {
+		log.Printf("Error dead-lettering message from %s: %v", queue.name, err)
+	}
+}
+
+type memoryAcknowledger struct {
+	broker *InMemoryBroker
+	queue  *memoryQueue
+	msg    messaging.Message
+
+	mu      sync.Mutex
+	settled bool
+}
+
+func (a *memoryAcknowledger) settle() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.settled {
+		return messaging.ErrAlreadyAcknowledged
+	}
+	a.settled = true
+	return nil
+}
+
+func (a *memoryAcknowledger) Ack() error {
+	return a.settle()
+}
+
+func (a *memoryAcknowledger) Nack(requeue bool) error {
+	if err := a.settle(); err != nil {
+		return err
+	}
+	if requeue {
+		msg := a.msg
+		msg.Redelivered = true
+		a.queue.push(msg)
+		return nil
+	}
+	a.broker.deadLetter(a.queue, a.msg, "rejected")
+	return nil
+}
+
+type memoryQueue struct {
+	name   string
+	args   map[string]interface{}
+	ttl    time.Duration
+	expire func(msg messaging.Message)
+
+	mu       sync.Mutex
+	messages []queuedMessage
+	sequence uint64
+	ready    chan struct{}
+	closed   bool
+}
+
+type queuedMessage struct {
+	sequence uint64
+	msg      messaging.Message
+}
+
+func (q *memoryQueue) push(msg messaging.Message) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.sequence++
+	sequence := q.sequence
+	q.messages = append(q.messages, queuedMessage{sequence: sequence, msg: msg})
+	q.mu.Unlock()
+	q.signal()
+
+	if q.ttl > 0 {
+		time.AfterFunc(q.ttl, func() {
+			if q.remove(sequence) {
+				q.expire(msg)
+			}
+		})
+	}
+}
+
+// remove drops a message that was not consumed yet, reporting whether it was still queued.
+func (q *memoryQueue) remove(sequence uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	for i, queued := range q.messages {
+		if queued.sequence == sequence {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// pop blocks until a message is available or the queue is closed.
+func (q *memoryQueue) pop() (messaging.Message, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return messaging.Message{}, false
+		}
+		if len(q.messages) > 0 {
+			msg := q.messages[0].msg
+			q.messages = q.messages[1:]
+			more := len(q.messages) > 0
+			q.mu.Unlock()
+			if more {
+				// Wake another consumer of the same queue
+				q.signal()
+			}
+			return msg, true
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+func (q *memoryQueue) signal() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *memoryQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	close(q.ready)
+}
+
+// messageTTL reads the x-message-ttl argument, in milliseconds.
+func messageTTL(args map[string]interface{}) time.Duration {
+	var ttl int64
+	switch value := args[ArgMessageTTL].(type) {
+	case int:
+		ttl = int64(value)
+	case int32:
+		ttl = int64(value)
+	case int64:
+		ttl = value
+	}
+	return time.Duration(ttl) * time.Millisecond
+}
+
+func copyHeaders(headers map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
+
+// topicMatches implements AMQP topic matching: "*" matches one word and "#" zero or more.
+func topicMatches(pattern, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	}
+	return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+}
@@ -0,0 +1,216 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/messaging"
+	"testing"
+	"time"
+)
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		routingKey string
+		want       bool
+	}{
+		{pattern: "payment.requested", routingKey: "payment.requested", want: true},
+		{pattern: "payment.requested", routingKey: "payment.processed", want: false},
+		{pattern: "payment.*", routingKey: "payment.requested", want: true},
+		{pattern: "payment.*", routingKey: "payment", want: false},
+		{pattern: "payment.*", routingKey: "payment.requested.v2", want: false},
+		{pattern: "*.requested", routingKey: "payment.requested", want: true},
+		{pattern: "payment.#", routingKey: "payment", want: true},
+		{pattern: "payment.#", routingKey: "payment.requested.v2", want: true},
+		{pattern: "#", routingKey: "anything.at.all", want: true},
+		{pattern: "#.dead", routingKey: "payment.dead", want: true},
+		{pattern: "#.dead", routingKey: "payment.dead.letter", want: false},
+		{pattern: "payment.#.v2", routingKey: "payment.requested.retry.v2", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := topicMatches(tt.pattern, tt.routingKey); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.pattern, tt.routingKey, got, tt.want)
+		}
+	}
+}
+
+func newTestBroker(t *testing.T) *InMemoryBroker {
+	t.Helper()
+	broker := NewInMemoryBroker()
+	if err := broker.SetupTopology(); err != nil {
+		t.Fatalf("SetupTopology() = %v", err)
+	}
+	t.Cleanup(broker.Close)
+	return broker
+}
+
+// collect subscribes to queue, passing every delivery to handle before sending it on the returned channel.
+func collect(t *testing.T, broker *InMemoryBroker, queue string, handle func(msg *messaging.Message)) <-chan *messaging.Message {
+	t.Helper()
+	deliveries := make(chan *messaging.Message, 10)
+	err := broker.Subscribe(queue, "test", func(msg *messaging.Message) {
+		handle(msg)
+		deliveries <- msg
+	}, messaging.SubscribeOptions{})
+	if err != nil {
+		t.Fatalf("Subscribe(%s) = %v", queue, err)
+	}
+	return deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan *messaging.Message) *messaging.Message {
+	t.Helper()
+	select {
+	case msg := <-deliveries:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message delivered")
+		return nil
+	}
+}
+
+func ack(msg *messaging.Message) {
+	msg.Ack()
+}
+
+func TestInMemoryBrokerRoutesByTopology(t *testing.T) {
+	broker := newTestBroker(t)
+	processed := collect(t, broker, "payment.processed.queue", ack)
+
+	err := broker.Publish(context.Background(), messaging.Message{
+		ID:         "message-1",
+		Exchange:   "payments.exchange",
+		RoutingKey: "payment.processed",
+		Headers:    map[string]interface{}{"x-custom": "value"},
+		Body:       []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	msg := receive(t, processed)
+	if msg.ID != "message-1" || msg.Header("x-custom") != "value" || msg.Timestamp.IsZero() {
+		t.Errorf("delivered %+v", msg)
+	}
+}
+
+func TestInMemoryBrokerRequeue(t *testing.T) {
+	broker := newTestBroker(t)
+	first := true
+	deliveries := collect(t, broker, "payment.processed.queue", func(msg *messaging.Message) {
+		if first {
+			first = false
+			msg.Nack(true)
+			return
+		}
+		msg.Ack()
+	})
+
+	broker.Publish(context.Background(), messaging.Message{ID: "message-1", Exchange: "payments.exchange", RoutingKey: "payment.processed"})
+
+	if msg := receive(t, deliveries); msg.Redelivered {
+		t.Error("first delivery is marked as redelivered")
+	}
+	msg := receive(t, deliveries)
+	if msg.ID != "message-1" || !msg.Redelivered {
+		t.Errorf("redelivered %+v", msg)
+	}
+	if err := msg.Ack(); !errors.Is(err, messaging.ErrAlreadyAcknowledged) {
+		t.Errorf("second Ack() = %v, want ErrAlreadyAcknowledged", err)
+	}
+}
+
+func TestInMemoryBrokerDeadLettersRejectedMessages(t *testing.T) {
+	broker := newTestBroker(t)
+	collect(t, broker, PaymentRequestedQueue, func(msg *messaging.Message) {
+		msg.Nack(false)
+	})
+	deadLetters := collect(t, broker, "payments.dlq", ack)
+
+	broker.Publish(context.Background(), messaging.Message{ID: "message-1", Exchange: "payments.exchange", RoutingKey: "payment.requested"})
+
+	msg := receive(t, deadLetters)
+	if msg.ID != "message-1" || msg.RoutingKey != "payment.dead" {
+		t.Errorf("dead-lettered %s with routing key %s", msg.ID, msg.RoutingKey)
+	}
+	deaths, _ := msg.Headers[messaging.HeaderDeath].([]interface{})
+	if len(deaths) != 1 {
+		t.Fatalf("x-death = %v, want one entry", msg.Headers[messaging.HeaderDeath])
+	}
+	death := deaths[0].(map[string]interface{})
+	if death["queue"] != PaymentRequestedQueue || death["reason"] != "rejected" {
+		t.Errorf("x-death = %v", death)
+	}
+}
+
+func TestInMemoryBrokerRejectsUnroutableMessages(t *testing.T) {
+	broker := newTestBroker(t)
+
+	err := broker.Publish(context.Background(), messaging.Message{Exchange: "payments.exchange", RoutingKey: "payment.unknown"})
+	var unroutable *messaging.ErrUnroutable
+	if !errors.As(err, &unroutable) {
+		t.Errorf("Publish() = %v, want *messaging.ErrUnroutable", err)
+	}
+
+	// The default exchange routes by queue name
+	err = broker.Publish(context.Background(), messaging.Message{RoutingKey: "payment.processed.queue"})
+	if err != nil {
+		t.Errorf("Publish() to a queue by name = %v", err)
+	}
+}
+
+func TestInMemoryBrokerExpiresMessagesToDeadLetterExchange(t *testing.T) {
+	broker := NewInMemoryBroker()
+	t.Cleanup(broker.Close)
+	broker.mu.Lock()
+	broker.declareQueue(QueueSpec{Name: "target"})
+	broker.declareQueue(QueueSpec{Name: "delay", Args: map[string]interface{}{
+		ArgMessageTTL:           int32(10),
+		ArgDeadLetterExchange:   "",
+		ArgDeadLetterRoutingKey: "target",
+	}})
+	broker.mu.Unlock()
+	deliveries := collect(t, broker, "target", ack)
+
+	// Like the retry tiers: the message waits in delay and comes back to target
+	broker.Publish(context.Background(), messaging.Message{ID: "message-1", RoutingKey: "delay"})
+
+	msg := receive(t, deliveries)
+	deaths, _ := msg.Headers[messaging.HeaderDeath].([]interface{})
+	if msg.ID != "message-1" || len(deaths) != 1 || deaths[0].(map[string]interface{})["reason"] != "expired" {
+		t.Errorf("delivered %s with x-death %v", msg.ID, msg.Headers[messaging.HeaderDeath])
+	}
+}
+
+func TestInMemoryBrokerShutdown(t *testing.T) {
+	broker := newTestBroker(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	broker.Subscribe("payment.processed.queue", "test", func(msg *messaging.Message) {
+		close(started)
+		<-release
+		msg.Ack()
+	}, messaging.SubscribeOptions{})
+
+	broker.Publish(context.Background(), messaging.Message{ID: "message-1", Exchange: "payments.exchange", RoutingKey: "payment.processed"})
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		done <- broker.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown() = %v before the handler finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if err := broker.Publish(context.Background(), messaging.Message{RoutingKey: "payment.processed.queue"}); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Publish() after Shutdown = %v, want ErrBrokerClosed", err)
+	}
+}
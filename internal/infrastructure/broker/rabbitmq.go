@@ -2,166 +2,683 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/messaging"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = 30 * time.Second
+
+	// confirmTimeout bounds how long Publish waits for a confirm when ctx has no earlier deadline
+	confirmTimeout = 5 * time.Second
+
+	// headerPublishID identifies each publish, so a returned message is matched to the
+	// publish that sent it even when message IDs are empty or reused
+	headerPublishID = "x-publish-id"
+)
+
+// ErrNotConnected is returned while the client is reconnecting to RabbitMQ.
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+// RabbitMQClient keeps a connection to RabbitMQ. When the connection or its channels
+// are closed by the broker it reconnects with exponential backoff, declares the
+// topology again and re-registers every consumer.
+//
+// Messages are published on a dedicated channel in confirm mode with mandatory set,
+// so Publish only succeeds once the broker has routed and accepted the message.
 type RabbitMQClient struct {
-	conn *amqp.Connection
-	ch   *amqp.Channel
+	url string
+
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	ch          *amqp.Channel
+	pubCh       *amqp.Channel
+	returns     *returnTracker
+	connected   bool
+	hasTopology bool
+	consumers   []consumer
+	pools       map[*workerPool]struct{}
+	stopping    bool
+
+	// subscribing keeps Qos and Consume together, since Qos applies to the next consumer of the channel
+	subscribing sync.Mutex
+
+	closing chan struct{}
+	closed  sync.Once
+}
+
+type consumer struct {
+	queue   string
+	name    string
+	handler messaging.Handler
+	options messaging.SubscribeOptions
 }
 
 func NewRabbitMQClient(url string) (*RabbitMQClient, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
+	c := &RabbitMQClient{url: url, pools: make(map[*workerPool]struct{}), closing: make(chan struct{})}
+	if err := c.connect(); err != nil {
 		return nil, err
 	}
 
+	go c.watch()
+
+	return c, nil
+}
+
+// connect dials RabbitMQ and opens the consumer channel and the confirm-mode publisher channel.
+func (c *RabbitMQClient) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return err
+	}
+
 	ch, err := conn.Channel()
 	if err != nil {
-		return nil, err
+		conn.Close()
+		return err
+	}
+
+	pubCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := pubCh.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("error enabling publisher confirms: %w", err)
+	}
+	returns := newReturnTracker(pubCh.NotifyReturn(make(chan amqp.Return, 64)))
+
+	c.mu.Lock()
+	c.conn = conn
+	c.ch = ch
+	c.pubCh = pubCh
+	c.returns = returns
+	c.connected = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+// watch waits for the connection or channel to close and reconnects until Close is called.
+func (c *RabbitMQClient) watch() {
+	for {
+		c.mu.RLock()
+		connClosed := c.conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := c.ch.NotifyClose(make(chan *amqp.Error, 1))
+		pubClosed := c.pubCh.NotifyClose(make(chan *amqp.Error, 1))
+		c.mu.RUnlock()
+
+		var reason *amqp.Error
+		select {
+		case <-c.closing:
+			return
+		case reason = <-connClosed:
+		case reason = <-chClosed:
+		case reason = <-pubClosed:
+		}
+
+		c.mu.Lock()
+		c.connected = false
+		c.mu.Unlock()
+
+		select {
+		case <-c.closing:
+			return
+		default:
+		}
+		log.Printf("RabbitMQ connection lost: %v", reason)
+
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+// reconnect retries until the connection is restored, returning false if the client was closed meanwhile.
+func (c *RabbitMQClient) reconnect() bool {
+	backoff := reconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.closing:
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := c.restore()
+		if err == nil {
+			log.Printf("RabbitMQ connection restored after %d attempt(s)", attempt)
+			return true
+		}
+		log.Printf("Error reconnecting to RabbitMQ (attempt %d), retrying in %s: %v", attempt, backoff, err)
+
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// restore reconnects, declares the topology again and re-registers the consumers.
+func (c *RabbitMQClient) restore() error {
+	c.mu.RLock()
+	oldConn := c.conn
+	hasTopology := c.hasTopology
+	consumers := append([]consumer(nil), c.consumers...)
+	if c.stopping {
+		consumers = nil
 	}
+	c.mu.RUnlock()
 
-	return &RabbitMQClient{conn: conn, ch: ch}, nil
+	if oldConn != nil && !oldConn.IsClosed() {
+		oldConn.Close()
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		c.mu.Lock()
+		c.connected = false
+		c.conn.Close()
+		c.mu.Unlock()
+		return err
+	}
+
+	if hasTopology {
+		if err := c.declareTopology(); err != nil {
+			return fail(fmt.Errorf("error declaring topology: %w", err))
+		}
+	}
+	for _, consumer := range consumers {
+		if err := c.consume(consumer); err != nil {
+			return fail(fmt.Errorf("error re-registering consumer %s: %w", consumer.name, err))
+		}
+	}
+
+	return nil
+}
+
+// Connected reports whether the client currently holds an open connection.
+func (c *RabbitMQClient) Connected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
 }
 
 func (c *RabbitMQClient) Close() {
-	c.ch.Close()
-	c.conn.Close()
+	c.closed.Do(func() {
+		close(c.closing)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.connected = false
+		c.ch.Close()
+		c.pubCh.Close()
+		c.conn.Close()
+	})
+}
+
+func (c *RabbitMQClient) channel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.connected {
+		return nil, ErrNotConnected
+	}
+	return c.ch, nil
 }
 
-func (c *RabbitMQClient) Publish(ctx context.Context, exchange, routingKey string, body interface{}) error {
-	jsonBody, err := json.Marshal(body)
+func (c *RabbitMQClient) publisher() (*amqp.Channel, *returnTracker, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.connected {
+		return nil, nil, ErrNotConnected
+	}
+	return c.pubCh, c.returns, nil
+}
+
+// Publish sends msg and waits for the broker to confirm it. It returns a
+// *messaging.ErrUnroutable when no queue matched, messaging.ErrNacked when the broker
+// refused the message and messaging.ErrConfirmTimeout when no confirm arrived in time.
+func (c *RabbitMQClient) Publish(ctx context.Context, msg messaging.Message) error {
+	ch, returns, err := c.publisher()
 	if err != nil {
 		return err
 	}
 
-	return c.ch.PublishWithContext(ctx,
-		exchange,   // exchange
-		routingKey, // routing key
-		false,      // mandatory
-		false,      // immediate
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	publishID := uuid.NewString()
+	headers := make(amqp.Table, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[headerPublishID] = publishID
+
+	returns.expect(publishID)
+	defer returns.forget(publishID)
+
+	confirmCtx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(confirmCtx,
+		msg.Exchange,   // exchange
+		msg.RoutingKey, // routing key
+		true,           // mandatory
+		false,          // immediate
 		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         jsonBody,
-			DeliveryMode: amqp.Persistent,
+			Headers:       headers,
+			ContentType:   contentType,
+			MessageId:     msg.ID,
+			Type:          msg.Type,
+			CorrelationId: msg.CorrelationID,
+			AppId:         msg.AppID,
+			Timestamp:     msg.Timestamp,
+			Body:          msg.Body,
+			DeliveryMode:  amqp.Persistent,
 		})
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(confirmCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w (message %s)", messaging.ErrConfirmTimeout, msg.ID)
+	}
+
+	// The broker sends basic.return before the ack of an unroutable mandatory message
+	if returned, ok := returns.take(publishID); ok {
+		return &messaging.ErrUnroutable{
+			Exchange:   returned.Exchange,
+			RoutingKey: returned.RoutingKey,
+			Reason:     returned.ReplyText,
+		}
+	}
+	if !acked {
+		return fmt.Errorf("%w (message %s)", messaging.ErrNacked, msg.ID)
+	}
+
+	return nil
 }
 
-func (c *RabbitMQClient) Consume(queueName, consumerName string, handler func(d amqp.Delivery)) error {
-	msgs, err := c.ch.Consume(
-		queueName,    // queue
-		consumerName, // consumer
-		false,        // auto-ack
-		false,        // exclusive
-		false,        // no-local
-		false,        // no-wait
-		nil,          // args
-	)
+// returnTracker hands the messages returned by the broker on a publisher channel to
+// the publishes waiting for their confirms, matched on headerPublishID.
+type returnTracker struct {
+	mu       sync.Mutex
+	returns  chan amqp.Return
+	expected map[string]*amqp.Return
+}
+
+func newReturnTracker(returns chan amqp.Return) *returnTracker {
+	return &returnTracker{returns: returns, expected: make(map[string]*amqp.Return)}
+}
+
+// expect registers a publish before it is sent.
+func (t *returnTracker) expect(publishID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expected[publishID] = nil
+}
+
+// forget unregisters a publish once it is done. It drains the returns too, since the
+// channel stops delivering confirms while the buffer of returns is full.
+func (t *returnTracker) forget(publishID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drain()
+	delete(t.expected, publishID)
+}
+
+// take reports whether the publish was returned. The broker sends basic.return before
+// the confirm, so it must be called once the confirm arrived.
+func (t *returnTracker) take(publishID string) (amqp.Return, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drain()
+
+	returned := t.expected[publishID]
+	if returned == nil {
+		return amqp.Return{}, false
+	}
+	return *returned, true
+}
+
+// drain moves the returned messages to the publishes expecting them without blocking.
+// Returns nobody expects, e.g. of publishes that timed out, are discarded.
+func (t *returnTracker) drain() {
+	for {
+		select {
+		case returned, ok := <-t.returns:
+			if !ok {
+				return
+			}
+			id, _ := returned.Headers[headerPublishID].(string)
+			if _, ok := t.expected[id]; ok {
+				t.expected[id] = &returned
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Subscribe starts consuming queue with options.Workers concurrent handlers and keeps
+// the consumer registered across reconnections.
+func (c *RabbitMQClient) Subscribe(queueName, consumerName string, handler messaging.Handler, options messaging.SubscribeOptions) error {
+	registration := consumer{queue: queueName, name: consumerName, handler: handler, options: options.WithDefaults()}
+	if err := c.consume(registration); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.consumers = append(c.consumers, registration)
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *RabbitMQClient) consume(registration consumer) error {
+	c.mu.Lock()
+	if c.stopping {
+		c.mu.Unlock()
+		return ErrShuttingDown
+	}
+	if !c.connected {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	ch := c.ch
+	// Registered before consuming so that a concurrent Shutdown waits for it
+	pool := newWorkerPool(registration.options.Workers, registration.handler)
+	c.pools[pool] = struct{}{}
+	c.mu.Unlock()
+
+	msgs, err := c.startConsumer(ch, registration)
 	if err != nil {
+		c.removePool(pool)
+		pool.Close()
 		return err
 	}
 
 	go func() {
 		for d := range msgs {
-			handler(d)
+			pool.Submit(toMessage(d), deliveryAcknowledger{delivery: d})
 		}
-		log.Println("RabbitMQ consumer stopped")
+		pool.Close()
+		c.removePool(pool)
+		log.Printf("RabbitMQ consumer %s stopped", registration.name)
 	}()
 
 	return nil
 }
 
-func (c *RabbitMQClient) SetupTopology() error {
-	// Exchange
-	err := c.ch.ExchangeDeclare(
-		"payments.exchange", // name
-		"topic",             // type
-		true,                // durable
-		false,               // auto-deleted
-		false,               // internal
-		false,               // no-wait
-		nil,                 // arguments
-	)
-	if err != nil {
-		return err
+func (c *RabbitMQClient) startConsumer(ch *amqp.Channel, registration consumer) (<-chan amqp.Delivery, error) {
+	c.subscribing.Lock()
+	defer c.subscribing.Unlock()
+
+	if err := ch.Qos(registration.options.Prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("error setting prefetch of consumer %s: %w", registration.name, err)
 	}
 
-	// Queues
-	_, err = c.ch.QueueDeclare(
-		"payment.requested.queue", // name
-		true,                      // durable
-		false,                     // delete when unused
-		false,                     // exclusive
-		false,                     // no-wait
-		nil,                       // arguments
+	return ch.Consume(
+		registration.queue, // queue
+		registration.name,  // consumer
+		false,              // auto-ack
+		false,              // exclusive
+		false,              // no-local
+		false,              // no-wait
+		nil,                // args
 	)
-	if err != nil {
-		return err
+}
+
+func (c *RabbitMQClient) removePool(pool *workerPool) {
+	c.mu.Lock()
+	delete(c.pools, pool)
+	c.mu.Unlock()
+}
+
+// Shutdown cancels the consumers so no new messages are delivered, waits for the
+// handlers in flight and then closes the connection. Messages still being handled
+// when ctx ends are requeued. Prefetched messages not handled yet return to the
+// queue as well.
+func (c *RabbitMQClient) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopping = true
+	ch := c.ch
+	connected := c.connected
+	consumers := append([]consumer(nil), c.consumers...)
+	pools := make([]*workerPool, 0, len(c.pools))
+	for pool := range c.pools {
+		pools = append(pools, pool)
 	}
+	c.mu.Unlock()
 
-	_, err = c.ch.QueueDeclare(
-		"payment.processed.queue", // name
-		true,                      // durable
-		false,                     // delete when unused
-		false,                     // exclusive
-		false,                     // no-wait
-		nil,                       // arguments
-	)
-	if err != nil {
-		return err
+	if connected {
+		for _, consumer := range consumers {
+			if err := ch.Cancel(consumer.name, false); err != nil {
+				log.Printf("Error cancelling RabbitMQ consumer %s: %v", consumer.name, err)
+			}
+		}
 	}
 
-	_, err = c.ch.QueueDeclare(
-		"payments.dlq", // name
-		true,           // durable
-		false,          // delete when unused
-		false,          // exclusive
-		false,          // no-wait
-		amqp.Table{
-			"x-dead-letter-exchange":    "payments.exchange",
-			"x-dead-letter-routing-key": "payment.dead",
-		},
-	)
-	if err != nil {
+	var drainErr error
+	for _, pool := range pools {
+		if err := pool.Drain(ctx); err != nil {
+			drainErr = err
+		}
+	}
+	if drainErr != nil {
+		log.Printf("Timed out waiting for message handlers, in-flight messages were requeued: %v", drainErr)
+	}
+
+	c.Close()
+	return drainErr
+}
+
+// deliveryAcknowledger settles a single amqp.Delivery.
+type deliveryAcknowledger struct {
+	delivery amqp.Delivery
+}
+
+func (a deliveryAcknowledger) Ack() error {
+	return a.delivery.Ack(false)
+}
+
+func (a deliveryAcknowledger) Nack(requeue bool) error {
+	return a.delivery.Nack(false, requeue)
+}
+
+func toMessage(d amqp.Delivery) messaging.Message {
+	headers := normalizeTable(d.Headers)
+	delete(headers, headerPublishID)
+	return messaging.Message{
+		ID:            d.MessageId,
+		Exchange:      d.Exchange,
+		RoutingKey:    d.RoutingKey,
+		ContentType:   d.ContentType,
+		Type:          d.Type,
+		CorrelationID: d.CorrelationId,
+		AppID:         d.AppId,
+		Headers:       headers,
+		Body:          d.Body,
+		Timestamp:     d.Timestamp,
+		Redelivered:   d.Redelivered,
+	}
+}
+
+// SetupTopology declares the topology, and again after every reconnection.
+func (c *RabbitMQClient) SetupTopology() error {
+	if err := c.declareTopology(); err != nil {
 		return err
 	}
 
-	// Bindings
-	err = c.ch.QueueBind(
-		"payment.requested.queue", // queue name
-		"payment.requested",       // routing key
-		"payments.exchange",       // exchange
-		false,
-		nil,
-	)
+	c.mu.Lock()
+	c.hasTopology = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *RabbitMQClient) declareTopology() error {
+	ch, err := c.channel()
 	if err != nil {
 		return err
 	}
+	topology := PaymentsTopology()
 
-	err = c.ch.QueueBind(
-		"payment.processed.queue", // queue name
-		"payment.processed",       // routing key
-		"payments.exchange",       // exchange
-		false,
-		nil,
-	)
+	for _, exchange := range topology.Exchanges {
+		err := ch.ExchangeDeclare(
+			exchange.Name, // name
+			exchange.Kind, // type
+			true,          // durable
+			false,         // auto-deleted
+			false,         // internal
+			false,         // no-wait
+			nil,           // arguments
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		_, err := ch.QueueDeclare(
+			queue.Name,             // name
+			true,                   // durable
+			false,                  // delete when unused
+			false,                  // exclusive
+			false,                  // no-wait
+			amqp.Table(queue.Args), // arguments
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		err := ch.QueueBind(
+			binding.Queue,      // queue name
+			binding.RoutingKey, // routing key
+			binding.Exchange,   // exchange
+			false,
+			nil,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, retired := range topology.Retired {
+		if err := c.retireQueue(retired); err != nil {
+			return fmt.Errorf("error retiring queue %s: %w", retired.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// retireQueue unbinds a replaced queue, so no new message reaches it, moves the messages
+// left in it to its replacement and deletes it. A queue that does not exist is skipped.
+func (c *RabbitMQClient) retireQueue(retired RetiredQueueSpec) error {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	// Own channel: declaring a missing queue passively closes the channel
+	ch, err := conn.Channel()
 	if err != nil {
 		return err
 	}
+	defer ch.Close()
 
-	err = c.ch.QueueBind(
-		"payments.dlq",      // queue name
-		"payment.dead",      // routing key
-		"payments.exchange", // exchange
-		false,
-		nil,
-	)
-	if err != nil {
+	if _, err := ch.QueueDeclarePassive(retired.Name, true, false, false, false, nil); err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return nil
+		}
 		return err
 	}
 
+	for _, binding := range retired.Bindings {
+		if err := ch.QueueUnbind(binding.Queue, binding.RoutingKey, binding.Exchange, nil); err != nil {
+			return err
+		}
+	}
+
+	moved := 0
+	for {
+		d, ok, err := ch.Get(retired.Name, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		msg := toMessage(d)
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]interface{})
+		}
+		if _, ok := msg.Headers[messaging.HeaderOriginalRoutingKey]; !ok {
+			msg.Headers[messaging.HeaderOriginalExchange] = d.Exchange
+			msg.Headers[messaging.HeaderOriginalRoutingKey] = d.RoutingKey
+		}
+		msg.Exchange = ""
+		msg.RoutingKey = retired.ReplacedBy
+		if err := c.Publish(context.Background(), msg); err != nil {
+			d.Nack(false, true)
+			return err
+		}
+		if err := d.Ack(false); err != nil {
+			return err
+		}
+		moved++
+	}
+
+	// ifEmpty: a consumer of an older instance may still be handling a message
+	if _, err := ch.QueueDelete(retired.Name, false, true, false); err != nil {
+		log.Printf("Queue %s was not deleted, delete it once it is empty: %v", retired.Name, err)
+		return nil
+	}
+	log.Printf("Retired queue %s, %d messages moved to %s", retired.Name, moved, retired.ReplacedBy)
 	return nil
 }
+
+// normalizeTable converts nested amqp.Table values, such as the entries of x-death,
+// to plain maps so consumers do not depend on the AMQP client types.
+func normalizeTable(table amqp.Table) map[string]interface{} {
+	if table == nil {
+		return nil
+	}
+	headers := make(map[string]interface{}, len(table))
+	for key, value := range table {
+		headers[key] = normalizeValue(value)
+	}
+	return headers
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case amqp.Table:
+		return normalizeTable(value)
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, item := range value {
+			values[i] = normalizeValue(item)
+		}
+		return values
+	}
+	return value
+}
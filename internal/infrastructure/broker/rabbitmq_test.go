@@ -0,0 +1,40 @@
+package broker
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestReturnTrackerMatchesPublishes(t *testing.T) {
+	returns := make(chan amqp.Return, 4)
+	tracker := newReturnTracker(returns)
+	tracker.expect("publish-1")
+	tracker.expect("publish-2")
+
+	// Both publishes carry the same message ID, and a late return of a publish that
+	// timed out is not expected anymore
+	returns <- amqp.Return{MessageId: "message-1", RoutingKey: "late", Headers: amqp.Table{headerPublishID: "publish-0"}}
+	returns <- amqp.Return{MessageId: "message-1", RoutingKey: "second", Headers: amqp.Table{headerPublishID: "publish-2"}}
+
+	if returned, ok := tracker.take("publish-1"); ok {
+		t.Errorf("publish-1 got the return of %s", returned.RoutingKey)
+	}
+	if returned, ok := tracker.take("publish-2"); !ok || returned.RoutingKey != "second" {
+		t.Errorf("take(publish-2) = %+v, %v", returned, ok)
+	}
+
+	tracker.forget("publish-1")
+	tracker.forget("publish-2")
+	if len(tracker.expected) != 0 {
+		t.Errorf("%d publishes still expected", len(tracker.expected))
+	}
+}
+
+func TestToMessageRemovesPublishID(t *testing.T) {
+	msg := toMessage(amqp.Delivery{MessageId: "message-1", Headers: amqp.Table{headerPublishID: "publish-1", "x-custom": "value"}})
+
+	if _, ok := msg.Headers[headerPublishID]; ok || msg.Header("x-custom") != "value" {
+		t.Errorf("headers = %v", msg.Headers)
+	}
+}
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"gateway-payments/internal/domain/messaging"
+	"time"
+)
+
+// Queue arguments understood by RabbitMQ and emulated by the in-memory broker.
+const (
+	ArgDeadLetterExchange   = "x-dead-letter-exchange"
+	ArgDeadLetterRoutingKey = "x-dead-letter-routing-key"
+	ArgMessageTTL           = "x-message-ttl"
+)
+
+// PaymentRequestedQueue receives payment.requested. It replaces payment.requested.queue,
+// which was declared without the dead-letter arguments that RabbitMQ cannot add later.
+const PaymentRequestedQueue = "payment.requested.v2.queue"
+
+// PaymentRequestedRetryPolicy is the retry policy of PaymentRequestedQueue: failed
+// requests wait in the retry queues and then return to it, and end in payments.dlq.
+func PaymentRequestedRetryPolicy() messaging.RetryPolicy {
+	return messaging.RetryPolicy{
+		Tiers: []messaging.RetryTier{
+			{Queue: "payment.requested.retry.5s", Delay: 5 * time.Second},
+			{Queue: "payment.requested.retry.30s", Delay: 30 * time.Second},
+			{Queue: "payment.requested.retry.5m", Delay: 5 * time.Minute},
+		},
+		DeadLetterExchange:   "payments.exchange",
+		DeadLetterRoutingKey: "payment.dead",
+	}
+}
+
+// DeadLetterDelay is where the dead-letter collector puts messages it cannot store yet,
+// until they return to payments.dlq.
+func DeadLetterDelay() messaging.RetryTier {
+	return messaging.RetryTier{Queue: "payments.dlq.delay", Delay: 5 * time.Second}
+}
+
+// retryQueues declares the tiers of policy.
+func retryQueues(queue string, policy messaging.RetryPolicy) []QueueSpec {
+	specs := make([]QueueSpec, 0, len(policy.Tiers))
+	for _, tier := range policy.Tiers {
+		specs = append(specs, delayQueue(queue, tier))
+	}
+	return specs
+}
+
+// delayQueue declares the queue of tier. Expired messages go back to queue through the default exchange.
+func delayQueue(queue string, tier messaging.RetryTier) QueueSpec {
+	return QueueSpec{Name: tier.Queue, Args: map[string]interface{}{
+		ArgMessageTTL:           int32(tier.Delay / time.Millisecond),
+		ArgDeadLetterExchange:   "",
+		ArgDeadLetterRoutingKey: queue,
+	}}
+}
+
+type ExchangeSpec struct {
+	Name string
+	Kind string
+}
+
+type QueueSpec struct {
+	Name string
+	Args map[string]interface{}
+}
+
+type BindingSpec struct {
+	Queue      string
+	RoutingKey string
+	Exchange   string
+}
+
+// RetiredQueueSpec is a queue replaced by another one, usually a versioned copy declared
+// with new arguments, since RabbitMQ refuses to redeclare a queue with different ones.
+// The queue is unbound, its messages are moved to ReplacedBy and it is deleted.
+type RetiredQueueSpec struct {
+	Name       string
+	ReplacedBy string
+	Bindings   []BindingSpec
+}
+
+// Topology describes the exchanges, queues and bindings a broker must declare.
+type Topology struct {
+	Exchanges []ExchangeSpec
+	Queues    []QueueSpec
+	Bindings  []BindingSpec
+	Retired   []RetiredQueueSpec
+}
+
+// PaymentsTopology is the topology used by the gateway.
+func PaymentsTopology() Topology {
+	retry := PaymentRequestedRetryPolicy()
+
+	topology := Topology{
+		Exchanges: []ExchangeSpec{
+			{Name: "payments.exchange", Kind: "topic"},
+		},
+		Queues: []QueueSpec{
+			// Messages rejected without a retry, e.g. when the consumer cannot reach the broker to
+			// schedule one, still reach payments.dlq
+			{Name: PaymentRequestedQueue, Args: map[string]interface{}{
+				ArgDeadLetterExchange:   retry.DeadLetterExchange,
+				ArgDeadLetterRoutingKey: retry.DeadLetterRoutingKey,
+			}},
+			{Name: "payment.processed.queue"},
+			{Name: "payment.refunded.queue"},
+			{Name: "payment.voided.queue"},
+			{Name: "payments.dlq", Args: map[string]interface{}{
+				ArgDeadLetterExchange:   "payments.exchange",
+				ArgDeadLetterRoutingKey: "payment.dead",
+			}},
+		},
+		Bindings: []BindingSpec{
+			{Queue: PaymentRequestedQueue, RoutingKey: "payment.requested", Exchange: "payments.exchange"},
+			{Queue: "payment.processed.queue", RoutingKey: "payment.processed", Exchange: "payments.exchange"},
+			{Queue: "payment.refunded.queue", RoutingKey: "payment.refunded", Exchange: "payments.exchange"},
+			{Queue: "payment.voided.queue", RoutingKey: "payment.voided", Exchange: "payments.exchange"},
+			{Queue: "payments.dlq", RoutingKey: "payment.dead", Exchange: "payments.exchange"},
+		},
+		Retired: []RetiredQueueSpec{
+			{Name: "payment.requested.queue", ReplacedBy: PaymentRequestedQueue, Bindings: []BindingSpec{
+				{Queue: "payment.requested.queue", RoutingKey: "payment.requested", Exchange: "payments.exchange"},
+			}},
+		},
+	}
+	topology.Queues = append(topology.Queues, retryQueues(PaymentRequestedQueue, retry)...)
+	topology.Queues = append(topology.Queues, delayQueue("payments.dlq", DeadLetterDelay()))
+
+	return topology
+}
@@ -0,0 +1,146 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/messaging"
+	"sync"
+)
+
+// ErrShuttingDown is returned when subscribing after Shutdown was called.
+var ErrShuttingDown = errors.New("broker is shutting down")
+
+type poolDelivery struct {
+	msg          messaging.Message
+	acknowledger messaging.Acknowledger
+}
+
+// workerPool runs a handler on up to a fixed number of deliveries at a time and
+// tracks the deliveries in flight, so a shutdown can wait for them or requeue them.
+type workerPool struct {
+	handler messaging.Handler
+	jobs    chan poolDelivery
+	closed  sync.Once
+
+	mu       sync.Mutex
+	stopped  bool
+	inflight map[*trackedAcknowledger]struct{}
+	running  sync.WaitGroup
+}
+
+func newWorkerPool(workers int, handler messaging.Handler) *workerPool {
+	p := &workerPool{
+		handler:  handler,
+		jobs:     make(chan poolDelivery),
+		inflight: make(map[*trackedAcknowledger]struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for delivery := range p.jobs {
+				p.run(delivery)
+			}
+		}()
+	}
+	return p
+}
+
+// Submit blocks until a worker takes the delivery. Once the pool is draining,
+// deliveries are requeued instead of handled.
+func (p *workerPool) Submit(msg messaging.Message, acknowledger messaging.Acknowledger) {
+	p.jobs <- poolDelivery{msg: msg, acknowledger: acknowledger}
+}
+
+// Close stops the workers once the source of deliveries is exhausted.
+func (p *workerPool) Close() {
+	p.closed.Do(func() {
+		close(p.jobs)
+	})
+}
+
+func (p *workerPool) Stopped() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+func (p *workerPool) run(delivery poolDelivery) {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		delivery.acknowledger.Nack(true)
+		return
+	}
+	tracked := &trackedAcknowledger{inner: delivery.acknowledger}
+	p.inflight[tracked] = struct{}{}
+	p.running.Add(1)
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.inflight, tracked)
+		p.mu.Unlock()
+		p.running.Done()
+	}()
+
+	p.handler(messaging.NewDelivery(delivery.msg, tracked))
+}
+
+// Drain stops handling new deliveries and waits for the ones in flight. If ctx ends
+// first, the unsettled deliveries are requeued and ctx.Err() is returned.
+func (p *workerPool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for tracked := range p.inflight {
+		tracked.Nack(true)
+	}
+	return ctx.Err()
+}
+
+// trackedAcknowledger settles a delivery once, whether from the handler or from a
+// shutdown requeueing it.
+type trackedAcknowledger struct {
+	inner messaging.Acknowledger
+
+	mu      sync.Mutex
+	settled bool
+}
+
+func (t *trackedAcknowledger) settle() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.settled {
+		return messaging.ErrAlreadyAcknowledged
+	}
+	t.settled = true
+	return nil
+}
+
+func (t *trackedAcknowledger) Ack() error {
+	if err := t.settle(); err != nil {
+		return err
+	}
+	return t.inner.Ack()
+}
+
+func (t *trackedAcknowledger) Nack(requeue bool) error {
+	if err := t.settle(); err != nil {
+		return err
+	}
+	return t.inner.Nack(requeue)
+}
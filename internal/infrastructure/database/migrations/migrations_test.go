@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "single statement without semicolon",
+			script: "SELECT 1",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "several statements",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "empty statements are dropped",
+			script: ";;\n  ;SELECT 1;;",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "only comments",
+			script: "-- nada aqui;\n/* nem aqui; */\n# nem aqui;\n",
+			want:   nil,
+		},
+		{
+			name:   "line comments",
+			script: "-- cria a tabela; depois o índice\nCREATE TABLE a (id INT); # fim;\nSELECT 1;",
+			want:   []string{"CREATE TABLE a (id INT)", "SELECT 1"},
+		},
+		{
+			name:   "block comments",
+			script: "ALTER TABLE a /* ; */ ADD COLUMN b INT;\n/* multi;\nline; */SELECT 1;",
+			want:   []string{"ALTER TABLE a  ADD COLUMN b INT", "SELECT 1"},
+		},
+		{
+			name:   "semicolons inside quotes",
+			script: "INSERT INTO a VALUES ('x;y', \"z;w\");\nSELECT `we;ird` FROM a;",
+			want:   []string{"INSERT INTO a VALUES ('x;y', \"z;w\")", "SELECT `we;ird` FROM a"},
+		},
+		{
+			name:   "comment markers inside quotes",
+			script: "INSERT INTO a VALUES ('-- not a comment', '# nor this', '/* nor this */');",
+			want:   []string{"INSERT INTO a VALUES ('-- not a comment', '# nor this', '/* nor this */')"},
+		},
+		{
+			name:   "escaped quotes",
+			script: "INSERT INTO a VALUES ('it\\'s; fine');SELECT 1;",
+			want:   []string{"INSERT INTO a VALUES ('it\\'s; fine')", "SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q)\n got %q\nwant %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, migration := range migrations {
+		// Versions are contiguous, so a renumbering cannot leave gaps or duplicates behind
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s is at position %d", migration.Version, migration.Name, i+1)
+		}
+		if len(splitStatements(migration.Up)) == 0 || len(splitStatements(migration.Down)) == 0 {
+			t.Errorf("migration %d_%s has an empty up or down script", migration.Version, migration.Name)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// lockName is the MySQL advisory lock held while migrating, so replicas started
+// together with AUTO_MIGRATE do not run the same migration twice.
+const lockName = "gateway_payments.schema_migrations"
+
+// ErrLocked is returned when another process kept the migration lock for longer than the lock timeout.
+var ErrLocked = errors.New("another process is running migrations")
+
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Change is a migration applied or rolled back by the Migrator.
+type Change struct {
+	Version   int64
+	Name      string
+	Direction string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %04d_%s", c.Direction, c.Version, c.Name)
+}
+
+// Status reports whether a migration was applied. Migrations applied by a newer
+// binary are listed with an empty Name and Unknown set.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type Migrator struct {
+	DB          *sql.DB
+	Migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// NewMigrator loads the embedded migrations.
+func NewMigrator(db *sql.DB, lockTimeout time.Duration) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		DB:          db,
+		Migrations:  migrations,
+		LockTimeout: lockTimeout,
+	}, nil
+}
+
+// Latest is the version of the newest embedded migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]Change, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Change, error) {
+	var changes []Change
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := sortedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && len(changes) < steps; i-- {
+			change, err := m.rollback(ctx, conn, versions[i])
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// To migrates the schema to version, applying the pending migrations up to it and
+// rolling back the applied ones above it.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Change, error) {
+	if version < 0 || (version > 0 && m.find(version) == nil) {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	var changes []Change
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := sortedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > version; i-- {
+			change, err := m.rollback(ctx, conn, versions[i])
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+
+		for _, migration := range m.Migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			changes = append(changes, Change{Version: migration.Version, Name: migration.Name, Direction: DirectionUp})
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// Status lists every embedded migration, and any unknown applied one, by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+	defer conn.Close()
+
+	if err := createMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, version := range sortedVersions(applied) {
+		if m.find(version) == nil {
+			appliedAt := applied[version]
+			statuses = append(statuses, Status{Version: version, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn on a dedicated connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to the database: %w", err)
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(m.LockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("error acquiring the migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		// The lock is also released when the connection closes
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			log.Printf("Error releasing the migration lock: %v", err)
+		}
+	}()
+
+	if err := createMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply runs the statements of migration and records it. MySQL commits DDL
+// statements implicitly, so a migration that fails halfway is not rolled back.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	for _, statement := range splitStatements(migration.Up) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error applying migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	_, err := conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, migration.Version, migration.Name, time.Now())
+	if err != nil {
+		return fmt.Errorf("error recording migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, version int64) (Change, error) {
+	migration := m.find(version)
+	if migration == nil {
+		return Change{}, fmt.Errorf("cannot roll back migration %d: it is not known to this version of the binary", version)
+	}
+
+	for _, statement := range splitStatements(migration.Down) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return Change{}, fmt.Errorf("error rolling back migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, version); err != nil {
+		return Change{}, fmt.Errorf("error recording rollback of migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+
+	return Change{Version: migration.Version, Name: migration.Name, Direction: DirectionDown}, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.Migrations {
+		if m.Migrations[i].Version == version {
+			return &m.Migrations[i]
+		}
+	}
+	return nil
+}
+
+func createMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    applied_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func sortedVersions(applied map[int64]time.Time) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	return versions
+}
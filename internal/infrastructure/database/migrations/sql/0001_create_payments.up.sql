@@ -1,6 +1,5 @@
-CREATE DATABASE IF NOT EXISTS gateway_db;
-USE gateway_db;
-
+-- Esquema original de create_table.sql. Bancos criados por aquele script já têm a
+-- tabela, então esta migração não altera nada neles; as seguintes a atualizam.
 CREATE TABLE IF NOT EXISTS payments (
     -- ID v4 do UUID (36 caracteres)
     id CHAR(36) NOT NULL,
@@ -23,4 +22,4 @@ CREATE TABLE IF NOT EXISTS payments (
     PRIMARY KEY (id),
     INDEX idx_status (status),
     INDEX idx_order_id (order_id)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
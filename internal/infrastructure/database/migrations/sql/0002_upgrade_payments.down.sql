@@ -0,0 +1,19 @@
+ALTER TABLE payments
+    ADD COLUMN amount DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER id;
+
+-- Exato para moedas com 2 casas decimais, as únicas do esquema antigo
+UPDATE payments SET amount = amount_minor / 100;
+
+ALTER TABLE payments
+    ALTER COLUMN amount DROP DEFAULT,
+    DROP INDEX idx_status_authorized_at,
+    DROP COLUMN version,
+    DROP COLUMN updated_at,
+    DROP COLUMN authorized_at,
+    DROP COLUMN processor_reference,
+    DROP COLUMN processor,
+    DROP COLUMN status_reason,
+    DROP COLUMN currency,
+    DROP COLUMN refunded_minor,
+    DROP COLUMN captured_minor,
+    DROP COLUMN amount_minor;
@@ -0,0 +1,34 @@
+-- Leva a tabela original ao esquema usado pela aplicação.
+ALTER TABLE payments
+    -- Valor na menor unidade da moeda (centavos para BRL, ienes para JPY)
+    ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0 AFTER id,
+    -- Valor efetivamente capturado (pode ser menor que o autorizado)
+    ADD COLUMN captured_minor BIGINT NOT NULL DEFAULT 0 AFTER amount_minor,
+    -- Total já estornado, na mesma unidade de amount_minor
+    ADD COLUMN refunded_minor BIGINT NOT NULL DEFAULT 0 AFTER captured_minor,
+    -- Código ISO-4217 da moeda
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL' AFTER refunded_minor,
+    -- Motivo informado na rejeição do pagamento
+    ADD COLUMN status_reason VARCHAR(255) NULL AFTER status,
+    -- Processador que autorizou o pagamento e a referência dele no processador
+    ADD COLUMN processor VARCHAR(32) NULL AFTER status_reason,
+    ADD COLUMN processor_reference VARCHAR(64) NULL AFTER processor,
+    -- Início da reserva (AUTHORIZED), usado para expirar autorizações antigas
+    ADD COLUMN authorized_at DATETIME(6) NULL AFTER processor_reference,
+    -- Última alteração do pagamento
+    ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) AFTER created_at,
+    -- Incrementada a cada UPDATE; usada no lock otimista (compare-and-swap)
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1 AFTER updated_at,
+    ADD INDEX idx_status_authorized_at (status, authorized_at);
+
+-- Os valores antigos estão em reais com 2 casas decimais; pagamentos aprovados foram capturados integralmente
+UPDATE payments
+SET amount_minor = ROUND(amount * 100),
+    currency = 'BRL',
+    captured_minor = CASE WHEN status = 'APPROVED' THEN ROUND(amount * 100) ELSE 0 END;
+
+-- amount só é removida depois de copiada para amount_minor
+ALTER TABLE payments
+    ALTER COLUMN amount_minor DROP DEFAULT,
+    ALTER COLUMN currency DROP DEFAULT,
+    DROP COLUMN amount;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    -- Valor enviado no header Idempotency-Key
+    idempotency_key VARCHAR(255) NOT NULL,
+
+    -- SHA-256 do método, path e corpo da primeira requisição
+    request_hash CHAR(64) NOT NULL,
+
+    -- 0 enquanto a requisição original ainda está em andamento
+    status_code SMALLINT NOT NULL DEFAULT 0,
+
+    -- Resposta devolvida novamente nas tentativas repetidas
+    response_body BLOB NULL,
+
+    created_at DATETIME(6) NOT NULL,
+
+    PRIMARY KEY (idempotency_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id CHAR(36) NOT NULL,
+
+    -- Pagamento que originou o evento
+    aggregate_id CHAR(36) NOT NULL,
+
+    exchange VARCHAR(255) NOT NULL,
+    routing_key VARCHAR(255) NOT NULL,
+
+    -- Corpo JSON do evento, publicado exatamente como foi gravado
+    payload MEDIUMBLOB NOT NULL,
+
+    -- Tentativas de publicação que falharam e o último erro
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NULL,
+
+    -- Próxima tentativa permitida (backoff exponencial)
+    available_at DATETIME(6) NOT NULL,
+
+    -- Lease do relay que está publicando a mensagem
+    locked_by VARCHAR(64) NULL,
+    locked_until DATETIME(6) NULL,
+
+    created_at DATETIME(6) NOT NULL,
+    sent_at DATETIME(6) NULL,
+
+    PRIMARY KEY (id),
+    INDEX idx_outbox_pending (sent_at, available_at),
+    INDEX idx_outbox_locked_by (locked_by)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
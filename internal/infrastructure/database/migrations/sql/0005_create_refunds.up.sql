@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS refunds (
+    id CHAR(36) NOT NULL,
+    payment_id CHAR(36) NOT NULL,
+
+    -- Valor estornado na menor unidade da moeda do pagamento
+    amount_minor BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+
+    reason VARCHAR(255) NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+
+    PRIMARY KEY (id),
+    INDEX idx_refunds_payment_id (payment_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
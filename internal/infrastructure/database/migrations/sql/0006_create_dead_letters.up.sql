@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id CHAR(36) NOT NULL,
+
+    -- MessageId AMQP da mensagem original, quando houver
+    message_id VARCHAR(255) NULL,
+
+    -- Exchange e routing key em que a mensagem foi publicada originalmente (destino do replay)
+    exchange VARCHAR(255) NOT NULL,
+    routing_key VARCHAR(255) NOT NULL,
+
+    content_type VARCHAR(255) NULL,
+    headers JSON NULL,
+    body MEDIUMBLOB NOT NULL,
+
+    -- poison, retries_exhausted ou rejected, e o erro que levou a mensagem à DLQ
+    failure_kind VARCHAR(32) NULL,
+    failure_reason TEXT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+
+    -- PENDING até ser reprocessada (REPLAYED)
+    status VARCHAR(20) NOT NULL,
+    replay_count INT NOT NULL DEFAULT 0,
+
+    received_at DATETIME(6) NOT NULL,
+    replayed_at DATETIME(6) NULL,
+
+    PRIMARY KEY (id),
+    INDEX idx_dead_letters_status (status, received_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id CHAR(36) NOT NULL,
+
+    -- Quem executou a ação (usuário da API administrativa ou da CLI)
+    actor VARCHAR(255) NOT NULL,
+
+    -- Ação executada, ex.: dlq.replay, dlq.purge
+    action VARCHAR(64) NOT NULL,
+
+    -- Recurso afetado
+    target_type VARCHAR(64) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+
+    details TEXT NULL,
+    created_at DATETIME(6) NOT NULL,
+
+    PRIMARY KEY (id),
+    INDEX idx_audit_log_target (target_type, target_id),
+    INDEX idx_audit_log_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
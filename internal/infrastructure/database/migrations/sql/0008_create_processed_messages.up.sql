@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS processed_messages (
+    -- Consumidor que processou a mensagem, ex.: payment.requested
+    consumer VARCHAR(64) NOT NULL,
+
+    -- MessageId da mensagem consumida
+    message_id VARCHAR(255) NOT NULL,
+
+    -- PROCESSING enquanto um worker detém o claim, PROCESSED depois do sucesso
+    status VARCHAR(20) NOT NULL,
+
+    -- Fim do lease do worker; depois disso outro worker pode assumir a mensagem
+    locked_until DATETIME(6) NOT NULL,
+
+    processed_at DATETIME(6) NULL,
+
+    -- Usado na limpeza por TTL
+    created_at DATETIME(6) NOT NULL,
+
+    PRIMARY KEY (consumer, message_id),
+    INDEX idx_processed_messages_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
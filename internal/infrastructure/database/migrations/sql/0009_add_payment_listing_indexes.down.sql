@@ -0,0 +1,2 @@
+DROP INDEX idx_payments_status_created_at ON payments;
+DROP INDEX idx_payments_created_at ON payments;
@@ -0,0 +1,3 @@
+-- Listagem de GET /payments: ordenação padrão por created_at, com ou sem filtro de status
+CREATE INDEX idx_payments_created_at ON payments (created_at, id);
+CREATE INDEX idx_payments_status_created_at ON payments (status, created_at, id);
@@ -0,0 +1,9 @@
+UPDATE payments SET status = 'VOIDED' WHERE status = 'EXPIRED';
+
+ALTER TABLE payments
+    ADD INDEX idx_order_id (order_id),
+    DROP INDEX uq_payments_live_order_id,
+    DROP INDEX uq_payments_order_attempt,
+    DROP COLUMN live_order_id,
+    DROP COLUMN duplicate_of,
+    DROP COLUMN attempt;
@@ -0,0 +1,39 @@
+-- Um pedido pode ter várias tentativas de pagamento, numeradas a partir de 1.
+-- live_order_id só é preenchida enquanto a tentativa está aberta ou foi bem-sucedida,
+-- então a unique garante no máximo uma dessas por pedido; tentativas que falharam
+-- (REJECTED, VOIDED, EXPIRED) liberam o pedido para uma nova tentativa.
+ALTER TABLE payments
+    ADD COLUMN attempt INT NOT NULL DEFAULT 1 AFTER order_id,
+    -- Pagamento antigo em aberto ou aprovado de um pedido que já tinha um mais recente
+    -- antes das uniques existirem; aponta para o que continua valendo, para conciliação
+    ADD COLUMN duplicate_of CHAR(36) NULL AFTER attempt;
+
+-- Pedidos com vários pagamentos antigos: numera as tentativas pela ordem de criação
+UPDATE payments p
+JOIN (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY order_id ORDER BY created_at, id) AS attempt
+    FROM payments
+) numbered ON numbered.id = p.id
+SET p.attempt = numbered.attempt;
+
+-- Só a tentativa mais recente de cada pedido continua viva; as outras são marcadas em
+-- vez de alteradas, já que o dinheiro delas pode ter sido movido
+UPDATE payments p
+JOIN (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY order_id ORDER BY attempt DESC) AS kept
+    FROM payments
+    WHERE status NOT IN ('REJECTED', 'VOIDED', 'EXPIRED')
+) live ON live.id = p.id
+SET p.duplicate_of = live.kept
+WHERE live.kept <> p.id;
+
+ALTER TABLE payments
+    ADD COLUMN live_order_id VARCHAR(36) GENERATED ALWAYS AS (
+        CASE WHEN status IN ('REJECTED', 'VOIDED', 'EXPIRED') OR duplicate_of IS NOT NULL THEN NULL ELSE order_id END
+    ) STORED AFTER duplicate_of,
+    ADD UNIQUE KEY uq_payments_order_attempt (order_id, attempt),
+    ADD UNIQUE KEY uq_payments_live_order_id (live_order_id),
+    DROP INDEX idx_order_id;
+
+-- Autorizações expiradas eram gravadas como VOIDED
+UPDATE payments SET status = 'EXPIRED' WHERE status = 'VOIDED' AND status_reason = 'authorization expired';
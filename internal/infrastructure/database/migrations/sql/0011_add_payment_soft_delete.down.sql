@@ -0,0 +1,12 @@
+-- Pagamentos excluídos voltam a aparecer; falha se algum pedido tiver uma tentativa
+-- excluída e uma nova tentativa em aberto
+ALTER TABLE payments
+    MODIFY COLUMN live_order_id VARCHAR(36) GENERATED ALWAYS AS (
+        CASE WHEN status IN ('REJECTED', 'VOIDED', 'EXPIRED') OR duplicate_of IS NOT NULL THEN NULL ELSE order_id END
+    ) STORED AFTER duplicate_of;
+
+ALTER TABLE payments
+    DROP INDEX idx_payments_deleted_at,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at,
+    DROP COLUMN legal_hold;
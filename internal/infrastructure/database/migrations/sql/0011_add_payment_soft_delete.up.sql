@@ -0,0 +1,14 @@
+-- Pagamentos não são mais apagados pela API: o DELETE marca deleted_at/deleted_by e
+-- o job de retenção remove definitivamente os elegíveis depois do prazo.
+-- legal_hold impede tanto a exclusão quanto a remoção definitiva.
+ALTER TABLE payments
+    ADD COLUMN legal_hold BOOLEAN NOT NULL DEFAULT FALSE AFTER version,
+    ADD COLUMN deleted_at DATETIME(6) NULL AFTER legal_hold,
+    ADD COLUMN deleted_by VARCHAR(255) NULL AFTER deleted_at,
+    ADD INDEX idx_payments_deleted_at (deleted_at);
+
+-- Uma tentativa excluída libera o pedido para uma nova tentativa
+ALTER TABLE payments
+    MODIFY COLUMN live_order_id VARCHAR(36) GENERATED ALWAYS AS (
+        CASE WHEN status IN ('REJECTED', 'VOIDED', 'EXPIRED') OR duplicate_of IS NOT NULL OR deleted_at IS NOT NULL THEN NULL ELSE order_id END
+    ) STORED AFTER duplicate_of;
@@ -0,0 +1,4 @@
+-- A tentativa é gravada como PENDING antes da chamada ao processador; enquanto
+-- processing_until não passar, outras requisições do mesmo pedido não a processam de novo
+ALTER TABLE payments
+    ADD COLUMN processing_until DATETIME(6) NULL AFTER authorized_at;
@@ -0,0 +1,3 @@
+ALTER TABLE payments
+    DROP COLUMN next_expiry_attempt_at,
+    DROP COLUMN expiry_attempts;
@@ -0,0 +1,5 @@
+-- Falhas ao liberar uma autorização expirada no processador: a próxima tentativa é
+-- adiada para next_expiry_attempt_at, para não travar o lote nas mesmas linhas
+ALTER TABLE payments
+    ADD COLUMN expiry_attempts INT NOT NULL DEFAULT 0 AFTER processing_until,
+    ADD COLUMN next_expiry_attempt_at DATETIME(6) NULL AFTER expiry_attempts;
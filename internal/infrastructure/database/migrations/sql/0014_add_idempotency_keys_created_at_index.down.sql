@@ -0,0 +1 @@
+DROP INDEX idx_idempotency_keys_created_at ON idempotency_keys;
@@ -0,0 +1,2 @@
+-- Limpeza periódica das chaves mais antigas que IDEMPOTENCY_KEY_TTL
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at);
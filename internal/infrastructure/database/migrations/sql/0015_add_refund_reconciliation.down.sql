@@ -0,0 +1,4 @@
+ALTER TABLE refunds
+    DROP INDEX idx_refunds_status_created_at,
+    DROP COLUMN next_reconcile_at,
+    DROP COLUMN reconcile_attempts;
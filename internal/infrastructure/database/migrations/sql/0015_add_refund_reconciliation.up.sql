@@ -0,0 +1,6 @@
+-- Estornos sem resposta do processador ficam PENDING e são consultados de novo por um job.
+-- Falhas adiam a próxima consulta para next_reconcile_at, para não travar o lote.
+ALTER TABLE refunds
+    ADD COLUMN reconcile_attempts INT NOT NULL DEFAULT 0 AFTER status,
+    ADD COLUMN next_reconcile_at DATETIME(6) NULL AFTER reconcile_attempts,
+    ADD INDEX idx_refunds_status_created_at (status, created_at);
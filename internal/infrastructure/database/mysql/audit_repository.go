@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+)
+
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+func (r *AuditRepository) Create(entry *entity.AuditEntry) error {
+	query := `INSERT INTO audit_log (id, actor, action, target_type, target_id, details, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.DB.Exec(
+		query,
+		entry.ID,
+		entry.Actor,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Details,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error persisting audit entry [%s]: %w", entry.ID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+)
+
+const deadLetterColumns = `id, COALESCE(message_id, ''), exchange, routing_key, COALESCE(content_type, ''), headers, body, COALESCE(failure_kind, ''), COALESCE(failure_reason, ''), attempts, status, replay_count, received_at, replayed_at`
+
+func scanDeadLetter(row rowScanner) (*entity.DeadLetter, error) {
+	deadLetter := &entity.DeadLetter{}
+	var headers []byte
+	var replayedAt sql.NullTime
+	err := row.Scan(
+		&deadLetter.ID,
+		&deadLetter.MessageID,
+		&deadLetter.Exchange,
+		&deadLetter.RoutingKey,
+		&deadLetter.ContentType,
+		&headers,
+		&deadLetter.Body,
+		&deadLetter.FailureKind,
+		&deadLetter.FailureReason,
+		&deadLetter.Attempts,
+		&deadLetter.Status,
+		&deadLetter.ReplayCount,
+		&deadLetter.ReceivedAt,
+		&replayedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &deadLetter.Headers); err != nil {
+			return nil, fmt.Errorf("error decoding headers of dead letter [%s]: %w", deadLetter.ID, err)
+		}
+	}
+	if replayedAt.Valid {
+		deadLetter.ReplayedAt = &replayedAt.Time
+	}
+
+	return deadLetter, nil
+}
+
+type DeadLetterRepository struct {
+	DB DBTX
+}
+
+func NewDeadLetterRepository(db DBTX) *DeadLetterRepository {
+	return &DeadLetterRepository{DB: db}
+}
+
+func (r *DeadLetterRepository) Create(deadLetter *entity.DeadLetter) error {
+	headers, err := json.Marshal(deadLetter.Headers)
+	if err != nil {
+		return fmt.Errorf("error encoding headers of dead letter [%s]: %w", deadLetter.ID, err)
+	}
+
+	query := `INSERT INTO dead_letters (id, message_id, exchange, routing_key, content_type, headers, body, failure_kind, failure_reason, attempts, status, replay_count, received_at, replayed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.DB.Exec(
+		query,
+		deadLetter.ID,
+		deadLetter.MessageID,
+		deadLetter.Exchange,
+		deadLetter.RoutingKey,
+		deadLetter.ContentType,
+		headers,
+		deadLetter.Body,
+		deadLetter.FailureKind,
+		deadLetter.FailureReason,
+		deadLetter.Attempts,
+		deadLetter.Status,
+		deadLetter.ReplayCount,
+		deadLetter.ReceivedAt,
+		deadLetter.ReplayedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error persisting dead letter [%s]: %w", deadLetter.ID, err)
+	}
+
+	return nil
+}
+
+func (r *DeadLetterRepository) FindByID(id string) (*entity.DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE id = ?`
+	deadLetter, err := scanDeadLetter(r.DB.QueryRow(query, id))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &repository.ErrNotFound{Message: fmt.Sprintf("dead letter with ID %s not found", id)}
+		}
+		return nil, fmt.Errorf("error finding dead letter by ID [%s]: %w", id, err)
+	}
+
+	return deadLetter, nil
+}
+
+func (r *DeadLetterRepository) FindAll(status string, page, limit int) ([]*entity.DeadLetter, error) {
+	offset := (page - 1) * limit
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE (? = '' OR status = ?) ORDER BY received_at LIMIT ? OFFSET ?`
+	rows, err := r.DB.Query(query, status, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error querying dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := make([]*entity.DeadLetter, 0)
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning dead letter row: %w", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return deadLetters, nil
+}
+
+func (r *DeadLetterRepository) Update(deadLetter *entity.DeadLetter) error {
+	query := `UPDATE dead_letters SET status = ?, replay_count = ?, replayed_at = ? WHERE id = ?`
+	result, err := r.DB.Exec(query, deadLetter.Status, deadLetter.ReplayCount, deadLetter.ReplayedAt, deadLetter.ID)
+	if err != nil {
+		return fmt.Errorf("error updating dead letter [%s]: %w", deadLetter.ID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected after update: %w", err)
+	}
+	if rowsAffected == 0 {
+		// MySQL reports 0 rows when nothing changed, so check that the row exists
+		if _, err := r.FindByID(deadLetter.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DeadLetterRepository) Delete(id string) error {
+	result, err := r.DB.Exec(`DELETE FROM dead_letters WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting dead letter [%s]: %w", id, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected after delete: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &repository.ErrNotFound{Message: fmt.Sprintf("dead letter with ID %s not found", id)}
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package mysql
+
+import (
+	"errors"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrDupEntry is the server error number for unique constraint violations.
+const mysqlErrDupEntry = 1062
+
+func isDuplicateKeyError(err error) bool {
+	var mysqlErr *driver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDupEntry
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+func (r *IdempotencyRepository) Create(record *entity.IdempotencyKey) error {
+	query := `INSERT INTO idempotency_keys (idempotency_key, request_hash, status_code, response_body, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := r.DB.Exec(
+		query,
+		record.Key,
+		record.RequestHash,
+		record.StatusCode,
+		record.ResponseBody,
+		record.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return repository.ErrDuplicateKey
+		}
+		return fmt.Errorf("error persisting idempotency key [%s]: %w", record.Key, err)
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) FindByKey(key string) (*entity.IdempotencyKey, error) {
+	record := &entity.IdempotencyKey{}
+	query := `SELECT idempotency_key, request_hash, status_code, response_body, created_at FROM idempotency_keys WHERE idempotency_key = ?`
+	err := r.DB.QueryRow(query, key).Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ResponseBody,
+		&record.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &repository.ErrNotFound{Message: fmt.Sprintf("idempotency key %s not found", key)}
+		}
+		return nil, fmt.Errorf("error finding idempotency key [%s]: %w", key, err)
+	}
+
+	return record, nil
+}
+
+func (r *IdempotencyRepository) Complete(record *entity.IdempotencyKey) error {
+	query := `UPDATE idempotency_keys SET status_code = ?, response_body = ? WHERE idempotency_key = ?`
+	_, err := r.DB.Exec(query, record.StatusCode, record.ResponseBody, record.Key)
+	if err != nil {
+		return fmt.Errorf("error completing idempotency key [%s]: %w", record.Key, err)
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) TakeOver(key string, staleBefore time.Time, now time.Time) (bool, error) {
+	// Só uma das requisições concorrentes consegue renovar a chave abandonada
+	query := `UPDATE idempotency_keys SET created_at = ? WHERE idempotency_key = ? AND status_code = 0 AND created_at < ?`
+	result, err := r.DB.Exec(query, now, key, staleBefore)
+	if err != nil {
+		return false, fmt.Errorf("error taking over idempotency key [%s]: %w", key, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error taking over idempotency key [%s]: %w", key, err)
+	}
+	return affected == 1, nil
+}
+
+func (r *IdempotencyRepository) Delete(key string) error {
+	_, err := r.DB.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("error deleting idempotency key [%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteCreatedBefore(before time.Time, limit int) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM idempotency_keys WHERE created_at < ? LIMIT ?`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
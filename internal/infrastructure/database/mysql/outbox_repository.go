@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+// maxOutboxErrorLength matches the size of the outbox.last_error column.
+const maxOutboxErrorLength = 1024
+
+type OutboxRepository struct {
+	DB DBTX
+}
+
+func NewOutboxRepository(db DBTX) *OutboxRepository {
+	return &OutboxRepository{DB: db}
+}
+
+func (r *OutboxRepository) Add(messages ...*entity.OutboxMessage) error {
+	return inTransaction(r.DB, func(tx DBTX) error {
+		return insertOutboxMessages(tx, messages)
+	})
+}
+
+// insertOutboxMessages writes messages using the caller's transaction.
+func insertOutboxMessages(tx DBTX, messages []*entity.OutboxMessage) error {
+	query := `INSERT INTO outbox (id, aggregate_id, exchange, routing_key, payload, attempts, available_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, message := range messages {
+		_, err := tx.Exec(
+			query,
+			message.ID,
+			message.AggregateID,
+			message.Exchange,
+			message.RoutingKey,
+			message.Payload,
+			message.Attempts,
+			message.AvailableAt,
+			message.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error persisting outbox message [%s]: %w", message.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) Claim(owner string, limit int, lease time.Duration) ([]*entity.OutboxMessage, error) {
+	now := time.Now()
+
+	claim := `UPDATE outbox SET locked_by = ?, locked_until = ?
+		WHERE sent_at IS NULL AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)
+		ORDER BY created_at LIMIT ?`
+	_, err := r.DB.Exec(claim, owner, now.Add(lease), now, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming outbox messages: %w", err)
+	}
+
+	query := `SELECT id, aggregate_id, exchange, routing_key, payload, attempts, COALESCE(last_error, ''), available_at, created_at
+		FROM outbox WHERE locked_by = ? AND locked_until > ? AND sent_at IS NULL ORDER BY created_at`
+	rows, err := r.DB.Query(query, owner, now)
+	if err != nil {
+		return nil, fmt.Errorf("error querying claimed outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]*entity.OutboxMessage, 0)
+	for rows.Next() {
+		message := &entity.OutboxMessage{}
+		if err := rows.Scan(
+			&message.ID,
+			&message.AggregateID,
+			&message.Exchange,
+			&message.RoutingKey,
+			&message.Payload,
+			&message.Attempts,
+			&message.LastError,
+			&message.AvailableAt,
+			&message.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning outbox row: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkSent(id string, sentAt time.Time) error {
+	query := `UPDATE outbox SET sent_at = ?, locked_by = NULL, locked_until = NULL WHERE id = ?`
+	_, err := r.DB.Exec(query, sentAt, id)
+	if err != nil {
+		return fmt.Errorf("error marking outbox message [%s] as sent: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) MarkFailed(id string, lastError string, retryAt time.Time) error {
+	if len(lastError) > maxOutboxErrorLength {
+		lastError = lastError[:maxOutboxErrorLength]
+	}
+
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = ?, available_at = ?, locked_by = NULL, locked_until = NULL WHERE id = ?`
+	_, err := r.DB.Exec(query, lastError, retryAt, id)
+	if err != nil {
+		return fmt.Errorf("error marking outbox message [%s] as failed: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) Stats() (*entity.OutboxStats, error) {
+	stats := &entity.OutboxStats{}
+	var oldest sql.NullTime
+
+	query := `SELECT COUNT(*), MIN(created_at) FROM outbox WHERE sent_at IS NULL`
+	err := r.DB.QueryRow(query).Scan(&stats.Pending, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("error reading outbox stats: %w", err)
+	}
+	if oldest.Valid {
+		stats.OldestPending = &oldest.Time
+	}
+
+	return stats, nil
+}
+
+func (r *OutboxRepository) DeleteSentBefore(before time.Time, limit int) (int64, error) {
+	// Mensagens pendentes têm sent_at NULL e nunca entram no intervalo
+	result, err := r.DB.Exec(`DELETE FROM outbox WHERE sent_at < ? LIMIT ?`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting sent outbox messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
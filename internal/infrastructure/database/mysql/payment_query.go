@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"fmt"
+	"gateway-payments/internal/domain/repository"
+	"strings"
+)
+
+// paymentSortColumns maps the sort fields of repository.PaymentQuery to columns. Only
+// these columns ever reach ORDER BY, so sort fields cannot inject SQL.
+var paymentSortColumns = map[string]string{
+	repository.PaymentSortCreatedAt: "created_at",
+	repository.PaymentSortUpdatedAt: "updated_at",
+	repository.PaymentSortAmount:    "amount_minor",
+	repository.PaymentSortStatus:    "status",
+}
+
+// paymentWhere builds the WHERE clause of filter. Every condition compares a bare
+// column, so status, order_id and created_at lookups can use their indexes.
+func paymentWhere(filter repository.PaymentFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	in := func(column string, values []string) {
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = "?"
+			args = append(args, value)
+		}
+		conditions = append(conditions, column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if len(filter.Statuses) > 0 {
+		in("status", filter.Statuses)
+	}
+	if len(filter.Methods) > 0 {
+		in("method", filter.Methods)
+	}
+	if filter.OrderID != "" {
+		conditions = append(conditions, "order_id = ?")
+		args = append(args, filter.OrderID)
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = ?")
+		args = append(args, filter.Currency)
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount_minor >= ?")
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount_minor <= ?")
+		args = append(args, *filter.MaxAmount)
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// paymentAfter adds to where the condition that selects the payments following key,
+// written as a range on (created_at, id) so it is served by idx_payments_created_at
+// and idx_payments_status_created_at.
+func paymentAfter(where string, args []interface{}, key repository.PaymentKey, sorts []repository.PaymentSort) (string, []interface{}, error) {
+	descending := true
+	if len(sorts) > 0 {
+		if len(sorts) > 1 || sorts[0].Field != repository.PaymentSortCreatedAt {
+			return "", nil, fmt.Errorf("keyset pagination requires sorting by %s alone", repository.PaymentSortCreatedAt)
+		}
+		descending = sorts[0].Descending
+	}
+
+	operator := ">"
+	if descending {
+		operator = "<"
+	}
+	condition := "(created_at " + operator + " ? OR (created_at = ? AND id " + operator + " ?))"
+	args = append(args, key.CreatedAt, key.CreatedAt, key.ID)
+
+	if where == "" {
+		return " WHERE " + condition, args, nil
+	}
+	return where + " AND " + condition, args, nil
+}
+
+// paymentOrderBy builds the ORDER BY clause of sorts, newest first by default, with
+// the ID as the last key so payments created in the same microsecond keep their order.
+func paymentOrderBy(sorts []repository.PaymentSort) (string, error) {
+	if len(sorts) == 0 {
+		sorts = []repository.PaymentSort{{Field: repository.PaymentSortCreatedAt, Descending: true}}
+	}
+
+	keys := make([]string, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column, ok := paymentSortColumns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("unknown payment sort field %q", sort.Field)
+		}
+		keys = append(keys, column+direction(sort.Descending))
+	}
+	keys = append(keys, "id"+direction(sorts[len(sorts)-1].Descending))
+
+	return " ORDER BY " + strings.Join(keys, ", "), nil
+}
+
+func direction(descending bool) string {
+	if descending {
+		return " DESC"
+	}
+	return " ASC"
+}
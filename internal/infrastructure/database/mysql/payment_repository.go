@@ -5,75 +5,200 @@ import (
 	"fmt"
 	"gateway-payments/internal/domain/entity"
 	"gateway-payments/internal/domain/repository"
+	"strings"
+	"time"
 )
 
+// paymentColumns is the column list read by scanPayment.
+const paymentColumns = `id, method, amount_minor, captured_minor, refunded_minor, currency, status, COALESCE(status_reason, ''), COALESCE(processor, ''), COALESCE(processor_reference, ''), order_id, attempt, authorized_at, processing_until, expiry_attempts, next_expiry_attempt_at, created_at, updated_at, version, legal_hold, deleted_at, COALESCE(deleted_by, '')`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (*entity.Payment, error) {
+	payment := &entity.Payment{}
+	var authorizedAt, processingUntil, nextExpiryAttemptAt, deletedAt sql.NullTime
+	err := row.Scan(
+		&payment.ID,
+		&payment.Method,
+		&payment.Amount.Amount,
+		&payment.Captured.Amount,
+		&payment.Refunded.Amount,
+		&payment.Amount.Currency,
+		&payment.Status,
+		&payment.StatusReason,
+		&payment.Processor,
+		&payment.ProcessorReference,
+		&payment.OrderID,
+		&payment.Attempt,
+		&authorizedAt,
+		&processingUntil,
+		&payment.ExpiryAttempts,
+		&nextExpiryAttemptAt,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+		&payment.Version,
+		&payment.LegalHold,
+		&deletedAt,
+		&payment.DeletedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payment.Captured.Currency = payment.Amount.Currency
+	payment.Refunded.Currency = payment.Amount.Currency
+	if authorizedAt.Valid {
+		payment.AuthorizedAt = &authorizedAt.Time
+	}
+	if processingUntil.Valid {
+		payment.ProcessingUntil = &processingUntil.Time
+	}
+	if nextExpiryAttemptAt.Valid {
+		payment.NextExpiryAttemptAt = &nextExpiryAttemptAt.Time
+	}
+	if deletedAt.Valid {
+		payment.DeletedAt = &deletedAt.Time
+	}
+
+	return payment, nil
+}
+
 type PaymentRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
-func NewPaymentRepository(db *sql.DB) *PaymentRepository {
+func NewPaymentRepository(db DBTX) *PaymentRepository {
 	return &PaymentRepository{DB: db}
 }
 
-func (r *PaymentRepository) Save(payment *entity.Payment) error {
+func (r *PaymentRepository) Create(payment *entity.Payment, messages ...*entity.OutboxMessage) error {
 	if payment.Status == "" {
 		payment.Status = entity.StatusPending
 	}
-
-	// Check if the payment already exists to decide between INSERT and UPDATE
-	var exists bool
-	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM payments WHERE id = ?)", payment.ID).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("error checking if payment exists: %w", err)
+	if payment.Attempt == 0 {
+		payment.Attempt = 1
 	}
+	payment.Version = 1
+	payment.UpdatedAt = payment.CreatedAt
 
-	if exists {
-		query := `UPDATE payments SET method = ?, amount = ?, status = ?, order_id = ? WHERE id = ?`
-		_, err := r.DB.Exec(
+	return inTransaction(r.DB, func(tx DBTX) error {
+		query := `INSERT INTO payments (id, method, amount_minor, captured_minor, refunded_minor, currency, status, status_reason, processor, processor_reference, order_id, attempt, authorized_at, processing_until, created_at, updated_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.Exec(
 			query,
+			payment.ID,
 			payment.Method,
-			payment.Amount,
+			payment.Amount.Amount,
+			payment.Captured.Amount,
+			payment.Refunded.Amount,
+			payment.Amount.Currency,
 			payment.Status,
+			payment.StatusReason,
+			payment.Processor,
+			payment.ProcessorReference,
 			payment.OrderID,
-			payment.ID,
+			payment.Attempt,
+			payment.AuthorizedAt,
+			payment.ProcessingUntil,
+			payment.CreatedAt,
+			payment.UpdatedAt,
+			payment.Version,
 		)
 		if err != nil {
-			return fmt.Errorf("error updating payment [%s]: %w", payment.ID, err)
+			if isDuplicateKeyError(err) {
+				return fmt.Errorf("payment attempt %d for order %s conflicts with another attempt: %w", payment.Attempt, payment.OrderID, repository.ErrDuplicateKey)
+			}
+			return fmt.Errorf("error persisting payment [%s]: %w", payment.ID, err)
 		}
-	} else {
-		query := `INSERT INTO payments (id, method, amount, status, order_id, created_at) VALUES (?, ?, ?, ?, ?, ?)`
-		_, err := r.DB.Exec(
+
+		return insertOutboxMessages(tx, messages)
+	})
+}
+
+func (r *PaymentRepository) Update(payment *entity.Payment, messages ...*entity.OutboxMessage) error {
+	updatedAt := time.Now()
+
+	err := inTransaction(r.DB, func(tx DBTX) error {
+		// Compare-and-swap: só grava se ninguém alterou o pagamento desde a leitura
+		query := `UPDATE payments SET method = ?, amount_minor = ?, captured_minor = ?, refunded_minor = ?, currency = ?, status = ?, status_reason = ?, processor = ?, processor_reference = ?, order_id = ?, authorized_at = ?, processing_until = ?, expiry_attempts = ?, next_expiry_attempt_at = ?, legal_hold = ?, deleted_at = ?, deleted_by = NULLIF(?, ''), updated_at = ?, version = version + 1 WHERE id = ? AND version = ?`
+		result, err := tx.Exec(
 			query,
-			payment.ID,
 			payment.Method,
-			payment.Amount,
+			payment.Amount.Amount,
+			payment.Captured.Amount,
+			payment.Refunded.Amount,
+			payment.Amount.Currency,
 			payment.Status,
+			payment.StatusReason,
+			payment.Processor,
+			payment.ProcessorReference,
 			payment.OrderID,
-			payment.CreatedAt,
+			payment.AuthorizedAt,
+			payment.ProcessingUntil,
+			payment.ExpiryAttempts,
+			payment.NextExpiryAttemptAt,
+			payment.LegalHold,
+			payment.DeletedAt,
+			payment.DeletedBy,
+			updatedAt,
+			payment.ID,
+			payment.Version,
 		)
 		if err != nil {
-			return fmt.Errorf("error persisting payment [%s]: %w", payment.ID, err)
+			return fmt.Errorf("error updating payment [%s]: %w", payment.ID, err)
 		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected after update: %w", err)
+		}
+		if rowsAffected == 0 {
+			return r.updateConflict(tx, payment)
+		}
+
+		return insertOutboxMessages(tx, messages)
+	})
+	if err != nil {
+		return err
 	}
 
+	payment.Version++
+	payment.UpdatedAt = updatedAt
 	return nil
 }
 
+// updateConflict tells a payment that was deleted from one whose version moved on.
+func (r *PaymentRepository) updateConflict(tx DBTX, payment *entity.Payment) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM payments WHERE id = ?)", payment.ID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking if payment exists: %w", err)
+	}
+	if !exists {
+		return &repository.ErrNotFound{Message: fmt.Sprintf("payment with ID %s not found", payment.ID)}
+	}
+	return &repository.ErrConcurrentModification{Entity: "payment", ID: payment.ID, Version: payment.Version}
+}
+
 func (r *PaymentRepository) FindByID(id string) (*entity.Payment, error) {
-	payment := &entity.Payment{}
-	query := `SELECT id, method, amount, status, order_id, created_at FROM payments WHERE id = ?`
-	err := r.DB.QueryRow(query, id).Scan(
-		&payment.ID,
-		&payment.Method,
-		&payment.Amount,
-		&payment.Status,
-		&payment.OrderID,
-		&payment.CreatedAt,
-	)
+	return r.findByID(id, false)
+}
+
+func (r *PaymentRepository) FindByIDIncludingDeleted(id string) (*entity.Payment, error) {
+	return r.findByID(id, true)
+}
+
+func (r *PaymentRepository) findByID(id string, includeDeleted bool) (*entity.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = ?`
+	if !includeDeleted {
+		query += ` AND deleted_at IS NULL`
+	}
+	payment, err := scanPayment(r.DB.QueryRow(query, id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("payment with ID %s not found", id)
+			return nil, &repository.ErrNotFound{Message: fmt.Sprintf("payment with ID %s not found", id)}
 		}
 		return nil, fmt.Errorf("error finding payment by ID [%s]: %w", id, err)
 	}
@@ -82,16 +207,8 @@ func (r *PaymentRepository) FindByID(id string) (*entity.Payment, error) {
 }
 
 func (r *PaymentRepository) FindByOrderID(orderID string) (*entity.Payment, error) {
-	payment := &entity.Payment{}
-	query := `SELECT id, method, amount, status, order_id, created_at FROM payments WHERE order_id = ?`
-	err := r.DB.QueryRow(query, orderID).Scan(
-		&payment.ID,
-		&payment.Method,
-		&payment.Amount,
-		&payment.Status,
-		&payment.OrderID,
-		&payment.CreatedAt,
-	)
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = ? ORDER BY attempt DESC LIMIT 1`
+	payment, err := scanPayment(r.DB.QueryRow(query, orderID))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -103,26 +220,21 @@ func (r *PaymentRepository) FindByOrderID(orderID string) (*entity.Payment, erro
 	return payment, nil
 }
 
-func (r *PaymentRepository) FindAll(page, limit int) ([]*entity.Payment, error) {
-	offset := (page - 1) * limit
-	query := `SELECT id, method, amount, status, order_id, created_at FROM payments LIMIT ? OFFSET ?`
-	rows, err := r.DB.Query(query, limit, offset)
+func (r *PaymentRepository) FindAllByOrderID(orderID string, includeDeleted bool) ([]*entity.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = ?`
+	if !includeDeleted {
+		query += ` AND deleted_at IS NULL`
+	}
+	rows, err := r.DB.Query(query+` ORDER BY attempt`, orderID)
 	if err != nil {
-		return nil, fmt.Errorf("error querying payments: %w", err)
+		return nil, fmt.Errorf("error querying payments of order [%s]: %w", orderID, err)
 	}
 	defer rows.Close()
 
 	payments := make([]*entity.Payment, 0)
 	for rows.Next() {
-		payment := &entity.Payment{}
-		if err := rows.Scan(
-			&payment.ID,
-			&payment.Method,
-			&payment.Amount,
-			&payment.Status,
-			&payment.OrderID,
-			&payment.CreatedAt,
-		); err != nil {
+		payment, err := scanPayment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning payment row: %w", err)
 		}
 		payments = append(payments, payment)
@@ -135,21 +247,96 @@ func (r *PaymentRepository) FindAll(page, limit int) ([]*entity.Payment, error)
 	return payments, nil
 }
 
-func (r *PaymentRepository) Delete(id string) error {
-	query := `DELETE FROM payments WHERE id = ?`
-	result, err := r.DB.Exec(query, id)
+func (r *PaymentRepository) FindAll(paymentQuery repository.PaymentQuery) ([]*entity.Payment, error) {
+	where, args := paymentWhere(paymentQuery.Filter)
+	orderBy, err := paymentOrderBy(paymentQuery.Sort)
 	if err != nil {
-		return fmt.Errorf("error deleting payment [%s]: %w", id, err)
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	offset := (paymentQuery.Page - 1) * paymentQuery.Limit
+	if paymentQuery.After != nil {
+		if where, args, err = paymentAfter(where, args, *paymentQuery.After, paymentQuery.Sort); err != nil {
+			return nil, err
+		}
+		offset = 0
+	}
+	query := `SELECT ` + paymentColumns + ` FROM payments` + where + orderBy + ` LIMIT ? OFFSET ?`
+	rows, err := r.DB.Query(query, append(args, paymentQuery.Limit, offset)...)
 	if err != nil {
-		return fmt.Errorf("error getting rows affected after delete: %w", err)
+		return nil, fmt.Errorf("error querying payments: %w", err)
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("payment with ID %s not found for deletion", id)
+	payments := make([]*entity.Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment row: %w", err)
+		}
+		payments = append(payments, payment)
 	}
 
-	return nil
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *PaymentRepository) Count(filter repository.PaymentFilter) (int, error) {
+	where, args := paymentWhere(filter)
+	var total int
+	if err := r.DB.QueryRow(`SELECT COUNT(*) FROM payments`+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting payments: %w", err)
+	}
+
+	return total, nil
+}
+
+func (r *PaymentRepository) FindAuthorizedBefore(before time.Time, now time.Time, limit int) ([]*entity.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE status = ? AND authorized_at < ? AND deleted_at IS NULL AND (next_expiry_attempt_at IS NULL OR next_expiry_attempt_at <= ?) ORDER BY authorized_at LIMIT ?`
+	rows, err := r.DB.Query(query, entity.StatusAuthorized, before, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying expired authorizations: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make([]*entity.Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment row: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *PaymentRepository) PurgeDeletedBefore(before time.Time, limit int) (int64, error) {
+	retained := entity.RetainedStatuses()
+	placeholders := make([]string, len(retained))
+	args := []interface{}{before}
+	for i, status := range retained {
+		placeholders[i] = "?"
+		args = append(args, status)
+	}
+
+	// O status é conferido de novo para que nenhum pagamento com dinheiro movimentado seja apagado
+	query := `DELETE FROM payments WHERE deleted_at < ? AND legal_hold = FALSE AND status NOT IN (` + strings.Join(placeholders, ", ") + `) ORDER BY deleted_at LIMIT ?`
+	result, err := r.DB.Exec(query, append(args, limit)...)
+	if err != nil {
+		return 0, fmt.Errorf("error purging deleted payments: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected after purge: %w", err)
+	}
+	return deleted, nil
 }
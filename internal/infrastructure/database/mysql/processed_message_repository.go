@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"time"
+)
+
+type ProcessedMessageRepository struct {
+	DB DBTX
+}
+
+func NewProcessedMessageRepository(db DBTX) *ProcessedMessageRepository {
+	return &ProcessedMessageRepository{DB: db}
+}
+
+func (r *ProcessedMessageRepository) Claim(message *entity.ProcessedMessage) error {
+	query := `INSERT INTO processed_messages (consumer, message_id, status, locked_until, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := r.DB.Exec(query, message.Consumer, message.MessageID, message.Status, message.LockedUntil, message.CreatedAt)
+	if err == nil {
+		return nil
+	}
+	if !isDuplicateKeyError(err) {
+		return fmt.Errorf("error claiming message [%s] for %s: %w", message.MessageID, message.Consumer, err)
+	}
+
+	// O worker que tinha a mensagem morreu sem liberar nem concluir: assume o lease vencido
+	query = `UPDATE processed_messages SET locked_until = ? WHERE consumer = ? AND message_id = ? AND status = ? AND locked_until < ?`
+	result, err := r.DB.Exec(query, message.LockedUntil, message.Consumer, message.MessageID, entity.ProcessedMessageStatusProcessing, message.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error taking over message [%s] for %s: %w", message.MessageID, message.Consumer, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error taking over message [%s] for %s: %w", message.MessageID, message.Consumer, err)
+	}
+	if rows == 0 {
+		return repository.ErrDuplicateKey
+	}
+
+	return nil
+}
+
+func (r *ProcessedMessageRepository) Find(consumer string, messageID string) (*entity.ProcessedMessage, error) {
+	message := &entity.ProcessedMessage{}
+	var processedAt sql.NullTime
+	query := `SELECT consumer, message_id, status, locked_until, processed_at, created_at FROM processed_messages WHERE consumer = ? AND message_id = ?`
+	err := r.DB.QueryRow(query, consumer, messageID).Scan(
+		&message.Consumer,
+		&message.MessageID,
+		&message.Status,
+		&message.LockedUntil,
+		&processedAt,
+		&message.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &repository.ErrNotFound{Message: fmt.Sprintf("message %s not found for consumer %s", messageID, consumer)}
+		}
+		return nil, fmt.Errorf("error finding processed message [%s]: %w", messageID, err)
+	}
+	if processedAt.Valid {
+		message.ProcessedAt = &processedAt.Time
+	}
+
+	return message, nil
+}
+
+func (r *ProcessedMessageRepository) MarkProcessed(consumer string, messageID string, at time.Time) error {
+	query := `UPDATE processed_messages SET status = ?, processed_at = ? WHERE consumer = ? AND message_id = ?`
+	_, err := r.DB.Exec(query, entity.ProcessedMessageStatusProcessed, at, consumer, messageID)
+	if err != nil {
+		return fmt.Errorf("error marking message [%s] as processed: %w", messageID, err)
+	}
+
+	return nil
+}
+
+func (r *ProcessedMessageRepository) Release(consumer string, messageID string) error {
+	query := `DELETE FROM processed_messages WHERE consumer = ? AND message_id = ? AND status = ?`
+	_, err := r.DB.Exec(query, consumer, messageID, entity.ProcessedMessageStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("error releasing message [%s]: %w", messageID, err)
+	}
+
+	return nil
+}
+
+func (r *ProcessedMessageRepository) DeleteCreatedBefore(before time.Time, limit int) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM processed_messages WHERE created_at < ? LIMIT ?`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting processed messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
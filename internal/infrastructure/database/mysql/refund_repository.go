@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"time"
+)
+
+const refundColumns = `id, payment_id, amount_minor, currency, COALESCE(reason, ''), status, reconcile_attempts, next_reconcile_at, created_at`
+
+func scanRefund(row rowScanner) (*entity.Refund, error) {
+	refund := &entity.Refund{}
+	var nextReconcileAt sql.NullTime
+	err := row.Scan(
+		&refund.ID,
+		&refund.PaymentID,
+		&refund.Amount.Amount,
+		&refund.Amount.Currency,
+		&refund.Reason,
+		&refund.Status,
+		&refund.ReconcileAttempts,
+		&nextReconcileAt,
+		&refund.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if nextReconcileAt.Valid {
+		refund.NextReconcileAt = &nextReconcileAt.Time
+	}
+
+	return refund, nil
+}
+
+type RefundRepository struct {
+	DB DBTX
+}
+
+func NewRefundRepository(db DBTX) *RefundRepository {
+	return &RefundRepository{DB: db}
+}
+
+func (r *RefundRepository) Create(refund *entity.Refund) error {
+	query := `INSERT INTO refunds (id, payment_id, amount_minor, currency, reason, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.DB.Exec(
+		query,
+		refund.ID,
+		refund.PaymentID,
+		refund.Amount.Amount,
+		refund.Amount.Currency,
+		refund.Reason,
+		refund.Status,
+		refund.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error persisting refund [%s]: %w", refund.ID, err)
+	}
+
+	return nil
+}
+
+func (r *RefundRepository) Update(refund *entity.Refund) error {
+	// Só um estorno ainda PENDING pode mudar: o job de conciliação e a requisição original
+	// não aplicam o mesmo estorno duas vezes
+	query := `UPDATE refunds SET status = ?, reconcile_attempts = ?, next_reconcile_at = ? WHERE id = ? AND status = ?`
+	result, err := r.DB.Exec(query, refund.Status, refund.ReconcileAttempts, refund.NextReconcileAt, refund.ID, entity.RefundStatusPending)
+	if err != nil {
+		return fmt.Errorf("error updating refund [%s]: %w", refund.ID, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating refund [%s]: %w", refund.ID, err)
+	}
+	if affected == 0 {
+		return &repository.ErrConcurrentModification{Entity: "refund", ID: refund.ID}
+	}
+
+	return nil
+}
+
+func (r *RefundRepository) FindByPaymentID(paymentID string) ([]*entity.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = ? ORDER BY created_at`
+	return r.query(query, paymentID)
+}
+
+func (r *RefundRepository) FindPendingBefore(before time.Time, now time.Time, limit int) ([]*entity.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE status = ? AND created_at < ? AND (next_reconcile_at IS NULL OR next_reconcile_at <= ?) ORDER BY created_at LIMIT ?`
+	return r.query(query, entity.RefundStatusPending, before, now, limit)
+}
+
+func (r *RefundRepository) query(query string, args ...interface{}) ([]*entity.Refund, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := make([]*entity.Refund, 0)
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning refund row: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return refunds, nil
+}
@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gateway-payments/internal/domain/repository"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so repositories can run on their own
+// or inside the transaction of a UnitOfWork.
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// inTransaction runs fn in a new transaction, or in the caller's when db already is one.
+func inTransaction(db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+type UnitOfWork struct {
+	DB *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{DB: db}
+}
+
+func (u *UnitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(txRepositories{tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// txRepositories builds repositories bound to the transaction of a unit of work.
+type txRepositories struct {
+	tx *sql.Tx
+}
+
+func (r txRepositories) Payments() repository.PaymentRepository {
+	return &PaymentRepository{DB: r.tx}
+}
+
+func (r txRepositories) Refunds() repository.RefundRepository {
+	return &RefundRepository{DB: r.tx}
+}
+
+func (r txRepositories) Outbox() repository.OutboxRepository {
+	return &OutboxRepository{DB: r.tx}
+}
+
+func (r txRepositories) ProcessedMessages() repository.ProcessedMessageRepository {
+	return &ProcessedMessageRepository{DB: r.tx}
+}
+
+func (r txRepositories) DeadLetters() repository.DeadLetterRepository {
+	return &DeadLetterRepository{DB: r.tx}
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed schemas/*.json
+var files embed.FS
+
+// Registry holds the embedded JSON Schemas, named after their file: event types such
+// as "payment.requested" describe the event data, and "*_request" the HTTP bodies.
+type Registry struct {
+	raw     map[string][]byte
+	schemas map[string]*node
+}
+
+// NewRegistry parses every embedded schema.
+func NewRegistry() (*Registry, error) {
+	entries, err := files.ReadDir("schemas")
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &Registry{
+		raw:     make(map[string][]byte, len(entries)),
+		schemas: make(map[string]*node, len(entries)),
+	}
+	for _, entry := range entries {
+		raw, err := files.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		schema, err := parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing schema %s: %w", name, err)
+		}
+		registry.raw[name] = raw
+		registry.schemas[name] = schema
+	}
+
+	return registry, nil
+}
+
+// Names lists the schemas in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.raw))
+	for name := range r.raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Raw returns the schema document as it is embedded.
+func (r *Registry) Raw(name string) ([]byte, bool) {
+	raw, ok := r.raw[name]
+	return raw, ok
+}
+
+// Title returns the title of the schema, for listings.
+func (r *Registry) Title(name string) string {
+	if schema, ok := r.schemas[name]; ok {
+		return schema.Title
+	}
+	return ""
+}
+
+// Validate checks document against the named schema, returning a *validation.ErrValidation
+// with every violation found, or a malformed_request error when it is not valid JSON.
+func (r *Registry) Validate(name string, document []byte) error {
+	schema, ok := r.schemas[name]
+	if !ok {
+		return fmt.Errorf("unknown schema %q", name)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return apperr.Wrap(apperr.CodeMalformedRequest, fmt.Errorf("invalid JSON: %w", err))
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return apperr.New(apperr.CodeMalformedRequest, "invalid JSON: unexpected data after the document")
+	}
+
+	var violations []validation.Violation
+	schema.validate("", value, &violations)
+	if len(violations) > 0 {
+		return &validation.ErrValidation{Schema: name, Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"gateway-payments/internal/domain/validation"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// node is the subset of JSON Schema (draft 2020-12) used by the embedded schemas:
+// type, enum, required, properties, additionalProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, format (date-time, uuid), minimum, maximum and
+// exclusiveMinimum. Annotations such as title and description are kept but not enforced.
+type node struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Types       []string `json:"-"`
+
+	Enum                 []interface{}    `json:"enum"`
+	Required             []string         `json:"required"`
+	Properties           map[string]*node `json:"properties"`
+	AdditionalProperties *bool            `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	MinItems             *int             `json:"minItems"`
+	MaxItems             *int             `json:"maxItems"`
+
+	MinLength *int   `json:"minLength"`
+	MaxLength *int   `json:"maxLength"`
+	Pattern   string `json:"pattern"`
+	Format    string `json:"format"`
+
+	Minimum          *json.Number `json:"minimum"`
+	Maximum          *json.Number `json:"maximum"`
+	ExclusiveMinimum *json.Number `json:"exclusiveMinimum"`
+
+	pattern *regexp.Regexp
+}
+
+func parse(raw []byte) (*node, error) {
+	var schema node
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+	if err := schema.compile(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// UnmarshalJSON accepts "type" as a single name or a list of names.
+func (n *node) UnmarshalJSON(data []byte) error {
+	type plain node
+	var fields struct {
+		*plain
+		Type json.RawMessage `json:"type"`
+	}
+	fields.plain = (*plain)(n)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if len(fields.Type) > 0 {
+		var single string
+		if err := json.Unmarshal(fields.Type, &single); err == nil {
+			n.Types = []string{single}
+		} else if err := json.Unmarshal(fields.Type, &n.Types); err != nil {
+			return fmt.Errorf("invalid type: %s", fields.Type)
+		}
+	}
+	return nil
+}
+
+func (n *node) compile() error {
+	if n.Pattern != "" {
+		pattern, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", n.Pattern, err)
+		}
+		n.pattern = pattern
+	}
+	for name, property := range n.Properties {
+		if err := property.compile(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if n.Items != nil {
+		return n.Items.compile()
+	}
+	return nil
+}
+
+func (n *node) validate(field string, value interface{}, violations *[]validation.Violation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, validation.Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(n.Types) > 0 && !n.matchesType(value) {
+		report("must be of type %s, got %s", strings.Join(n.Types, " or "), typeName(value))
+		return
+	}
+	if len(n.Enum) > 0 && !n.inEnum(value) {
+		report("must be one of %s", enumList(n.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		n.validateObject(field, v, violations)
+	case []interface{}:
+		if n.MinItems != nil && len(v) < *n.MinItems {
+			report("must have at least %d items", *n.MinItems)
+		}
+		if n.MaxItems != nil && len(v) > *n.MaxItems {
+			report("must have at most %d items", *n.MaxItems)
+		}
+		if n.Items != nil {
+			for i, item := range v {
+				n.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n.MinLength != nil && length < *n.MinLength {
+			if *n.MinLength == 1 {
+				report("must not be empty")
+			} else {
+				report("must have at least %d characters", *n.MinLength)
+			}
+		}
+		if n.MaxLength != nil && length > *n.MaxLength {
+			report("must have at most %d characters", *n.MaxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			report("must match %s", n.Pattern)
+		}
+		if message, ok := checkFormat(n.Format, v); !ok {
+			report("%s", message)
+		}
+	case json.Number:
+		n.validateNumber(v, report)
+	}
+}
+
+func (n *node) validateObject(field string, object map[string]interface{}, violations *[]validation.Violation) {
+	for _, name := range n.Required {
+		if _, ok := object[name]; !ok {
+			*violations = append(*violations, validation.Violation{Field: join(field, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := n.Properties[name]
+		if !ok {
+			if n.AdditionalProperties != nil && !*n.AdditionalProperties {
+				*violations = append(*violations, validation.Violation{Field: join(field, name), Message: "is not allowed"})
+			}
+			continue
+		}
+		property.validate(join(field, name), object[name], violations)
+	}
+}
+
+func (n *node) validateNumber(number json.Number, report func(string, ...interface{})) {
+	value, ok := new(big.Rat).SetString(number.String())
+	if !ok {
+		report("must be a number")
+		return
+	}
+	if n.Minimum != nil && value.Cmp(rat(*n.Minimum)) < 0 {
+		report("must be at least %s", n.Minimum)
+	}
+	if n.Maximum != nil && value.Cmp(rat(*n.Maximum)) > 0 {
+		report("must be at most %s", n.Maximum)
+	}
+	if n.ExclusiveMinimum != nil && value.Cmp(rat(*n.ExclusiveMinimum)) <= 0 {
+		report("must be greater than %s", n.ExclusiveMinimum)
+	}
+}
+
+func (n *node) matchesType(value interface{}) bool {
+	actual := typeName(value)
+	for _, expected := range n.Types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node) inEnum(value interface{}) bool {
+	for _, allowed := range n.Enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) && typeName(allowed) == typeName(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func checkFormat(format string, value string) (string, bool) {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return "must be an RFC 3339 date-time", false
+		}
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return "must be a UUID", false
+		}
+	}
+	return "", true
+}
+
+func enumList(values []interface{}) string {
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = fmt.Sprint(value)
+	}
+	return strings.Join(items, ", ")
+}
+
+func rat(number json.Number) *big.Rat {
+	value, ok := new(big.Rat).SetString(number.String())
+	if !ok {
+		return new(big.Rat)
+	}
+	return value
+}
+
+func join(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
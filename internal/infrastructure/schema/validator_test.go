@@ -0,0 +1,192 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"gateway-payments/internal/domain/validation"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["id", "amount", "status"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"amount": {"type": ["string", "number"], "exclusiveMinimum": 0, "maximum": 1000},
+		"currency": {"type": "string", "pattern": "^[A-Z]{3}$"},
+		"status": {"enum": ["PENDING", "APPROVED"]},
+		"attempt": {"type": "integer", "minimum": 1},
+		"reason": {"type": "string", "minLength": 1, "maxLength": 5},
+		"created_at": {"type": "string", "format": "date-time"},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	schema, err := parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const valid = `"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": 10.5, "status": "PENDING"`
+	tests := []struct {
+		name     string
+		document string
+		want     []validation.Violation
+	}{
+		{name: "valid", document: `{` + valid + `}`},
+		{name: "valid with every optional field", document: `{` + valid + `, "currency": "BRL", "attempt": 2, "reason": "ok", "created_at": "2024-05-01T10:00:00.123Z", "tags": ["a"]}`},
+		{name: "amount as a string", document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": "10.50", "status": "APPROVED"}`},
+		{
+			name:     "not an object",
+			document: `[]`,
+			want:     []validation.Violation{{Field: "", Message: "must be of type object, got array"}},
+		},
+		{
+			name:     "required",
+			document: `{"amount": 1}`,
+			want: []validation.Violation{
+				{Field: "id", Message: "is required"},
+				{Field: "status", Message: "is required"},
+			},
+		},
+		{
+			name:     "additional property",
+			document: `{` + valid + `, "extra": true}`,
+			want:     []validation.Violation{{Field: "extra", Message: "is not allowed"}},
+		},
+		{
+			name:     "wrong type",
+			document: `{` + valid + `, "attempt": "2", "reason": 5}`,
+			want: []validation.Violation{
+				{Field: "attempt", Message: "must be of type integer, got string"},
+				{Field: "reason", Message: "must be of type string, got integer"},
+			},
+		},
+		{
+			name:     "number is not an integer",
+			document: `{` + valid + `, "attempt": 1.5}`,
+			want:     []validation.Violation{{Field: "attempt", Message: "must be of type integer, got number"}},
+		},
+		{
+			name:     "null is a type of its own",
+			document: `{"id": null, "amount": 1, "status": "PENDING"}`,
+			want:     []validation.Violation{{Field: "id", Message: "must be of type string, got null"}},
+		},
+		{
+			name:     "enum",
+			document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": 1, "status": "pending"}`,
+			want:     []validation.Violation{{Field: "status", Message: "must be one of PENDING, APPROVED"}},
+		},
+		{
+			name:     "pattern",
+			document: `{` + valid + `, "currency": "brl"}`,
+			want:     []validation.Violation{{Field: "currency", Message: "must match ^[A-Z]{3}$"}},
+		},
+		{
+			name:     "uuid format",
+			document: `{"id": "payment-1", "amount": 1, "status": "PENDING"}`,
+			want:     []validation.Violation{{Field: "id", Message: "must be a UUID"}},
+		},
+		{
+			name:     "date-time format",
+			document: `{` + valid + `, "created_at": "2024-05-01 10:00"}`,
+			want:     []validation.Violation{{Field: "created_at", Message: "must be an RFC 3339 date-time"}},
+		},
+		{
+			name:     "exclusiveMinimum rejects the bound",
+			document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": 0, "status": "PENDING"}`,
+			want:     []validation.Violation{{Field: "amount", Message: "must be greater than 0"}},
+		},
+		{
+			name:     "exclusiveMinimum rejects negatives",
+			document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": -0.01, "status": "PENDING"}`,
+			want:     []validation.Violation{{Field: "amount", Message: "must be greater than 0"}},
+		},
+		{
+			name:     "exclusiveMinimum accepts the smallest amount above",
+			document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": 0.0000001, "status": "PENDING"}`,
+		},
+		{
+			name:     "maximum and minimum",
+			document: `{"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7", "amount": 1000.01, "status": "PENDING", "attempt": 0}`,
+			want: []validation.Violation{
+				{Field: "amount", Message: "must be at most 1000"},
+				{Field: "attempt", Message: "must be at least 1"},
+			},
+		},
+		{
+			name:     "string lengths",
+			document: `{` + valid + `, "reason": ""}`,
+			want:     []validation.Violation{{Field: "reason", Message: "must not be empty"}},
+		},
+		{
+			name:     "string lengths count characters",
+			document: `{` + valid + `, "reason": "ação!!"}`,
+			want:     []validation.Violation{{Field: "reason", Message: "must have at most 5 characters"}},
+		},
+		{
+			name:     "array items",
+			document: `{` + valid + `, "tags": ["a", 1, "c"]}`,
+			want: []validation.Violation{
+				{Field: "tags", Message: "must have at most 2 items"},
+				{Field: "tags[1]", Message: "must be of type string, got integer"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := json.NewDecoder(bytes.NewReader([]byte(tt.document)))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				t.Fatal(err)
+			}
+
+			var violations []validation.Violation
+			schema.validate("", value, &violations)
+			if !reflect.DeepEqual(violations, tt.want) {
+				t.Errorf("violations = %+v, want %+v", violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidPattern(t *testing.T) {
+	if _, err := parse([]byte(`{"properties": {"currency": {"pattern": "[A-Z"}}}`)); err == nil {
+		t.Error("parse() accepted an invalid pattern")
+	}
+}
+
+func TestRegistryValidate(t *testing.T) {
+	registry, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry() = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  bool
+	}{
+		{name: "valid", document: `{"order_id": "order-1", "amount": "100.00", "currency": "BRL", "method": "PIX"}`},
+		{name: "schema violation", document: `{"order_id": "order-1", "amount": "abc", "method": "PIX"}`, wantErr: true},
+		{name: "malformed", document: `{"order_id": `, wantErr: true},
+		{name: "trailing data", document: `{"order_id": "order-1", "amount": "100.00", "method": "PIX"} {}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.Validate("payment.requested", []byte(tt.document))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := registry.Validate("unknown", []byte(`{}`)); err == nil {
+		t.Error("Validate() accepted an unknown schema")
+	}
+}
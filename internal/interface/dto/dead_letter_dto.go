@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+// DeadLetterActionRequestSchema is the JSON Schema of DeadLetterActionRequest
+const DeadLetterActionRequestSchema = "dead_letter_action_request"
+
+type DeadLetterActionRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+type DeadLetterResponse struct {
+	ID            string                 `json:"id"`
+	MessageID     string                 `json:"message_id,omitempty"`
+	Exchange      string                 `json:"exchange"`
+	RoutingKey    string                 `json:"routing_key"`
+	ContentType   string                 `json:"content_type,omitempty"`
+	Headers       map[string]interface{} `json:"headers"`
+	Body          json.RawMessage        `json:"body,omitempty"`
+	BodyBase64    string                 `json:"body_base64,omitempty"` // set instead of body when it is not JSON
+	FailureKind   string                 `json:"failure_kind,omitempty"`
+	FailureReason string                 `json:"failure_reason,omitempty"`
+	Attempts      int                    `json:"attempts"`
+	Status        string                 `json:"status"`
+	ReplayCount   int                    `json:"replay_count"`
+	ReceivedAt    time.Time              `json:"received_at"`
+	ReplayedAt    *time.Time             `json:"replayed_at,omitempty"`
+}
+
+func CreateDeadLetterResponse(deadLetter *entity.DeadLetter) *DeadLetterResponse {
+	response := &DeadLetterResponse{
+		ID:            deadLetter.ID,
+		MessageID:     deadLetter.MessageID,
+		Exchange:      deadLetter.Exchange,
+		RoutingKey:    deadLetter.RoutingKey,
+		ContentType:   deadLetter.ContentType,
+		Headers:       deadLetter.Headers,
+		FailureKind:   deadLetter.FailureKind,
+		FailureReason: deadLetter.FailureReason,
+		Attempts:      deadLetter.Attempts,
+		Status:        deadLetter.Status,
+		ReplayCount:   deadLetter.ReplayCount,
+		ReceivedAt:    deadLetter.ReceivedAt,
+		ReplayedAt:    deadLetter.ReplayedAt,
+	}
+	if json.Valid(deadLetter.Body) {
+		response.Body = json.RawMessage(deadLetter.Body)
+	} else {
+		response.BodyBase64 = base64.StdEncoding.EncodeToString(deadLetter.Body)
+	}
+	return response
+}
+
+type DeadLetterActionFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+type DeadLetterActionResponse struct {
+	Succeeded []string                  `json:"succeeded"`
+	Failed    []DeadLetterActionFailure `json:"failed"`
+}
@@ -1,36 +1,123 @@
 package dto
 
 import (
+	"encoding/json"
 	"gateway-payments/internal/domain/entity"
 	"time"
 )
 
+// JSON Schemas of the request bodies, embedded in internal/infrastructure/schema
+const (
+	CreatePaymentRequestSchema  = "create_payment_request"
+	UpdatePaymentRequestSchema  = "update_payment_request"
+	CapturePaymentRequestSchema = "capture_payment_request"
+	VoidPaymentRequestSchema    = "void_payment_request"
+	LegalHoldRequestSchema      = "legal_hold_request"
+)
+
 type CreatePaymentRequest struct {
-	Amount  float64 `json:"amount"`
-	Method  string  `json:"method"`
-	OrderID string  `json:"order_id"`
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+	Method   string      `json:"method"`
+	OrderID  string      `json:"order_id"`
+	Capture  *bool       `json:"capture,omitempty"` // false only authorizes; defaults to true
+	// CardToken is forwarded to the processor and never stored
+	CardToken string `json:"card_token,omitempty"`
 }
 
 type UpdatePaymentRequest struct {
 	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type CapturePaymentRequest struct {
+	Amount json.Number `json:"amount"` // defaults to the full authorized amount
+}
+
+type VoidPaymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+type LegalHoldRequest struct {
+	Reason string `json:"reason"` // recorded in the audit log
 }
 
 type PaymentResponse struct {
-	ID        string    `json:"id"`
-	OrderID   string    `json:"order_id"`
-	Amount    float64   `json:"amount"`
-	Method    string    `json:"method"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string      `json:"id"`
+	OrderID       string      `json:"order_id"`
+	Attempt       int         `json:"attempt"`
+	Amount        json.Number `json:"amount"`
+	AmountMinor   int64       `json:"amount_minor"`
+	Captured      json.Number `json:"captured"`
+	CapturedMinor int64       `json:"captured_minor"`
+	Refunded      json.Number `json:"refunded"`
+	RefundedMinor int64       `json:"refunded_minor"`
+	Currency      string      `json:"currency"`
+	Method        string      `json:"method"`
+	Status        string      `json:"status"`
+	StatusReason  string      `json:"status_reason,omitempty"`
+	AuthorizedAt  *time.Time  `json:"authorized_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	Version       int64       `json:"version"`
+	LegalHold     bool        `json:"legal_hold"`
+	DeletedAt     *time.Time  `json:"deleted_at,omitempty"`
+	DeletedBy     string      `json:"deleted_by,omitempty"`
 }
 
 func CreatePaymentResponse(payment *entity.Payment) *PaymentResponse {
 	return &PaymentResponse{
-		ID:        payment.ID,
-		OrderID:   payment.OrderID,
-		Method:    payment.Method,
-		Amount:    payment.Amount,
-		Status:    payment.Status,
-		CreatedAt: payment.CreatedAt,
+		ID:            payment.ID,
+		OrderID:       payment.OrderID,
+		Attempt:       payment.Attempt,
+		Method:        payment.Method,
+		Amount:        json.Number(payment.Amount.Decimal()),
+		AmountMinor:   payment.Amount.Amount,
+		Captured:      json.Number(payment.Captured.Decimal()),
+		CapturedMinor: payment.Captured.Amount,
+		Refunded:      json.Number(payment.Refunded.Decimal()),
+		RefundedMinor: payment.Refunded.Amount,
+		Currency:      payment.Amount.Currency,
+		Status:        payment.Status,
+		StatusReason:  payment.StatusReason,
+		AuthorizedAt:  payment.AuthorizedAt,
+		CreatedAt:     payment.CreatedAt,
+		UpdatedAt:     payment.UpdatedAt,
+		Version:       payment.Version,
+		LegalHold:     payment.LegalHold,
+		DeletedAt:     payment.DeletedAt,
+		DeletedBy:     payment.DeletedBy,
 	}
 }
+
+// PaymentListResponse is a page of GET /payments with the links to the other pages;
+// Prev and Next are omitted on the first and last pages. Pages requested by cursor
+// are not counted, so they have no page number, total or last link.
+type PaymentListResponse struct {
+	Data       []*PaymentResponse `json:"data"`
+	Pagination Pagination         `json:"pagination"`
+	Links      PageLinks          `json:"links"`
+}
+
+type Pagination struct {
+	Page       *int   `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      *int   `json:"total,omitempty"`
+	TotalPages *int   `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+type PageLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// OrderPaymentsResponse lists the payment attempts of an order, oldest first
+type OrderPaymentsResponse struct {
+	OrderID string             `json:"order_id"`
+	Data    []*PaymentResponse `json:"data"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"time"
+)
+
+// CreateRefundRequestSchema is the JSON Schema of CreateRefundRequest
+const CreateRefundRequestSchema = "create_refund_request"
+
+type CreateRefundRequest struct {
+	Amount json.Number `json:"amount"`
+	Reason string      `json:"reason"`
+}
+
+type RefundResponse struct {
+	ID          string      `json:"id"`
+	PaymentID   string      `json:"payment_id"`
+	Amount      json.Number `json:"amount"`
+	AmountMinor int64       `json:"amount_minor"`
+	Currency    string      `json:"currency"`
+	Reason      string      `json:"reason,omitempty"`
+	Status      string      `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+func CreateRefundResponse(refund *entity.Refund) *RefundResponse {
+	return &RefundResponse{
+		ID:          refund.ID,
+		PaymentID:   refund.PaymentID,
+		Amount:      json.Number(refund.Amount.Decimal()),
+		AmountMinor: refund.Amount.Amount,
+		Currency:    refund.Amount.Currency,
+		Reason:      refund.Reason,
+		Status:      refund.Status,
+		CreatedAt:   refund.CreatedAt,
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"gateway-payments/internal/domain/apperr"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminAuthMiddleware requires "Authorization: Bearer <token>" on the admin API.
+// Without a token configured the admin API is disabled.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authorizeAdmin(w, r, token) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AdminQueryMiddleware requires the admin token only on requests that set the boolean
+// query parameter, such as include_deleted, to true. Other requests are public.
+func AdminQueryMiddleware(token string, parameter string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			enabled, err := boolParameter(r, parameter)
+			if err != nil {
+				respondWithError(w, r, err)
+				return
+			}
+			if enabled && !authorizeAdmin(w, r, token) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorizeAdmin checks the admin token, sending the error response when it is missing or wrong
+func authorizeAdmin(w http.ResponseWriter, r *http.Request, token string) bool {
+	if token == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeForbidden, "admin API disabled, set ADMIN_TOKEN to enable it"))
+		return false
+	}
+
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondWithError(w, r, apperr.New(apperr.CodeUnauthorized, "invalid admin token"))
+		return false
+	}
+	return true
+}
+
+// boolParameter parses an optional boolean query parameter, false when absent
+func boolParameter(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, apperr.New(apperr.CodeInvalidParameter, "invalid "+name+" "+strconv.Quote(value)+": expected true or false")
+	}
+	return enabled, nil
+}
+
+// adminActor names the operator of an admin request for the audit log
+func adminActor(r *http.Request) string {
+	if actor := r.Header.Get(AdminActorHeader); actor != "" {
+		return actor
+	}
+	return defaultAdminActor
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"gateway-payments/internal/domain/event"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CorrelationIDHeader carries the ID shared by the requests and events of one business flow.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength keeps client-provided IDs to a sane size.
+const maxCorrelationIDLength = 128
+
+// CorrelationMiddleware reads the correlation ID of the request, or starts a new one,
+// echoes it in the response and makes the events published by the request carry it.
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationID := r.Header.Get(CorrelationIDHeader)
+		if correlationID == "" || len(correlationID) > maxCorrelationIDLength {
+			correlationID = uuid.NewString()
+		}
+
+		w.Header().Set(CorrelationIDHeader, correlationID)
+		ctx := event.WithMetadata(r.Context(), event.Metadata{CorrelationID: correlationID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
+	"gateway-payments/internal/interface/dto"
+	"gateway-payments/internal/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminActorHeader names the operator recorded in the audit log for admin actions.
+const AdminActorHeader = "X-Admin-Actor"
+
+// defaultAdminActor is recorded when the request does not name an operator.
+const defaultAdminActor = "admin-api"
+
+type DeadLetterHandler struct {
+	GetDeadLetters    *usecase.GetDeadLetters
+	GetDeadLetter     *usecase.GetDeadLetter
+	ReplayDeadLetters *usecase.ReplayDeadLetters
+	PurgeDeadLetters  *usecase.PurgeDeadLetters
+	Validator         validation.Validator
+}
+
+func NewDeadLetterHandler(
+	getDeadLetters *usecase.GetDeadLetters,
+	getDeadLetter *usecase.GetDeadLetter,
+	replayDeadLetters *usecase.ReplayDeadLetters,
+	purgeDeadLetters *usecase.PurgeDeadLetters,
+	validator validation.Validator,
+) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		GetDeadLetters:    getDeadLetters,
+		GetDeadLetter:     getDeadLetter,
+		ReplayDeadLetters: replayDeadLetters,
+		PurgeDeadLetters:  purgeDeadLetters,
+		Validator:         validator,
+	}
+}
+
+func (h *DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	deadLetters, err := h.GetDeadLetters.Execute(usecase.GetDeadLettersInput{
+		Status: strings.ToUpper(r.URL.Query().Get("status")),
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	responses := make([]*dto.DeadLetterResponse, len(deadLetters))
+	for i, deadLetter := range deadLetters {
+		responses[i] = dto.CreateDeadLetterResponse(deadLetter)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responses)
+}
+
+func (h *DeadLetterHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "dead letter ID is required"))
+		return
+	}
+
+	deadLetter, err := h.GetDeadLetter.Execute(id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.CreateDeadLetterResponse(deadLetter))
+}
+
+// Replay publishes the dead letters selected in the body, or the one in the path, again
+func (h *DeadLetterHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	input, ok := h.deadLetterActionInput(w, r)
+	if !ok {
+		return
+	}
+
+	output, err := h.ReplayDeadLetters.Execute(r.Context(), input)
+	respondWithDeadLetterAction(w, r, output, err)
+}
+
+// Purge deletes the dead letters selected in the body, or the one in the path
+func (h *DeadLetterHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	input, ok := h.deadLetterActionInput(w, r)
+	if !ok {
+		return
+	}
+
+	output, err := h.PurgeDeadLetters.Execute(input)
+	respondWithDeadLetterAction(w, r, output, err)
+}
+
+func (h *DeadLetterHandler) deadLetterActionInput(w http.ResponseWriter, r *http.Request) (usecase.DeadLetterActionInput, bool) {
+	input := usecase.DeadLetterActionInput{Actor: adminActor(r)}
+
+	if id := chi.URLParam(r, "id"); id != "" {
+		input.IDs = []string{id}
+		return input, true
+	}
+
+	var request dto.DeadLetterActionRequest
+	if !decodeBody(w, r, h.Validator, dto.DeadLetterActionRequestSchema, false, &request) {
+		return input, false
+	}
+	input.IDs = request.IDs
+	input.All = request.All
+	return input, true
+}
+
+func respondWithDeadLetterAction(w http.ResponseWriter, r *http.Request, output *usecase.DeadLetterActionOutput, err error) {
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	response := dto.DeadLetterActionResponse{
+		Succeeded: output.Succeeded,
+		Failed:    make([]dto.DeadLetterActionFailure, len(output.Failed)),
+	}
+	for i, failure := range output.Failed {
+		response.Failed[i] = dto.DeadLetterActionFailure{ID: failure.ID, Error: failure.Error}
+	}
+
+	// Messages that could not be processed are reported one by one in the body
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
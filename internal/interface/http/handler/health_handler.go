@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Pinger is implemented by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// ConnectionState is implemented by the broker clients.
+type ConnectionState interface {
+	Connected() bool
+}
+
+type HealthHandler struct {
+	DB     Pinger
+	Broker ConnectionState
+}
+
+func NewHealthHandler(db Pinger, broker ConnectionState) *HealthHandler {
+	return &HealthHandler{
+		DB:     db,
+		Broker: broker,
+	}
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Live reports that the process is up
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready reports whether the database and the broker are reachable, so the instance
+// is taken out of rotation while RabbitMQ is reconnecting
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	response := readinessResponse{Status: "ok", Checks: map[string]string{"database": "ok", "broker": "ok"}}
+	status := http.StatusOK
+
+	if err := h.DB.PingContext(ctx); err != nil {
+		response.Checks["database"] = err.Error()
+		response.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	if !h.Broker.Connected() {
+		response.Checks["broker"] = "disconnected"
+		response.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
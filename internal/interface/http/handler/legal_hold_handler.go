@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
+	"gateway-payments/internal/interface/dto"
+	"gateway-payments/internal/usecase"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LegalHoldHandler places and releases legal holds on payments through the admin API
+type LegalHoldHandler struct {
+	SetLegalHold *usecase.SetLegalHold
+	Validator    validation.Validator
+}
+
+func NewLegalHoldHandler(setLegalHold *usecase.SetLegalHold, validator validation.Validator) *LegalHoldHandler {
+	return &LegalHoldHandler{
+		SetLegalHold: setLegalHold,
+		Validator:    validator,
+	}
+}
+
+// Place puts the payment under legal hold, even if it was deleted
+func (h *LegalHoldHandler) Place(w http.ResponseWriter, r *http.Request) {
+	h.set(w, r, true)
+}
+
+// Release lifts the legal hold of the payment
+func (h *LegalHoldHandler) Release(w http.ResponseWriter, r *http.Request) {
+	h.set(w, r, false)
+}
+
+func (h *LegalHoldHandler) set(w http.ResponseWriter, r *http.Request, hold bool) {
+	paymentID := chi.URLParam(r, "id")
+	if paymentID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	var input dto.LegalHoldRequest
+	if !decodeBody(w, r, h.Validator, dto.LegalHoldRequestSchema, true, &input) {
+		return
+	}
+
+	payment, err := h.SetLegalHold.Execute(usecase.SetLegalHoldInput{
+		ID:     paymentID,
+		Hold:   hold,
+		Reason: input.Reason,
+		Actor:  adminActor(r),
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.CreatePaymentResponse(payment))
+}
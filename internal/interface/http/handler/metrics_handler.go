@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"gateway-payments/internal/usecase"
+	"net/http"
+)
+
+// publishFailureReasons are always exported, so a failure rate can be computed from zero
+var publishFailureReasons = []string{
+	usecase.PublishFailureUnroutable,
+	usecase.PublishFailureNacked,
+	usecase.PublishFailureConfirmTimeout,
+	usecase.PublishFailureError,
+}
+
+type MetricsHandler struct {
+	OutboxRelay *usecase.OutboxRelay
+}
+
+func NewMetricsHandler(outboxRelay *usecase.OutboxRelay) *MetricsHandler {
+	return &MetricsHandler{
+		OutboxRelay: outboxRelay,
+	}
+}
+
+// Metrics exposes the relay metrics in the Prometheus text format
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	outbox, err := h.OutboxRelay.Metrics()
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintln(w, "# HELP gateway_outbox_pending_messages Outbox messages not yet published.")
+	fmt.Fprintln(w, "# TYPE gateway_outbox_pending_messages gauge")
+	fmt.Fprintf(w, "gateway_outbox_pending_messages %d\n", outbox.Pending)
+	fmt.Fprintln(w, "# HELP gateway_outbox_lag_seconds Age of the oldest unpublished outbox message.")
+	fmt.Fprintln(w, "# TYPE gateway_outbox_lag_seconds gauge")
+	fmt.Fprintf(w, "gateway_outbox_lag_seconds %.3f\n", outbox.LagSeconds)
+	fmt.Fprintln(w, "# HELP gateway_outbox_sent_total Outbox messages published by this instance.")
+	fmt.Fprintln(w, "# TYPE gateway_outbox_sent_total counter")
+	fmt.Fprintf(w, "gateway_outbox_sent_total %d\n", outbox.SentTotal)
+	fmt.Fprintln(w, "# HELP gateway_outbox_publish_failures_total Failed outbox publish attempts by this instance.")
+	fmt.Fprintln(w, "# TYPE gateway_outbox_publish_failures_total counter")
+	for _, reason := range publishFailureReasons {
+		fmt.Fprintf(w, "gateway_outbox_publish_failures_total{reason=%q} %d\n", reason, outbox.FailedByReason[reason])
+	}
+}
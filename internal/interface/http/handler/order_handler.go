@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/interface/dto"
+	"gateway-payments/internal/usecase"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OrderHandler serves lookups by the order IDs of the e-commerce side
+type OrderHandler struct {
+	GetOrderPayments *usecase.GetOrderPayments
+}
+
+func NewOrderHandler(getOrderPayments *usecase.GetOrderPayments) *OrderHandler {
+	return &OrderHandler{
+		GetOrderPayments: getOrderPayments,
+	}
+}
+
+// ListPayments returns every payment attempt of the order, or 404 when it has none
+func (h *OrderHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "order_id")
+	if orderID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "order ID is required"))
+		return
+	}
+
+	includeDeleted, err := boolParameter(r, "include_deleted")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	payments, err := h.GetOrderPayments.Execute(usecase.GetOrderPaymentsInput{
+		OrderID:        orderID,
+		IncludeDeleted: includeDeleted,
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	response := dto.OrderPaymentsResponse{
+		OrderID: orderID,
+		Data:    make([]*dto.PaymentResponse, len(payments)),
+	}
+	for i, payment := range payments {
+		response.Data[i] = dto.CreatePaymentResponse(payment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
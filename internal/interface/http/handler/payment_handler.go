@@ -1,27 +1,57 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
 	"gateway-payments/internal/interface/dto"
 	"gateway-payments/internal/usecase"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// ErrorResponse represents a standardized JSON error response
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
+// IdempotencyKeyHeader is the request header clients use to make POST /payments safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the size of the idempotency_keys.idempotency_key column.
+const maxIdempotencyKeyLength = 255
+
+// ActorHeader names who deletes a payment, recorded in its deleted_by and in the audit log.
+const ActorHeader = "X-Actor"
+
+// defaultActor is recorded when a public request does not name who made it.
+const defaultActor = "api"
 
-// respondWithError sends a JSON error response
-func respondWithError(w http.ResponseWriter, code int, message string) {
+// maxRequestBodySize limits how much of a request body is read into memory.
+const maxRequestBodySize = 1 << 20
+
+// writeJSON sends an already encoded JSON body
+func writeJSON(w http.ResponseWriter, code int, body []byte) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(ErrorResponse{Message: message})
+	w.Write(body)
+}
+
+// requestFingerprint identifies a request by its method, path and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
 }
 
 type PaymentHandler struct {
@@ -30,6 +60,10 @@ type PaymentHandler struct {
 	GetPayment     *usecase.GetPayment
 	GetAllPayments *usecase.GetAllPayments
 	DeletePayment  *usecase.DeletePayment
+	CapturePayment *usecase.CapturePayment
+	VoidPayment    *usecase.VoidPayment
+	Idempotency    *usecase.Idempotency
+	Validator      validation.Validator
 }
 
 func NewPaymentHandler(
@@ -38,6 +72,10 @@ func NewPaymentHandler(
 	getPayment *usecase.GetPayment,
 	getAllPayments *usecase.GetAllPayments,
 	deletePayment *usecase.DeletePayment,
+	capturePayment *usecase.CapturePayment,
+	voidPayment *usecase.VoidPayment,
+	idempotency *usecase.Idempotency,
+	validator validation.Validator,
 ) *PaymentHandler {
 	return &PaymentHandler{
 		CreatePayment:  createPayment,
@@ -45,34 +83,126 @@ func NewPaymentHandler(
 		GetPayment:     getPayment,
 		GetAllPayments: getAllPayments,
 		DeletePayment:  deletePayment,
+		CapturePayment: capturePayment,
+		VoidPayment:    voidPayment,
+		Idempotency:    idempotency,
+		Validator:      validator,
+	}
+}
+
+func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		respondWithError(w, r, apperr.Wrap(apperr.CodeMalformedRequest, err))
+		return
+	}
+
+	idempotencyKey := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, fmt.Sprintf("%s must have at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+		return
+	}
+
+	if idempotencyKey != "" {
+		stored, err := h.Idempotency.Begin(usecase.IdempotencyInput{
+			Key:         idempotencyKey,
+			RequestHash: requestFingerprint(r, body),
+		})
+		if err != nil {
+			respondWithError(w, r, err)
+			return
+		}
+		if stored != nil {
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeCreateResponse(w, stored.StatusCode, stored.ResponseBody)
+			return
+		}
+	}
+
+	statusCode := http.StatusCreated
+	responseBody, createErr := h.create(r, body)
+	if createErr != nil {
+		statusCode, responseBody = problemBody(r, createErr)
+	}
+
+	if idempotencyKey != "" {
+		// Retryable failures are not remembered so the client can retry with the same key.
+		if createErr != nil && apperr.Lookup(apperr.CodeOf(createErr)).Retryable {
+			err = h.Idempotency.Release(idempotencyKey)
+		} else {
+			err = h.Idempotency.Complete(idempotencyKey, statusCode, responseBody)
+		}
+		if err != nil {
+			log.Printf("Error storing idempotency key %s: %v", idempotencyKey, err)
+		}
+	}
+
+	writeCreateResponse(w, statusCode, responseBody)
+}
+
+// writeCreateResponse sends a body built by create, which is a problem document for errors
+func writeCreateResponse(w http.ResponseWriter, code int, body []byte) {
+	if code >= http.StatusBadRequest {
+		writeProblem(w, code, body)
+		return
 	}
+	writeJSON(w, code, body)
+}
+
+// create runs the use case and returns the JSON body of the created payment.
+func (h *PaymentHandler) create(r *http.Request, body []byte) ([]byte, error) {
+	if err := h.Validator.Validate(dto.CreatePaymentRequestSchema, body); err != nil {
+		return nil, err
+	}
+
+	var input dto.CreatePaymentRequest
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(&input); err != nil {
+		return nil, apperr.Wrap(apperr.CodeMalformedRequest, err)
+	}
+
+	payment, err := h.CreatePayment.Execute(r.Context(), usecase.CreatePaymentInput{
+		OrderID:  input.OrderID,
+		Amount:   input.Amount.String(),
+		Currency: input.Currency,
+		Method:   input.Method,
+
+		AuthorizeOnly: input.Capture != nil && !*input.Capture,
+		SourceToken:   input.CardToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := json.Marshal(dto.CreatePaymentResponse(payment))
+	if err != nil {
+		return nil, err
+	}
+
+	return responseBody, nil
 }
 
 func (h *PaymentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	paymentID := chi.URLParam(r, "id")
 	if paymentID == "" {
-		respondWithError(w, http.StatusBadRequest, "payment ID is required")
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
 		return
 	}
 
 	var input dto.UpdatePaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	if !decodeBody(w, r, h.Validator, dto.UpdatePaymentRequestSchema, false, &input) {
 		return
 	}
 
 	usecaseInput := usecase.UpdatePaymentInput{
 		ID:     paymentID,
 		Status: input.Status,
+		Reason: input.Reason,
 	}
 
 	err := h.UpdatePayment.Execute(r.Context(), usecaseInput)
 	if err != nil {
-		if errors.Is(err, errors.New("payment not found")) {
-			respondWithError(w, http.StatusNotFound, err.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, err)
 		return
 	}
 
@@ -82,23 +212,24 @@ func (h *PaymentHandler) Update(w http.ResponseWriter, r *http.Request) {
 func (h *PaymentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	paymentID := chi.URLParam(r, "id")
 	if paymentID == "" {
-		respondWithError(w, http.StatusBadRequest, "payment ID is required")
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	includeDeleted, err := boolParameter(r, "include_deleted")
+	if err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
 	usecaseInput := usecase.GetPaymentInput{
-		ID: paymentID,
+		ID:             paymentID,
+		IncludeDeleted: includeDeleted,
 	}
 
 	payment, err := h.GetPayment.Execute(usecaseInput)
 	if err != nil {
-		// It's better to check the specific error returned by the use case (e.g., from repository)
-		// For now, a generic "payment not found" check is used.
-		if err.Error() == fmt.Sprintf("payment with ID %s not found", paymentID) { // Specific error check
-			respondWithError(w, http.StatusNotFound, err.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, err)
 		return
 	}
 
@@ -109,28 +240,49 @@ func (h *PaymentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// List returns a filtered, sorted page of payments. Filters that accept several values
+// take them separated by commas, e.g. status=APPROVED,CAPTURED.
 func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
+	query := r.URL.Query()
 
-	page, err := strconv.Atoi(pageStr)
+	page, err := strconv.Atoi(query.Get("page"))
 	if err != nil || page <= 0 {
 		page = 1
 	}
 
-	limit, err := strconv.Atoi(limitStr)
+	limit, err := strconv.Atoi(query.Get("limit"))
 	if err != nil || limit <= 0 {
 		limit = 10
 	}
 
 	usecaseInput := usecase.GetAllPaymentsInput{
-		Page:  page,
-		Limit: limit,
+		Statuses:  listParameter(query.Get("status")),
+		Methods:   listParameter(query.Get("method")),
+		OrderID:   query.Get("order_id"),
+		Currency:  query.Get("currency"),
+		MinAmount: query.Get("amount_min"),
+		MaxAmount: query.Get("amount_max"),
+		Sort:      query.Get("sort"),
+		Page:      page,
+		Limit:     limit,
+		Cursor:    query.Get("cursor"),
+	}
+	if usecaseInput.IncludeDeleted, err = boolParameter(r, "include_deleted"); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if usecaseInput.CreatedFrom, err = timeParameter(query, "created_from"); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if usecaseInput.CreatedTo, err = timeParameter(query, "created_to"); err != nil {
+		respondWithError(w, r, err)
+		return
 	}
 
 	paymentsOutput, err := h.GetAllPayments.Execute(usecaseInput)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, err)
 		return
 	}
 
@@ -139,31 +291,187 @@ func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
 		responses[i] = dto.CreatePaymentResponse(payment)
 	}
 
+	response := dto.PaymentListResponse{
+		Data: responses,
+		Pagination: dto.Pagination{
+			Limit:      paymentsOutput.Limit,
+			NextCursor: paymentsOutput.NextCursor,
+			PrevCursor: paymentsOutput.PrevCursor,
+		},
+	}
+	if paymentsOutput.Total != nil {
+		totalPages := (*paymentsOutput.Total + paymentsOutput.Limit - 1) / paymentsOutput.Limit
+		response.Pagination.Page = &paymentsOutput.Page
+		response.Pagination.Total = paymentsOutput.Total
+		response.Pagination.TotalPages = &totalPages
+		response.Links = pageLinks(r, paymentsOutput.Page, paymentsOutput.Limit, totalPages)
+	} else {
+		response.Links = cursorLinks(r, paymentsOutput)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(responses)
+	json.NewEncoder(w).Encode(response)
+}
+
+// listParameter splits a comma-separated query parameter, dropping empty items
+func listParameter(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// timeParameter parses an RFC 3339 timestamp or a YYYY-MM-DD date, taken as midnight UTC
+func timeParameter(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, apperr.New(apperr.CodeInvalidParameter, fmt.Sprintf("invalid %s %q: expected an RFC 3339 timestamp or a YYYY-MM-DD date", name, value))
+}
+
+// pageLinks builds the links to the other pages from the request URL, keeping its filters
+func pageLinks(r *http.Request, page, limit, totalPages int) dto.PageLinks {
+	link := func(page int) string {
+		return listLink(r, map[string]string{"page": strconv.Itoa(page), "limit": strconv.Itoa(limit)})
+	}
+
+	last := totalPages
+	if last < 1 {
+		last = 1
+	}
+	links := dto.PageLinks{
+		Self:  link(page),
+		First: link(1),
+		Last:  link(last),
+	}
+	if page > 1 {
+		links.Prev = link(min(page-1, last))
+	}
+	if page < totalPages {
+		links.Next = link(page + 1)
+	}
+	return links
+}
+
+// cursorLinks builds the links of a page requested by cursor, which has no last page
+func cursorLinks(r *http.Request, output *usecase.GetAllPaymentsOutput) dto.PageLinks {
+	limit := strconv.Itoa(output.Limit)
+	links := dto.PageLinks{
+		Self:  listLink(r, map[string]string{"limit": limit}),
+		First: listLink(r, map[string]string{"limit": limit, "cursor": ""}),
+	}
+	if output.PrevCursor != "" {
+		links.Prev = listLink(r, map[string]string{"limit": limit, "cursor": output.PrevCursor})
+	}
+	if output.NextCursor != "" {
+		links.Next = listLink(r, map[string]string{"limit": limit, "cursor": output.NextCursor})
+	}
+	return links
+}
+
+// listLink is the request URL with parameters replaced; empty values remove the
+// parameter. Page and cursor exclude each other.
+func listLink(r *http.Request, parameters map[string]string) string {
+	query := r.URL.Query()
+	if _, ok := parameters["cursor"]; ok {
+		query.Del("page")
+	}
+	if _, ok := parameters["page"]; ok {
+		query.Del("cursor")
+	}
+	for name, value := range parameters {
+		if value == "" {
+			query.Del(name)
+			continue
+		}
+		query.Set(name, value)
+	}
+	return r.URL.Path + "?" + query.Encode()
 }
 
 func (h *PaymentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	paymentID := chi.URLParam(r, "id")
 	if paymentID == "" {
-		respondWithError(w, http.StatusBadRequest, "payment ID is required")
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
 		return
 	}
 
 	usecaseInput := usecase.DeletePaymentInput{
-		ID: paymentID,
+		ID:    paymentID,
+		Actor: r.Header.Get(ActorHeader),
+	}
+	if usecaseInput.Actor == "" {
+		usecaseInput.Actor = defaultActor
 	}
 
 	err := h.DeletePayment.Execute(usecaseInput)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("payment with ID %s not found for deletion", paymentID) {
-			respondWithError(w, http.StatusNotFound, err.Error())
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *PaymentHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	paymentID := chi.URLParam(r, "id")
+	if paymentID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	var input dto.CapturePaymentRequest
+	// An empty body captures the full authorized amount
+	if !decodeBody(w, r, h.Validator, dto.CapturePaymentRequestSchema, true, &input) {
+		return
+	}
+
+	payment, err := h.CapturePayment.Execute(r.Context(), usecase.CapturePaymentInput{
+		ID:     paymentID,
+		Amount: input.Amount.String(),
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.CreatePaymentResponse(payment))
+}
+
+func (h *PaymentHandler) Void(w http.ResponseWriter, r *http.Request) {
+	paymentID := chi.URLParam(r, "id")
+	if paymentID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	var input dto.VoidPaymentRequest
+	if !decodeBody(w, r, h.Validator, dto.VoidPaymentRequestSchema, true, &input) {
+		return
+	}
+
+	payment, err := h.VoidPayment.Execute(r.Context(), usecase.VoidPaymentInput{
+		ID:     paymentID,
+		Reason: input.Reason,
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.CreatePaymentResponse(payment))
+}
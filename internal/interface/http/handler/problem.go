@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/repository"
+	"gateway-payments/internal/domain/validation"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProblemContentType is the media type of error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix makes the problem type a relative URI served by ProblemHandler
+const problemTypePrefix = "/problems/"
+
+// problemStatuses maps the codes of the error catalog to HTTP statuses. Codes
+// missing here are answered with 500.
+var problemStatuses = map[apperr.Code]int{
+	apperr.CodeMalformedRequest:       http.StatusBadRequest,
+	apperr.CodeValidationFailed:       http.StatusUnprocessableEntity,
+	apperr.CodeInvalidParameter:       http.StatusBadRequest,
+	apperr.CodeInvalidAmount:          http.StatusBadRequest,
+	apperr.CodeUnsupportedCurrency:    http.StatusBadRequest,
+	apperr.CodeUnknownStatus:          http.StatusBadRequest,
+	apperr.CodeUnsupportedEvent:       http.StatusBadRequest,
+	apperr.CodeNotFound:               http.StatusNotFound,
+	apperr.CodeConflict:               http.StatusConflict,
+	apperr.CodeInvalidTransition:      http.StatusConflict,
+	apperr.CodeAttemptNotAllowed:      http.StatusConflict,
+	apperr.CodeDeletionNotAllowed:     http.StatusConflict,
+	apperr.CodeConcurrentModification: http.StatusConflict,
+	apperr.CodeInsufficientFunds:      http.StatusUnprocessableEntity,
+	apperr.CodeIdempotencyKeyReused:   http.StatusUnprocessableEntity,
+	apperr.CodeInProgress:             http.StatusConflict,
+	apperr.CodeAlreadyProcessed:       http.StatusConflict,
+	apperr.CodeProcessorDeclined:      http.StatusPaymentRequired,
+	apperr.CodeProcessorUnavailable:   http.StatusServiceUnavailable,
+	apperr.CodeUnreconciledCharge:     http.StatusInternalServerError,
+	apperr.CodeUnauthorized:           http.StatusUnauthorized,
+	apperr.CodeForbidden:              http.StatusForbidden,
+	apperr.CodeInternal:               http.StatusInternalServerError,
+}
+
+// Problem is an RFC 7807 problem document. Code is the stable code of the error
+// catalog and Violations lists the fields that broke the schema of the request body.
+type Problem struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	Status        int                    `json:"status"`
+	Detail        string                 `json:"detail,omitempty"`
+	Instance      string                 `json:"instance,omitempty"`
+	Code          apperr.Code            `json:"code"`
+	Retryable     bool                   `json:"retryable"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Violations    []validation.Violation `json:"violations,omitempty"`
+}
+
+func problemStatus(code apperr.Code) int {
+	if status, ok := problemStatuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// newProblem describes err. Errors outside the catalog are logged and reported
+// without their message, which may expose internal details.
+func newProblem(r *http.Request, err error) Problem {
+	entry := apperr.Lookup(apperr.CodeOf(err))
+	problem := Problem{
+		Type:          problemTypePrefix + string(entry.Code),
+		Title:         entry.Title,
+		Status:        problemStatus(entry.Code),
+		Detail:        err.Error(),
+		Instance:      r.URL.Path,
+		Code:          entry.Code,
+		Retryable:     entry.Retryable,
+		CorrelationID: event.MetadataFromContext(r.Context()).CorrelationID,
+	}
+
+	if entry.Code == apperr.CodeInternal {
+		log.Printf("Error handling %s %s: %v", r.Method, r.URL.Path, err)
+		problem.Detail = "the request could not be completed"
+	}
+
+	var invalid *validation.ErrValidation
+	if errors.As(err, &invalid) {
+		problem.Detail = "request body does not match the " + invalid.Schema + " schema"
+		problem.Violations = invalid.Violations
+	}
+
+	return problem
+}
+
+// respondWithError sends err as a problem document
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	code, body := problemBody(r, err)
+	writeProblem(w, code, body)
+}
+
+// problemBody encodes the problem document of err, for handlers that need the body before writing it
+func problemBody(r *http.Request, err error) (int, []byte) {
+	problem := newProblem(r, err)
+	body, _ := json.Marshal(problem)
+	return problem.Status, body
+}
+
+func writeProblem(w http.ResponseWriter, code int, body []byte) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// ProblemHandler documents the problem types, so the type of a problem can be looked up
+type ProblemHandler struct{}
+
+func NewProblemHandler() *ProblemHandler {
+	return &ProblemHandler{}
+}
+
+// ProblemTypeResponse describes one code of the error catalog
+type ProblemTypeResponse struct {
+	Type      string      `json:"type"`
+	Code      apperr.Code `json:"code"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Retryable bool        `json:"retryable"`
+}
+
+func newProblemTypeResponse(entry apperr.Entry) ProblemTypeResponse {
+	return ProblemTypeResponse{
+		Type:      problemTypePrefix + string(entry.Code),
+		Code:      entry.Code,
+		Title:     entry.Title,
+		Status:    problemStatus(entry.Code),
+		Retryable: entry.Retryable,
+	}
+}
+
+// List returns the whole error catalog
+func (h *ProblemHandler) List(w http.ResponseWriter, r *http.Request) {
+	entries := apperr.Entries()
+	responses := make([]ProblemTypeResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = newProblemTypeResponse(entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responses)
+}
+
+// Get describes the problem type of one code
+func (h *ProblemHandler) Get(w http.ResponseWriter, r *http.Request) {
+	code := apperr.Code(chi.URLParam(r, "code"))
+	entry := apperr.Lookup(code)
+	if entry.Code != code {
+		respondWithError(w, r, &repository.ErrNotFound{Message: "unknown problem type " + string(code)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newProblemTypeResponse(entry))
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
+	"gateway-payments/internal/interface/dto"
+	"gateway-payments/internal/usecase"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type RefundHandler struct {
+	CreateRefund *usecase.CreateRefund
+	GetRefunds   *usecase.GetRefunds
+	Validator    validation.Validator
+}
+
+func NewRefundHandler(createRefund *usecase.CreateRefund, getRefunds *usecase.GetRefunds, validator validation.Validator) *RefundHandler {
+	return &RefundHandler{
+		CreateRefund: createRefund,
+		GetRefunds:   getRefunds,
+		Validator:    validator,
+	}
+}
+
+func (h *RefundHandler) Create(w http.ResponseWriter, r *http.Request) {
+	paymentID := chi.URLParam(r, "id")
+	if paymentID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	var input dto.CreateRefundRequest
+	// An empty body refunds the whole remaining amount
+	if !decodeBody(w, r, h.Validator, dto.CreateRefundRequestSchema, true, &input) {
+		return
+	}
+
+	refund, err := h.CreateRefund.Execute(r.Context(), usecase.CreateRefundInput{
+		PaymentID: paymentID,
+		Amount:    input.Amount.String(),
+		Reason:    input.Reason,
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.CreateRefundResponse(refund))
+}
+
+func (h *RefundHandler) List(w http.ResponseWriter, r *http.Request) {
+	paymentID := chi.URLParam(r, "id")
+	if paymentID == "" {
+		respondWithError(w, r, apperr.New(apperr.CodeInvalidParameter, "payment ID is required"))
+		return
+	}
+
+	refunds, err := h.GetRefunds.Execute(usecase.GetRefundsInput{PaymentID: paymentID})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	responses := make([]*dto.RefundResponse, len(refunds))
+	for i, refund := range refunds {
+		responses[i] = dto.CreateRefundResponse(refund)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(responses)
+}
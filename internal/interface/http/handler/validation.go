@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/validation"
+	"io"
+	"net/http"
+)
+
+// decodeBody reads the request body, validates it against schema and decodes it into
+// target. An empty body is accepted, and leaves target untouched, when optional is set.
+// On failure the error response is sent and false is returned.
+func decodeBody(w http.ResponseWriter, r *http.Request, validator validation.Validator, schema string, optional bool, target interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		respondWithError(w, r, apperr.Wrap(apperr.CodeMalformedRequest, err))
+		return false
+	}
+	if optional && len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+
+	if err := validator.Validate(schema, body); err != nil {
+		respondWithError(w, r, err)
+		return false
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		respondWithError(w, r, apperr.Wrap(apperr.CodeMalformedRequest, err))
+		return false
+	}
+	return true
+}
@@ -10,16 +10,58 @@ import (
 
 func NewRouter(
 	paymentHandler *handler.PaymentHandler,
+	refundHandler *handler.RefundHandler,
+	orderHandler *handler.OrderHandler,
+	metricsHandler *handler.MetricsHandler,
+	healthHandler *handler.HealthHandler,
+	deadLetterHandler *handler.DeadLetterHandler,
+	legalHoldHandler *handler.LegalHoldHandler,
+	adminToken string,
 ) *chi.Mux {
 	router := chi.NewRouter()
 	router.Use(corsMiddleware)
+	router.Use(handler.CorrelationMiddleware)
 	router.Use(middleware.Logger)
 
+	// Deleted payments are only listed for admins
+	includeDeleted := handler.AdminQueryMiddleware(adminToken, "include_deleted")
+
+	router.Post("/payments", paymentHandler.Create)
 	router.Put("/payments/{id}", paymentHandler.Update)
-	router.Get("/payments/{id}", paymentHandler.Get)
-	router.Get("/payments", paymentHandler.List)
+	router.With(includeDeleted).Get("/payments/{id}", paymentHandler.Get)
+	router.With(includeDeleted).Get("/payments", paymentHandler.List)
 	router.Delete("/payments/{id}", paymentHandler.Delete)
 
+	router.Post("/payments/{id}/capture", paymentHandler.Capture)
+	router.Post("/payments/{id}/void", paymentHandler.Void)
+
+	router.Post("/payments/{id}/refunds", refundHandler.Create)
+	router.Get("/payments/{id}/refunds", refundHandler.List)
+
+	router.With(includeDeleted).Get("/orders/{order_id}/payments", orderHandler.ListPayments)
+
+	problemHandler := handler.NewProblemHandler()
+	router.Get("/problems", problemHandler.List)
+	router.Get("/problems/{code}", problemHandler.Get)
+
+	router.Get("/metrics", metricsHandler.Metrics)
+	router.Get("/healthz", healthHandler.Live)
+	router.Get("/readyz", healthHandler.Ready)
+
+	router.Route("/admin", func(admin chi.Router) {
+		admin.Use(handler.AdminAuthMiddleware(adminToken))
+
+		admin.Get("/dlq", deadLetterHandler.List)
+		admin.Get("/dlq/{id}", deadLetterHandler.Get)
+		admin.Post("/dlq/replay", deadLetterHandler.Replay)
+		admin.Post("/dlq/{id}/replay", deadLetterHandler.Replay)
+		admin.Post("/dlq/purge", deadLetterHandler.Purge)
+		admin.Delete("/dlq/{id}", deadLetterHandler.Purge)
+
+		admin.Put("/payments/{id}/legal-hold", legalHoldHandler.Place)
+		admin.Delete("/payments/{id}/legal-hold", legalHoldHandler.Release)
+	})
+
 	return router
 }
 
@@ -28,7 +70,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		// Permite qualquer origem (ideal para desenvolvimento)
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Actor, X-Admin-Actor, X-Correlation-ID")
 
 		// Se for uma requisição pre-flight (OPTIONS), responde com OK e encerra
 		if r.Method == "OPTIONS" {
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+)
+
+type CapturePaymentInput struct {
+	ID     string
+	Amount string // decimal amount in the payment currency; empty captures the full authorization
+}
+
+type CapturePayment struct {
+	Repo       repository.PaymentRepository
+	Processors *processor.Registry
+}
+
+func NewCapturePaymentUseCase(repo repository.PaymentRepository, processors *processor.Registry) *CapturePayment {
+	return &CapturePayment{
+		Repo:       repo,
+		Processors: processors,
+	}
+}
+
+func (cp *CapturePayment) Execute(ctx context.Context, input CapturePaymentInput) (*entity.Payment, error) {
+	payment, err := cp.Repo.FindByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := payment.Amount
+	if input.Amount != "" {
+		amount, err = entity.ParseMoney(input.Amount, payment.Amount.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := payment.Capture(amount); err != nil {
+		return nil, err
+	}
+
+	proc, ok, err := paymentProcessor(cp.Processors, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		messages, err := paymentEventMessages(ctx, payment)
+		if err != nil {
+			return nil, err
+		}
+		if err := cp.Repo.Update(payment, messages...); err != nil {
+			return nil, err
+		}
+		return payment, nil
+	}
+
+	result, err := proc.Capture(ctx, processor.CaptureRequest{
+		PaymentID: payment.ID,
+		Reference: payment.ProcessorReference,
+		Amount:    amount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error capturing payment %s: %w", payment.ID, err)
+	}
+	if !result.Approved {
+		return nil, processor.Declined("capture", result)
+	}
+
+	// A captura já aconteceu no processador: uma alteração concorrente não pode desfazê-la
+	return saveProcessed(ctx, cp.Repo, proc, payment, func(current *entity.Payment) error {
+		return current.Capture(amount)
+	})
+}
@@ -2,79 +2,239 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"gateway-payments/internal/domain/apperr"
 	"gateway-payments/internal/domain/entity"
-	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/processor"
 	"gateway-payments/internal/domain/repository"
-	"gateway-payments/internal/infrastructure/broker"
-	"math/rand"
-	"os"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DefaultPaymentMethod is used when the caller does not inform a method.
+const DefaultPaymentMethod = "Credit Card"
+
+// paymentProcessingLease is how long other requests for an order wait for a worker
+// calling the processor before they take the attempt over.
+const paymentProcessingLease = time.Minute
+
+// ErrPaymentInProgress is returned while another worker waits for the processor to
+// decide the payment of the same order.
+var ErrPaymentInProgress = apperr.New(apperr.CodeInProgress, "the payment of this order is being processed")
+
+type CreatePaymentInput struct {
+	OrderID  string
+	Amount   string // decimal amount in major units, e.g. "100.50"
+	Currency string
+	Method   string
+	// AuthorizeOnly places a hold that must be captured later with CapturePayment
+	AuthorizeOnly bool
+	// SourceToken identifies the card or wallet for the processor; it is not stored
+	SourceToken string
+}
+
 type CreatePayment struct {
-	Repo   repository.PaymentRepository
-	Broker *broker.RabbitMQClient
+	Repo       repository.PaymentRepository
+	UnitOfWork repository.UnitOfWork
+	Processors *processor.Registry
 }
 
-func NewCreatePaymentUseCase(repo repository.PaymentRepository, broker *broker.RabbitMQClient) *CreatePayment {
+func NewCreatePaymentUseCase(repo repository.PaymentRepository, unitOfWork repository.UnitOfWork, processors *processor.Registry) *CreatePayment {
 	return &CreatePayment{
-		Repo:   repo,
-		Broker: broker,
+		Repo:       repo,
+		UnitOfWork: unitOfWork,
+		Processors: processors,
 	}
 }
 
-func (pc *CreatePayment) Execute(ctx context.Context, paymentRequested event.PaymentRequested) (*entity.Payment, error) {
-	// Check idempotency
-	existingPayment, err := pc.Repo.FindByOrderID(paymentRequested.OrderID)
-	if err != nil && err.Error() != fmt.Sprintf("payment with order ID %s not found", paymentRequested.OrderID) { // Check for actual error not "not found"
-		return nil, fmt.Errorf("error checking existing payment for order %s: %w", paymentRequested.OrderID, err)
+func (pc *CreatePayment) Execute(ctx context.Context, input CreatePaymentInput) (*entity.Payment, error) {
+	// Check idempotency: an open or successful attempt answers for the order, and a
+	// failed one lets the customer try again
+	lastAttempt, err := pc.findByOrderID(input.OrderID)
+	if err != nil {
+		return nil, err
 	}
-	if existingPayment != nil {
-		fmt.Printf("Payment for order %s already exists, ignoring. Status: %s\n", paymentRequested.OrderID, existingPayment.Status)
-		return existingPayment, nil // Idempotent: payment already processed
+	if lastAttempt != nil && lastAttempt.AwaitsProcessor() {
+		return pc.resume(ctx, lastAttempt, input)
+	}
+	if lastAttempt != nil && !lastAttempt.AllowsNewAttempt() {
+		fmt.Printf("Payment for order %s already exists, ignoring. Status: %s\n", input.OrderID, lastAttempt.Status)
+		return lastAttempt, nil // Idempotent: payment already processed
 	}
 
-	autoApprove := os.Getenv("AUTO_APPROVE_PAYMENTS") == "true"
+	currency := input.Currency
+	if currency == "" {
+		currency = entity.DefaultCurrency
+	}
+	amount, err := entity.ParseMoney(input.Amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsPositive() {
+		return nil, &entity.ErrInvalidAmount{Amount: input.Amount, Reason: "must be greater than zero"}
+	}
 
-	var paymentStatus string
-	if autoApprove {
-		// Lógica atual: Simular processamento (random success/failure)
-		rand.Seed(time.Now().UnixNano())
-		if rand.Intn(100) < 80 {
-			paymentStatus = entity.StatusApproved
-		} else {
-			paymentStatus = entity.StatusRejected
-		}
+	method := input.Method
+	if method == "" {
+		method = DefaultPaymentMethod
+	}
+
+	var payment *entity.Payment
+	if lastAttempt == nil {
+		payment = entity.NewPayment(uuid.NewString(), input.OrderID, amount, method)
 	} else {
-		// Nova lógica: Nasce pendente para aprovação manual via PUT
-		paymentStatus = "PENDING"
+		payment, err = entity.NewPaymentAttempt(uuid.NewString(), lastAttempt, amount, method)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Order %s had attempt %d %s; starting attempt %d", input.OrderID, lastAttempt.Attempt, lastAttempt.Status, payment.Attempt)
 	}
-	// Persist payment record
-	payment := entity.NewPayment(uuid.NewString(), paymentRequested.OrderID, paymentRequested.Amount, "Credit Card")
-	payment.Status = paymentStatus
 
-	err = pc.Repo.Save(payment)
-	if err != nil {
+	// Sem processador configurado para o método o pagamento nasce pendente para aprovação manual via PUT
+	processorName, proc, ok := pc.Processors.ForMethod(method)
+	if !ok {
+		err := pc.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+			if err := repos.Payments().Create(payment); err != nil {
+				return err
+			}
+			return markMessageProcessed(ctx, repos)
+		})
+		if err != nil {
+			return pc.createConflict(ctx, input, err)
+		}
+		return payment, nil
+	}
+
+	// A tentativa é gravada antes de chamar o processador: as uniques de (order_id, attempt)
+	// e live_order_id barram uma requisição concorrente antes que ela movimente dinheiro
+	payment.Processor = processorName
+	payment.ProcessingUntil = processingDeadline()
+	if err := pc.Repo.Create(payment); err != nil {
+		return pc.createConflict(ctx, input, err)
+	}
+
+	return pc.authorize(ctx, payment, proc, input)
+}
+
+// createConflict answers for the order when another request stored an attempt first.
+func (pc *CreatePayment) createConflict(ctx context.Context, input CreatePaymentInput, err error) (*entity.Payment, error) {
+	if !errors.Is(err, repository.ErrDuplicateKey) {
 		return nil, fmt.Errorf("error saving payment: %w", err)
 	}
+	existingPayment, findErr := pc.findByOrderID(input.OrderID)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if existingPayment == nil {
+		return nil, fmt.Errorf("error saving payment: %w", err)
+	}
+	if existingPayment.AwaitsProcessor() {
+		return pc.resume(ctx, existingPayment, input)
+	}
+	return existingPayment, nil
+}
+
+// resume sends again a payment whose processor call failed or was interrupted, keeping
+// its ID. It waits while another worker is still calling the processor.
+func (pc *CreatePayment) resume(ctx context.Context, payment *entity.Payment, input CreatePaymentInput) (*entity.Payment, error) {
+	if payment.Processing(time.Now()) {
+		return nil, ErrPaymentInProgress
+	}
+	proc, _, err := paymentProcessor(pc.Processors, payment)
+	if err != nil {
+		return nil, err
+	}
 
-	// 2. Só dispara o evento se o pagamento já estiver decidido (Approved ou Rejected)
-	if paymentStatus != "PENDING" {
-		paymentProcessedEvent := event.PaymentProcessed{
-			Event:       "payment.processed",
-			OrderID:     payment.OrderID,
-			Status:      payment.Status,
-			ProcessedAt: time.Now(),
+	// O compare-and-swap garante que só um worker retoma a tentativa
+	payment.ProcessingUntil = processingDeadline()
+	if err := pc.Repo.Update(payment); err != nil {
+		var conflict *repository.ErrConcurrentModification
+		if errors.As(err, &conflict) {
+			return nil, ErrPaymentInProgress
 		}
+		return nil, err
+	}
+	log.Printf("Resuming attempt %d of order %s (payment %s) with processor %s", payment.Attempt, payment.OrderID, payment.ID, payment.Processor)
 
-		err = pc.Broker.Publish(ctx, "payments.exchange", "payment.processed", paymentProcessedEvent)
-		if err != nil {
-			return nil, fmt.Errorf("error publishing event: %w", err)
+	return pc.authorize(ctx, payment, proc, input)
+}
+
+// authorize calls the processor for a stored PENDING payment and records the outcome.
+// If the outcome cannot be stored, the charge is reversed at the processor.
+func (pc *CreatePayment) authorize(ctx context.Context, payment *entity.Payment, proc processor.Processor, input CreatePaymentInput) (*entity.Payment, error) {
+	if err := pc.process(ctx, payment, proc, input); err != nil {
+		// A tentativa continua pendente e é retomada na próxima requisição ou entrega
+		payment.ProcessingUntil = nil
+		if releaseErr := pc.Repo.Update(payment); releaseErr != nil {
+			log.Printf("Error releasing payment %s for a new try: %v", payment.ID, releaseErr)
 		}
+		return nil, err
+	}
+
+	// O evento é gravado na outbox na mesma transação e publicado pelo relay.
+	messages, err := paymentEventMessages(ctx, payment)
+	if err != nil {
+		return nil, reverseCharge(ctx, proc, payment, err)
+	}
+
+	// O consumidor só marca a mensagem como processada se o resultado for gravado
+	err = pc.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		if err := repos.Payments().Update(payment, messages...); err != nil {
+			return err
+		}
+		return markMessageProcessed(ctx, repos)
+	})
+	if err != nil {
+		return nil, reverseCharge(ctx, proc, payment, fmt.Errorf("error saving payment: %w", err))
 	}
 
 	return payment, nil
 }
+
+// findByOrderID returns the last attempt of the order, or nil if it has none.
+func (pc *CreatePayment) findByOrderID(orderID string) (*entity.Payment, error) {
+	payment, err := pc.Repo.FindByOrderID(orderID)
+	if err != nil {
+		var notFound *repository.ErrNotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error checking existing payment for order %s: %w", orderID, err)
+	}
+	return payment, nil
+}
+
+// process authorizes the payment with the processor, capturing it in the same call
+// unless only an authorization was requested.
+func (pc *CreatePayment) process(ctx context.Context, payment *entity.Payment, proc processor.Processor, input CreatePaymentInput) error {
+	result, err := proc.Authorize(ctx, processor.AuthorizeRequest{
+		PaymentID:   payment.ID,
+		OrderID:     payment.OrderID,
+		Amount:      payment.Amount,
+		Method:      payment.Method,
+		SourceToken: input.SourceToken,
+		Capture:     !input.AuthorizeOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("error authorizing payment for order %s: %w", payment.OrderID, err)
+	}
+
+	payment.ProcessorReference = result.Reference
+
+	switch {
+	case !result.Approved:
+		return payment.Reject(result.DeclineReason)
+	case input.AuthorizeOnly:
+		return payment.Authorize()
+	default:
+		return payment.Approve()
+	}
+}
+
+func processingDeadline() *time.Time {
+	deadline := time.Now().Add(paymentProcessingLease)
+	return &deadline
+}
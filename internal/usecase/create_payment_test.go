@@ -0,0 +1,297 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/processor"
+	"testing"
+	"time"
+)
+
+// newTestCreatePayment routes PIX to proc and leaves the other methods for manual approval.
+func newTestCreatePayment(store *fakeStore, proc *fakeProcessor) *CreatePayment {
+	processors := processor.NewRegistry()
+	processors.Register("fake", proc)
+	if err := processors.Route("PIX", "fake"); err != nil {
+		panic(err)
+	}
+	return NewCreatePaymentUseCase(store.Payments(), &fakeUnitOfWork{store: store}, processors)
+}
+
+func pixInput(orderID string) CreatePaymentInput {
+	return CreatePaymentInput{OrderID: orderID, Amount: "100.00", Currency: "BRL", Method: "PIX"}
+}
+
+// storedAttempt builds an attempt of order-1 that another request stored.
+func storedAttempt(id string, status string, processorName string, processingUntil *time.Time) *entity.Payment {
+	payment := entity.NewPayment(id, "order-1", entity.Money{Amount: 10000, Currency: "BRL"}, "PIX")
+	payment.Status = status
+	payment.Processor = processorName
+	payment.ProcessingUntil = processingUntil
+	if status == entity.StatusApproved {
+		payment.Captured = payment.Amount
+	}
+	return payment
+}
+
+func TestCreatePaymentAuthorizesWithProcessor(t *testing.T) {
+	store := newFakeStore()
+	proc := &fakeProcessor{}
+
+	payment, err := newTestCreatePayment(store, proc).Execute(context.Background(), pixInput("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != entity.StatusApproved || payment.ProcessorReference != "ref-"+payment.ID {
+		t.Errorf("got %s with reference %q", payment.Status, payment.ProcessorReference)
+	}
+	if payment.ProcessingUntil != nil {
+		t.Error("the processing lease was kept after the outcome was stored")
+	}
+	authorized, _, _ := proc.calls()
+	if len(authorized) != 1 || authorized[0].PaymentID != payment.ID || !authorized[0].Capture {
+		t.Errorf("authorizations = %+v", authorized)
+	}
+	if messages := store.outboxMessages(); len(messages) != 1 || messages[0].RoutingKey != event.TypePaymentProcessed {
+		t.Errorf("outbox = %+v, want one %s event", messages, event.TypePaymentProcessed)
+	}
+}
+
+// TestCreatePaymentDuplicate covers requests for an order that already has an attempt,
+// either found up front or stored by a concurrent request just before this one.
+func TestCreatePaymentDuplicate(t *testing.T) {
+	future := time.Now().Add(time.Minute)
+	past := time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name  string
+		input CreatePaymentInput
+		// existing is stored before the request, or right before its Create when concurrent
+		existing   *entity.Payment
+		concurrent bool
+
+		wantErr        error
+		wantID         string
+		wantStatus     string
+		authorizations int
+		attempts       int
+	}{
+		{
+			name:       "decided attempt answers the request",
+			input:      pixInput("order-1"),
+			existing:   storedAttempt("existing", entity.StatusApproved, "fake", nil),
+			wantID:     "existing",
+			wantStatus: entity.StatusApproved,
+			attempts:   1,
+		},
+		{
+			name:       "concurrent decided attempt answers the request",
+			input:      pixInput("order-1"),
+			existing:   storedAttempt("existing", entity.StatusApproved, "fake", nil),
+			concurrent: true,
+			wantID:     "existing",
+			wantStatus: entity.StatusApproved,
+			attempts:   1,
+		},
+		{
+			name:       "manual pending attempt answers the request",
+			input:      CreatePaymentInput{OrderID: "order-1", Amount: "100.00", Method: "Boleto"},
+			existing:   storedAttempt("existing", entity.StatusPending, "", nil),
+			concurrent: true,
+			wantID:     "existing",
+			wantStatus: entity.StatusPending,
+			attempts:   1,
+		},
+		{
+			name:     "attempt being processed is left alone",
+			input:    pixInput("order-1"),
+			existing: storedAttempt("existing", entity.StatusPending, "fake", &future),
+			wantErr:  ErrPaymentInProgress,
+			attempts: 1,
+		},
+		{
+			name:       "concurrent attempt being processed is left alone",
+			input:      pixInput("order-1"),
+			existing:   storedAttempt("existing", entity.StatusPending, "fake", &future),
+			concurrent: true,
+			wantErr:    ErrPaymentInProgress,
+			attempts:   1,
+		},
+		{
+			name:           "abandoned attempt is resumed with its ID",
+			input:          pixInput("order-1"),
+			existing:       storedAttempt("existing", entity.StatusPending, "fake", &past),
+			wantID:         "existing",
+			wantStatus:     entity.StatusApproved,
+			authorizations: 1,
+			attempts:       1,
+		},
+		{
+			name:           "attempt whose processor call failed is resumed with its ID",
+			input:          pixInput("order-1"),
+			existing:       storedAttempt("existing", entity.StatusPending, "fake", nil),
+			concurrent:     true,
+			wantID:         "existing",
+			wantStatus:     entity.StatusApproved,
+			authorizations: 1,
+			attempts:       1,
+		},
+		{
+			name:           "failed attempt lets the order be paid again",
+			input:          pixInput("order-1"),
+			existing:       storedAttempt("existing", entity.StatusRejected, "fake", nil),
+			wantStatus:     entity.StatusApproved,
+			authorizations: 1,
+			attempts:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			proc := &fakeProcessor{}
+			if tt.concurrent {
+				store.beforeCreate = func() { store.seed(tt.existing) }
+			} else {
+				store.seed(tt.existing)
+			}
+
+			payment, err := newTestCreatePayment(store, proc).Execute(context.Background(), tt.input)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Execute() = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Execute() = %v", err)
+				}
+				if tt.wantID != "" && payment.ID != tt.wantID {
+					t.Errorf("payment = %s, want %s", payment.ID, tt.wantID)
+				}
+				if payment.Status != tt.wantStatus {
+					t.Errorf("status = %s, want %s", payment.Status, tt.wantStatus)
+				}
+			}
+
+			authorized, _, _ := proc.calls()
+			if len(authorized) != tt.authorizations {
+				t.Errorf("processor authorized %d times, want %d", len(authorized), tt.authorizations)
+			}
+			if tt.wantID != "" && len(authorized) > 0 && authorized[0].PaymentID != tt.wantID {
+				t.Errorf("processor authorized payment %s, want %s", authorized[0].PaymentID, tt.wantID)
+			}
+			if attempts := store.attempts("order-1"); len(attempts) != tt.attempts {
+				t.Errorf("order has %d attempts, want %d", len(attempts), tt.attempts)
+			}
+		})
+	}
+}
+
+func TestCreatePaymentRetryAfterProcessorFailure(t *testing.T) {
+	store := newFakeStore()
+	proc := &fakeProcessor{authorizeErr: processor.ErrUnavailable}
+	createPayment := newTestCreatePayment(store, proc)
+
+	if _, err := createPayment.Execute(context.Background(), pixInput("order-1")); !errors.Is(err, processor.ErrUnavailable) {
+		t.Fatalf("first Execute() = %v, want processor.ErrUnavailable", err)
+	}
+	attempts := store.attempts("order-1")
+	if len(attempts) != 1 || !attempts[0].AwaitsProcessor() || attempts[0].ProcessingUntil != nil {
+		t.Fatalf("after the failure the order has %+v, want one released PENDING attempt", attempts)
+	}
+
+	// The redelivery retries the same attempt, so the processor sees one payment ID
+	payment, err := createPayment.Execute(context.Background(), pixInput("order-1"))
+	if err != nil {
+		t.Fatalf("second Execute() = %v", err)
+	}
+	if payment.ID != attempts[0].ID || payment.Status != entity.StatusApproved {
+		t.Errorf("got payment %s %s, want %s APPROVED", payment.ID, payment.Status, attempts[0].ID)
+	}
+	authorized, _, _ := proc.calls()
+	if len(authorized) != 2 || authorized[0].PaymentID != authorized[1].PaymentID {
+		t.Errorf("authorizations = %+v, want two for the same payment", authorized)
+	}
+	if attempts := store.attempts("order-1"); len(attempts) != 1 {
+		t.Errorf("order has %d attempts, want 1", len(attempts))
+	}
+}
+
+func TestCreatePaymentReversesChargeThatCannotBeStored(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorizeOnly bool
+		refundErr     error
+		wantRefunds   int
+		wantVoids     int
+		unreconciled  bool
+	}{
+		{name: "captured payment is refunded", wantRefunds: 1},
+		{name: "authorization is voided", authorizeOnly: true, wantVoids: 1},
+		{name: "failed refund is reported", refundErr: processor.ErrUnavailable, wantRefunds: 1, unreconciled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.commitErr = errors.New("connection lost")
+			proc := &fakeProcessor{refundErr: tt.refundErr}
+			input := pixInput("order-1")
+			input.AuthorizeOnly = tt.authorizeOnly
+
+			_, err := newTestCreatePayment(store, proc).Execute(context.Background(), input)
+			if err == nil {
+				t.Fatal("Execute() succeeded although the outcome was not stored")
+			}
+			var unreconciled *ErrUnreconciledCharge
+			if errors.As(err, &unreconciled) != tt.unreconciled {
+				t.Errorf("Execute() = %v, want unreconciled %v", err, tt.unreconciled)
+			}
+
+			_, refunded, voided := proc.calls()
+			if len(refunded) != tt.wantRefunds || len(voided) != tt.wantVoids {
+				t.Errorf("processor refunded %d and voided %d times, want %d and %d", len(refunded), len(voided), tt.wantRefunds, tt.wantVoids)
+			}
+			if len(refunded) > 0 && refunded[0].Amount.Amount != 10000 {
+				t.Errorf("refunded %s, want the captured amount", refunded[0].Amount)
+			}
+			if messages := store.outboxMessages(); len(messages) != 0 {
+				t.Errorf("outbox = %+v, want the event rolled back", messages)
+			}
+		})
+	}
+}
+
+func TestCreatePaymentMarksMessageInTransaction(t *testing.T) {
+	store := newFakeStore()
+	proc := &fakeProcessor{authorizeErr: processor.ErrUnavailable}
+	deduplication := NewMessageDeduplicationUseCase(store.ProcessedMessages(), time.Minute)
+	createPayment := newTestCreatePayment(store, proc)
+
+	process := func(ctx context.Context, _ *messaging.Message) error {
+		_, err := createPayment.Execute(ctx, pixInput("order-1"))
+		return err
+	}
+
+	if err := deduplication.Run(context.Background(), "test", "message-1", nil, process); err == nil {
+		t.Fatal("Run() succeeded although the processor failed")
+	}
+	if _, ok := store.processedMessage("test", "message-1"); ok {
+		t.Fatal("the message stayed claimed after the payment failed")
+	}
+
+	if err := deduplication.Run(context.Background(), "test", "message-1", nil, process); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if message, ok := store.processedMessage("test", "message-1"); !ok || !message.Processed() {
+		t.Errorf("message = %+v, want it processed", message)
+	}
+	if err := deduplication.Run(context.Background(), "test", "message-1", nil, process); !errors.Is(err, ErrMessageAlreadyProcessed) {
+		t.Errorf("third Run() = %v, want ErrMessageAlreadyProcessed", err)
+	}
+}
@@ -0,0 +1,202 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+type CreateRefundInput struct {
+	PaymentID string
+	Amount    string // decimal amount in the payment currency; empty refunds everything still refundable
+	Reason    string
+}
+
+type CreateRefund struct {
+	PaymentRepo repository.PaymentRepository
+	RefundRepo  repository.RefundRepository
+	UnitOfWork  repository.UnitOfWork
+	Processors  *processor.Registry
+}
+
+func NewCreateRefundUseCase(paymentRepo repository.PaymentRepository, refundRepo repository.RefundRepository, unitOfWork repository.UnitOfWork, processors *processor.Registry) *CreateRefund {
+	return &CreateRefund{
+		PaymentRepo: paymentRepo,
+		RefundRepo:  refundRepo,
+		UnitOfWork:  unitOfWork,
+		Processors:  processors,
+	}
+}
+
+func (cr *CreateRefund) Execute(ctx context.Context, input CreateRefundInput) (*entity.Refund, error) {
+	payment, err := cr.PaymentRepo.FindByID(input.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunds still waiting for the processor hold their amount
+	refunds, err := cr.RefundRepo.FindByPaymentID(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := entity.PendingRefunds(refunds, payment.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := payment.RefundableAmount().Sub(pending)
+	if err != nil {
+		return nil, err
+	}
+	if input.Amount != "" {
+		amount, err = entity.ParseMoney(input.Amount, payment.Amount.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := payment.CheckRefund(amount, pending); err != nil {
+		return nil, err
+	}
+
+	refund := entity.NewRefund(uuid.NewString(), payment.ID, amount, input.Reason)
+
+	proc, ok, err := paymentProcessor(cr.Processors, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := cr.apply(ctx, payment, refund, false); err != nil {
+			return nil, err
+		}
+		return refund, nil
+	}
+
+	// O estorno pendente é gravado antes de chamar o processador. A checagem de versão do
+	// pagamento barra outro estorno gravado desde a leitura dos pendentes.
+	err = cr.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		if err := repos.Payments().Update(payment); err != nil {
+			return err
+		}
+		return repos.Refunds().Create(refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = requestRefund(ctx, proc, payment, refund)
+	var declined *processor.ErrDeclined
+	if errors.As(err, &declined) {
+		refund.Fail()
+		if updateErr := cr.RefundRepo.Update(refund); updateErr != nil {
+			log.Printf("Error recording failed refund %s of payment %s: %v", refund.ID, payment.ID, updateErr)
+		}
+		return nil, fmt.Errorf("error refunding payment %s: %w", payment.ID, err)
+	}
+	if err != nil {
+		// Sem resposta não se sabe se o dinheiro voltou: o estorno fica PENDING, segurando
+		// o valor, até ReconcileRefunds perguntar de novo ao processador
+		return nil, fmt.Errorf("error refunding payment %s, refund %s stays pending: %w", payment.ID, refund.ID, err)
+	}
+
+	if err := cr.settle(ctx, payment.ID, refund); err != nil {
+		// The money was given back; the refund stays PENDING, holding its amount, until reconciled
+		log.Printf("Error recording refund %s of payment %s, which the processor accepted: %v", refund.ID, payment.ID, err)
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// Reconcile asks the processor again for a refund left PENDING, with the same refund ID
+// so it is not given back twice, and stores the answer. Errors other than a decline
+// leave the refund PENDING.
+func (cr *CreateRefund) Reconcile(ctx context.Context, refund *entity.Refund) error {
+	payment, err := cr.PaymentRepo.FindByID(refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	proc, ok, err := paymentProcessor(cr.Processors, payment)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("payment %s has no processor to refund %s", payment.ID, refund.ID)
+	}
+
+	err = requestRefund(ctx, proc, payment, refund)
+	var declined *processor.ErrDeclined
+	if errors.As(err, &declined) {
+		refund.Fail()
+		return cr.RefundRepo.Update(refund)
+	}
+	if err != nil {
+		return err
+	}
+	return cr.settle(ctx, payment.ID, refund)
+}
+
+// requestRefund asks proc to give refund back, returning *processor.ErrDeclined when it refused.
+func requestRefund(ctx context.Context, proc processor.Processor, payment *entity.Payment, refund *entity.Refund) error {
+	result, err := proc.Refund(ctx, processor.RefundRequest{
+		PaymentID: payment.ID,
+		RefundID:  refund.ID,
+		Reference: payment.ProcessorReference,
+		Amount:    refund.Amount,
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Approved {
+		return processor.Declined("refund", result)
+	}
+	return nil
+}
+
+// settle applies a refund accepted by the processor to the payment, reading the payment
+// again when it changed concurrently.
+func (cr *CreateRefund) settle(ctx context.Context, paymentID string, refund *entity.Refund) error {
+	for saves := 1; ; saves++ {
+		payment, err := cr.PaymentRepo.FindByID(paymentID)
+		if err != nil {
+			return err
+		}
+		err = cr.apply(ctx, payment, refund, true)
+		// A refund that is no longer PENDING was settled by someone else
+		var conflict *repository.ErrConcurrentModification
+		if err == nil || !errors.As(err, &conflict) || conflict.Entity == "refund" || saves == maxProcessedSaves {
+			return err
+		}
+	}
+}
+
+// apply adds refund to the refunded total of payment and stores both as succeeded, with
+// the payment.refunded event, in one transaction. recorded tells whether the refund was
+// already stored as pending.
+func (cr *CreateRefund) apply(ctx context.Context, payment *entity.Payment, refund *entity.Refund, recorded bool) error {
+	if err := payment.ApplyRefund(refund.Amount); err != nil {
+		return err
+	}
+	refund.Succeed()
+
+	message, err := paymentRefundedMessage(ctx, payment, refund)
+	if err != nil {
+		return fmt.Errorf("error building payment.refunded event: %w", err)
+	}
+
+	return cr.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		if err := repos.Payments().Update(payment, message); err != nil {
+			return err
+		}
+		if recorded {
+			return repos.Refunds().Update(refund)
+		}
+		return repos.Refunds().Create(refund)
+	})
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"testing"
+	"time"
+)
+
+func newTestCreateRefund(store *fakeStore, proc *fakeProcessor) *CreateRefund {
+	processors := processor.NewRegistry()
+	processors.Register("fake", proc)
+	return NewCreateRefundUseCase(store.Payments(), store.Refunds(), &fakeUnitOfWork{store: store}, processors)
+}
+
+// refundStatuses returns the stored refunds of payment-1 and the amount refunded on it.
+func refundStatuses(t *testing.T, store *fakeStore) ([]*entity.Refund, int64) {
+	t.Helper()
+	refunds, _ := store.Refunds().FindByPaymentID("payment-1")
+	payment, err := store.Payments().FindByID("payment-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refunds, payment.Refunded.Amount
+}
+
+func TestCreateRefundOutcome(t *testing.T) {
+	tests := []struct {
+		name          string
+		refundErr     error
+		refundDecline string
+		wantStatus    string
+		wantRefunded  int64
+	}{
+		{name: "approved", wantStatus: entity.RefundStatusSucceeded, wantRefunded: 3000},
+		{name: "declined", refundDecline: "do_not_honor", wantStatus: entity.RefundStatusFailed},
+		{name: "unanswered refund stays pending", refundErr: processor.ErrUnavailable, wantStatus: entity.RefundStatusPending},
+		{name: "unknown failure stays pending", refundErr: errors.New("acquirer returned 400"), wantStatus: entity.RefundStatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.seed(storedAttempt("payment-1", entity.StatusApproved, "fake", nil))
+			proc := &fakeProcessor{refundErr: tt.refundErr, refundDecline: tt.refundDecline}
+
+			_, err := newTestCreateRefund(store, proc).Execute(context.Background(), CreateRefundInput{PaymentID: "payment-1", Amount: "30.00"})
+			if (err != nil) != (tt.wantStatus != entity.RefundStatusSucceeded) {
+				t.Fatalf("Execute() = %v", err)
+			}
+
+			refunds, refunded := refundStatuses(t, store)
+			if len(refunds) != 1 || refunds[0].Status != tt.wantStatus {
+				t.Fatalf("refunds = %+v, want one %s", refunds, tt.wantStatus)
+			}
+			if refunded != tt.wantRefunded {
+				t.Errorf("payment refunded %d, want %d", refunded, tt.wantRefunded)
+			}
+		})
+	}
+}
+
+func TestReconcileRefunds(t *testing.T) {
+	tests := []struct {
+		name          string
+		refundErr     error
+		refundDecline string
+		wantStatus    string
+		wantRefunded  int64
+		wantAttempts  int
+	}{
+		{name: "accepted refund is settled", wantStatus: entity.RefundStatusSucceeded, wantRefunded: 3000},
+		{name: "declined refund fails", refundDecline: "do_not_honor", wantStatus: entity.RefundStatusFailed},
+		{name: "unanswered refund is postponed", refundErr: processor.ErrUnavailable, wantStatus: entity.RefundStatusPending, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.seed(storedAttempt("payment-1", entity.StatusApproved, "fake", nil))
+			pending := entity.NewRefund("refund-1", "payment-1", entity.Money{Amount: 3000, Currency: "BRL"}, "")
+			pending.CreatedAt = time.Now().Add(-time.Hour)
+			store.Refunds().Create(pending)
+			// A refund younger than After may still be waiting for its request
+			store.Refunds().Create(entity.NewRefund("refund-2", "payment-1", entity.Money{Amount: 1000, Currency: "BRL"}, ""))
+			proc := &fakeProcessor{refundErr: tt.refundErr, refundDecline: tt.refundDecline}
+			reconcile := NewReconcileRefundsUseCase(store.Refunds(), newTestCreateRefund(store, proc), time.Minute, 10)
+
+			if _, err := reconcile.Execute(context.Background()); err != nil {
+				t.Fatalf("Execute() = %v", err)
+			}
+
+			_, refunded, _ := proc.calls()
+			if len(refunded) != 1 || refunded[0].RefundID != "refund-1" {
+				t.Fatalf("processor refunds = %+v, want refund-1 again", refunded)
+			}
+			refunds, paymentRefunded := refundStatuses(t, store)
+			if refunds[0].Status != tt.wantStatus || refunds[0].ReconcileAttempts != tt.wantAttempts {
+				t.Errorf("refund-1 is %s after %d attempts, want %s after %d", refunds[0].Status, refunds[0].ReconcileAttempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if refunds[1].Status != entity.RefundStatusPending {
+				t.Errorf("refund-2 is %s, want it left alone", refunds[1].Status)
+			}
+			if paymentRefunded != tt.wantRefunded {
+				t.Errorf("payment refunded %d, want %d", paymentRefunded, tt.wantRefunded)
+			}
+
+			// A postponed refund is not asked again before its next attempt
+			if _, err := reconcile.Execute(context.Background()); err != nil {
+				t.Fatalf("second Execute() = %v", err)
+			}
+			if _, refunded, _ := proc.calls(); len(refunded) != 1 {
+				t.Errorf("processor was asked %d times, want once", len(refunded))
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterCollectorName scopes the deduplication records of DeadLetterCollector.
+const DeadLetterCollectorName = "payments.dlq"
+
+// DeadLetterCollector moves the messages of the dead-letter queue to the dead_letters
+// table, where operators can inspect, replay or purge them. Messages that cannot be
+// stored yet wait in Delay before coming back.
+type DeadLetterCollector struct {
+	Broker        messaging.EventSubscriber
+	Publisher     messaging.EventPublisher
+	UnitOfWork    repository.UnitOfWork
+	Deduplication *MessageDeduplication
+	Delay         messaging.RetryTier
+}
+
+func NewDeadLetterCollector(subscriber messaging.EventSubscriber, publisher messaging.EventPublisher, unitOfWork repository.UnitOfWork, deduplication *MessageDeduplication, delay messaging.RetryTier) *DeadLetterCollector {
+	return &DeadLetterCollector{
+		Broker:        subscriber,
+		Publisher:     publisher,
+		UnitOfWork:    unitOfWork,
+		Deduplication: deduplication,
+		Delay:         delay,
+	}
+}
+
+func (c *DeadLetterCollector) StartConsuming(queueName, consumerName string, options messaging.SubscribeOptions) {
+	handler := c.Deduplication.Handler(MessageConsumer{
+		Name:    DeadLetterCollectorName,
+		Key:     deadLetterKey,
+		Process: c.process,
+		Settle:  c.settle,
+		Timeout: 5 * time.Second,
+	})
+	err := c.Broker.Subscribe(queueName, consumerName, handler, options)
+	if err != nil {
+		log.Fatalf("Failed to start consuming dead letters: %v", err)
+	}
+	log.Printf("Started collecting dead letters from queue: %s", queueName)
+}
+
+func (c *DeadLetterCollector) process(ctx context.Context, msg *messaging.Message) error {
+	deadLetter := newDeadLetter(msg)
+
+	err := c.UnitOfWork.Do(ctx, func(repos repository.Repositories) error {
+		if err := repos.DeadLetters().Create(deadLetter); err != nil {
+			return err
+		}
+		return markMessageProcessed(ctx, repos)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Stored dead letter %s from %s (%s): %s", deadLetter.ID, deadLetter.RoutingKey, deadLetter.FailureKind, deadLetter.FailureReason)
+	return nil
+}
+
+func (c *DeadLetterCollector) settle(msg *messaging.Message, err error) {
+	if err == nil || errors.Is(err, ErrMessageAlreadyProcessed) {
+		msg.Ack()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log.Printf("Error storing dead letter %s, trying again in %s: %v", msg.ID, c.Delay.Delay, err)
+	if err := c.Publisher.Publish(ctx, delayedDeadLetter(msg, c.Delay.Queue)); err != nil {
+		log.Printf("Error delaying dead letter %s: %v", msg.ID, err)
+		msg.Nack(true)
+		return
+	}
+	msg.Ack()
+}
+
+// delayedDeadLetter copies msg to queue with where and when it failed recorded in its
+// headers, since the delay queue adds its own x-death entry when the copy comes back.
+// The copy keeps the deduplication key of msg.
+func delayedDeadLetter(msg *messaging.Message, queue string) messaging.Message {
+	deadLetter := newDeadLetter(msg)
+	headers := make(map[string]interface{}, len(msg.Headers)+4)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[messaging.HeaderOriginalExchange] = deadLetter.Exchange
+	headers[messaging.HeaderOriginalRoutingKey] = deadLetter.RoutingKey
+	headers[messaging.HeaderFailureKind] = deadLetter.FailureKind
+	headers[messaging.HeaderFailedAt] = failedAt(msg)
+
+	return messaging.Message{
+		ID:          msg.ID,
+		RoutingKey:  queue,
+		ContentType: msg.ContentType,
+		Headers:     headers,
+		Body:        msg.Body,
+		Timestamp:   msg.Timestamp,
+	}
+}
+
+// deadLetterKey identifies one failure of a message, since a replayed message that
+// fails again comes back to the dead-letter queue with the same ID.
+func deadLetterKey(msg *messaging.Message) string {
+	if msg.ID == "" {
+		return ""
+	}
+	return msg.ID + "@" + failedAt(msg)
+}
+
+// failedAt is when msg failed: the time recorded by the consumer that dead-lettered
+// it, or else the time of its first x-death entry.
+func failedAt(msg *messaging.Message) string {
+	if _, ok := msg.Headers[messaging.HeaderFailedAt]; ok {
+		return msg.Header(messaging.HeaderFailedAt)
+	}
+	if death, ok := firstDeath(msg); ok {
+		if at, ok := death["time"].(time.Time); ok {
+			return at.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// newDeadLetter reads where the message was first published and why it failed from the
+// headers set by the consumers, or from the x-death header set by the broker when the
+// message was rejected or expired.
+func newDeadLetter(msg *messaging.Message) *entity.DeadLetter {
+	deadLetter := &entity.DeadLetter{
+		ID:            uuid.NewString(),
+		MessageID:     msg.ID,
+		Exchange:      msg.Exchange,
+		RoutingKey:    msg.RoutingKey,
+		ContentType:   msg.ContentType,
+		Headers:       msg.Headers,
+		Body:          msg.Body,
+		FailureKind:   msg.Header(messaging.HeaderFailureKind),
+		FailureReason: msg.Header(messaging.HeaderFailureReason),
+		Attempts:      msg.Attempt(),
+		Status:        entity.DeadLetterStatusPending,
+		ReceivedAt:    time.Now(),
+	}
+
+	if death, ok := firstDeath(msg); ok {
+		if exchange, ok := death["exchange"].(string); ok {
+			deadLetter.Exchange = exchange
+		}
+		if routingKeys, ok := death["routing-keys"].([]interface{}); ok && len(routingKeys) > 0 {
+			if routingKey, ok := routingKeys[0].(string); ok {
+				deadLetter.RoutingKey = routingKey
+			}
+		}
+		if _, ok := msg.Headers[messaging.HeaderFailureKind]; !ok {
+			deadLetter.FailureKind, _ = death["reason"].(string)
+		}
+	}
+
+	if _, ok := msg.Headers[messaging.HeaderOriginalRoutingKey]; ok {
+		deadLetter.Exchange = msg.Header(messaging.HeaderOriginalExchange)
+		deadLetter.RoutingKey = msg.Header(messaging.HeaderOriginalRoutingKey)
+	}
+
+	return deadLetter
+}
+
+// firstDeath returns the oldest x-death entry, which describes the first queue that dead-lettered the message.
+func firstDeath(msg *messaging.Message) (map[string]interface{}, bool) {
+	deaths, ok := msg.Headers[messaging.HeaderDeath].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return nil, false
+	}
+	death, ok := deaths[len(deaths)-1].(map[string]interface{})
+	return death, ok
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"gateway-payments/internal/domain/messaging"
+	"testing"
+	"time"
+)
+
+func TestDelayedDeadLetterKeepsItsKey(t *testing.T) {
+	diedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]interface{}
+	}{
+		{
+			name: "dead-lettered by a consumer",
+			headers: map[string]interface{}{
+				messaging.HeaderFailedAt:           "2024-05-01T10:00:00Z",
+				messaging.HeaderFailureKind:        messaging.FailureKindPoison,
+				messaging.HeaderOriginalExchange:   "payments.exchange",
+				messaging.HeaderOriginalRoutingKey: "payment.requested",
+			},
+		},
+		{
+			name: "dead-lettered by the broker",
+			headers: map[string]interface{}{
+				messaging.HeaderDeath: []interface{}{map[string]interface{}{
+					"queue":        "payment.requested.v2.queue",
+					"reason":       "rejected",
+					"exchange":     "payments.exchange",
+					"routing-keys": []interface{}{"payment.requested"},
+					"time":         diedAt,
+				}},
+			},
+		},
+		{name: "without failure headers", headers: map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &messaging.Message{ID: "message-1", Exchange: "payments.exchange", RoutingKey: "payment.dead", Headers: tt.headers}
+			want := newDeadLetter(msg)
+
+			delayed := delayedDeadLetter(msg, "payments.dlq.delay")
+			if delayed.Exchange != "" || delayed.RoutingKey != "payments.dlq.delay" {
+				t.Errorf("delayed to %q %q", delayed.Exchange, delayed.RoutingKey)
+			}
+
+			// The delay queue dead-letters the copy back with an x-death entry of its own
+			deaths, _ := delayed.Headers[messaging.HeaderDeath].([]interface{})
+			delayed.Headers[messaging.HeaderDeath] = append([]interface{}{map[string]interface{}{
+				"queue":        "payments.dlq.delay",
+				"reason":       "expired",
+				"exchange":     "",
+				"routing-keys": []interface{}{"payments.dlq.delay"},
+				"time":         diedAt.Add(time.Minute),
+			}}, deaths...)
+			delayed.RoutingKey = "payments.dlq"
+
+			if got := deadLetterKey(&delayed); got != deadLetterKey(msg) {
+				t.Errorf("key = %q, want %q", got, deadLetterKey(msg))
+			}
+			got := newDeadLetter(&delayed)
+			if got.Exchange != want.Exchange || got.RoutingKey != want.RoutingKey || got.FailureKind != want.FailureKind {
+				t.Errorf("dead letter from %q %q (%s), want %q %q (%s)", got.Exchange, got.RoutingKey, got.FailureKind, want.Exchange, want.RoutingKey, want.FailureKind)
+			}
+		})
+	}
+}
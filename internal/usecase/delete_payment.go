@@ -1,27 +1,54 @@
 package usecase
 
 import (
+	"fmt"
+	"gateway-payments/internal/domain/entity"
 	"gateway-payments/internal/domain/repository"
+	"time"
+)
+
+// Audit log actions on payments
+const (
+	AuditActionPaymentDelete           = "payment.delete"
+	AuditActionPaymentLegalHoldPlace   = "payment.legal_hold.place"
+	AuditActionPaymentLegalHoldRelease = "payment.legal_hold.release"
+	AuditTargetPayment                 = "payment"
 )
 
 type DeletePaymentInput struct {
-	ID string
+	ID    string
+	Actor string
 }
 
+// DeletePayment soft-deletes a payment. Payments that moved money or are under legal
+// hold cannot be deleted; the others are purged by PurgeDeletedPayments after the
+// retention period.
 type DeletePayment struct {
-	Repo repository.PaymentRepository
+	Repo      repository.PaymentRepository
+	AuditRepo repository.AuditRepository
 }
 
-func NewDeletePaymentUseCase(repo repository.PaymentRepository) *DeletePayment {
+func NewDeletePaymentUseCase(repo repository.PaymentRepository, auditRepo repository.AuditRepository) *DeletePayment {
 	return &DeletePayment{
-		Repo: repo,
+		Repo:      repo,
+		AuditRepo: auditRepo,
 	}
 }
 
 func (dp *DeletePayment) Execute(input DeletePaymentInput) error {
-	err := dp.Repo.Delete(input.ID)
+	payment, err := dp.Repo.FindByID(input.ID)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if err := payment.Delete(input.Actor, time.Now()); err != nil {
+		return err
+	}
+	if err := dp.Repo.Update(payment); err != nil {
+		return err
+	}
+
+	// The audit entry outlives the payment once it is purged
+	details := fmt.Sprintf("deleted %s payment of %s for order %s (attempt %d)", payment.Status, payment.Amount, payment.OrderID, payment.Attempt)
+	return dp.AuditRepo.Create(entity.NewAuditEntry(input.Actor, AuditActionPaymentDelete, AuditTargetPayment, payment.ID, details))
 }
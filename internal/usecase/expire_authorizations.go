@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// AuthorizationExpiredReason is recorded on payments expired by ExpireAuthorizations.
+const AuthorizationExpiredReason = "authorization expired"
+
+// ExpireAuthorizations releases AUTHORIZED payments that were not captured within Window,
+// moving them to EXPIRED so the order can be paid again.
+type ExpireAuthorizations struct {
+	Repo       repository.PaymentRepository
+	Processors *processor.Registry
+	Window     time.Duration
+	BatchSize  int
+}
+
+func NewExpireAuthorizationsUseCase(repo repository.PaymentRepository, processors *processor.Registry, window time.Duration, batchSize int) *ExpireAuthorizations {
+	return &ExpireAuthorizations{
+		Repo:       repo,
+		Processors: processors,
+		Window:     window,
+		BatchSize:  batchSize,
+	}
+}
+
+// Run checks for expired authorizations every interval until ctx is cancelled.
+func (ea *ExpireAuthorizations) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Authorization expiry job started (window %s, interval %s)", ea.Window, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := ea.Execute(ctx)
+		if err != nil {
+			log.Printf("Error expiring authorizations: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d authorizations", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Authorization expiry job stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute expires one batch of authorizations and returns how many were expired.
+func (ea *ExpireAuthorizations) Execute(ctx context.Context) (int, error) {
+	now := time.Now()
+	payments, err := ea.Repo.FindAuthorizedBefore(now.Add(-ea.Window), now, ea.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, payment := range payments {
+		if !payment.AuthorizationExpired(ea.Window, now) {
+			continue
+		}
+		// Uma liberação repetida (Update falhou na rodada anterior) volta como already_released
+		if err := releaseAuthorization(ctx, ea.Processors, payment); err != nil {
+			ea.postpone(payment, now, err)
+			continue
+		}
+		if err := payment.Expire(AuthorizationExpiredReason); err != nil {
+			log.Printf("Error expiring payment %s: %v", payment.ID, err)
+			continue
+		}
+
+		messages, err := paymentEventMessages(ctx, payment)
+		if err != nil {
+			return expired, err
+		}
+		if err := ea.Repo.Update(payment, messages...); err != nil {
+			log.Printf("Error saving expired payment %s: %v", payment.ID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// postpone records a failed release of the authorization of payment, so the next
+// attempt waits and the batch moves on to other payments.
+func (ea *ExpireAuthorizations) postpone(payment *entity.Payment, now time.Time, cause error) {
+	delay := retryBackoff(payment.ExpiryAttempts + 1)
+	payment.ExpiryFailed(now.Add(delay))
+	log.Printf("Error releasing the authorization of payment %s (attempt %d), trying again in %s: %v", payment.ID, payment.ExpiryAttempts, delay, cause)
+	if err := ea.Repo.Update(payment); err != nil {
+		log.Printf("Error postponing the expiry of payment %s: %v", payment.ID, err)
+	}
+}
+
+// retryBackoff is how long background jobs wait to retry an operation at the processor:
+// it doubles from one minute with every failed attempt, up to six hours.
+func retryBackoff(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
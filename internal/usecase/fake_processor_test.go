@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/processor"
+	"sync"
+)
+
+// fakeProcessor approves every authorization unless told otherwise and records the calls.
+type fakeProcessor struct {
+	mu           sync.Mutex
+	authorizeErr error
+	refundErr    error
+	// refundDecline makes refunds come back declined with this code
+	refundDecline string
+	authorized    []processor.AuthorizeRequest
+	refunded      []processor.RefundRequest
+	voided        []processor.VoidRequest
+}
+
+func (p *fakeProcessor) Authorize(ctx context.Context, request processor.AuthorizeRequest) (*processor.Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authorized = append(p.authorized, request)
+	if p.authorizeErr != nil {
+		err := p.authorizeErr
+		p.authorizeErr = nil
+		return nil, err
+	}
+	return &processor.Result{Approved: true, Reference: "ref-" + request.PaymentID}, nil
+}
+
+func (p *fakeProcessor) Capture(ctx context.Context, request processor.CaptureRequest) (*processor.Result, error) {
+	return &processor.Result{Approved: true, Reference: request.Reference}, nil
+}
+
+func (p *fakeProcessor) Refund(ctx context.Context, request processor.RefundRequest) (*processor.Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refunded = append(p.refunded, request)
+	if p.refundErr != nil {
+		return nil, p.refundErr
+	}
+	if p.refundDecline != "" {
+		return &processor.Result{DeclineCode: p.refundDecline, DeclineReason: "declined"}, nil
+	}
+	return &processor.Result{Approved: true, Reference: request.Reference}, nil
+}
+
+func (p *fakeProcessor) Void(ctx context.Context, request processor.VoidRequest) (*processor.Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.voided = append(p.voided, request)
+	return &processor.Result{Approved: true, Reference: request.Reference}, nil
+}
+
+func (p *fakeProcessor) calls() (authorized []processor.AuthorizeRequest, refunded []processor.RefundRequest, voided []processor.VoidRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append(authorized, p.authorized...), append(refunded, p.refunded...), append(voided, p.voided...)
+}
@@ -0,0 +1,302 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"sync"
+	"time"
+)
+
+// fakeStore keeps payments, refunds, outbox messages and processed messages in memory. Its
+// unit of work rolls every write back when the function fails, like the MySQL one.
+type fakeStore struct {
+	mu        sync.Mutex
+	payments  []entity.Payment
+	refunds   []entity.Refund
+	outbox    []*entity.OutboxMessage
+	processed map[string]entity.ProcessedMessage
+
+	// beforeCreate runs once, before the next Create or unit of work, to let a test
+	// store a concurrent attempt first
+	beforeCreate func()
+	// commitErr makes the next unit of work fail after its function ran
+	commitErr error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{processed: make(map[string]entity.ProcessedMessage)}
+}
+
+func (s *fakeStore) Payments() repository.PaymentRepository {
+	return &fakePaymentRepository{store: s}
+}
+
+func (s *fakeStore) Refunds() repository.RefundRepository {
+	return &fakeRefundRepository{store: s}
+}
+
+func (s *fakeStore) ProcessedMessages() repository.ProcessedMessageRepository {
+	return &fakeProcessedMessageRepository{store: s}
+}
+
+func (s *fakeStore) runBeforeCreate() {
+	if hook := s.beforeCreate; hook != nil {
+		s.beforeCreate = nil
+		hook()
+	}
+}
+
+// seed stores payment as if it had been created earlier.
+func (s *fakeStore) seed(payment *entity.Payment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payment.Version = 1
+	s.payments = append(s.payments, *payment)
+}
+
+// attempts returns copies of the stored attempts of orderID, in order.
+func (s *fakeStore) attempts(orderID string) []entity.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var attempts []entity.Payment
+	for _, payment := range s.payments {
+		if payment.OrderID == orderID {
+			attempts = append(attempts, payment)
+		}
+	}
+	return attempts
+}
+
+func (s *fakeStore) outboxMessages() []*entity.OutboxMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*entity.OutboxMessage(nil), s.outbox...)
+}
+
+func (s *fakeStore) processedMessage(consumer, messageID string) (entity.ProcessedMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	message, ok := s.processed[consumer+"/"+messageID]
+	return message, ok
+}
+
+type fakeUnitOfWork struct {
+	store *fakeStore
+}
+
+func (u *fakeUnitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	// A concurrent request commits outside this transaction and survives its rollback
+	u.store.runBeforeCreate()
+
+	u.store.mu.Lock()
+	payments := append([]entity.Payment(nil), u.store.payments...)
+	refunds := append([]entity.Refund(nil), u.store.refunds...)
+	outbox := append([]*entity.OutboxMessage(nil), u.store.outbox...)
+	processed := make(map[string]entity.ProcessedMessage, len(u.store.processed))
+	for key, message := range u.store.processed {
+		processed[key] = message
+	}
+	u.store.mu.Unlock()
+
+	err := fn(&fakeRepositories{store: u.store})
+	if err == nil && u.store.commitErr != nil {
+		err, u.store.commitErr = u.store.commitErr, nil
+	}
+	if err != nil {
+		u.store.mu.Lock()
+		u.store.payments, u.store.refunds, u.store.outbox, u.store.processed = payments, refunds, outbox, processed
+		u.store.mu.Unlock()
+	}
+	return err
+}
+
+// fakeRepositories leaves the repositories the tests do not need unset.
+type fakeRepositories struct {
+	repository.Repositories
+	store *fakeStore
+}
+
+func (r *fakeRepositories) Payments() repository.PaymentRepository {
+	return r.store.Payments()
+}
+
+func (r *fakeRepositories) Refunds() repository.RefundRepository {
+	return r.store.Refunds()
+}
+
+func (r *fakeRepositories) ProcessedMessages() repository.ProcessedMessageRepository {
+	return r.store.ProcessedMessages()
+}
+
+type fakePaymentRepository struct {
+	repository.PaymentRepository
+	store *fakeStore
+}
+
+func (r *fakePaymentRepository) Create(payment *entity.Payment, messages ...*entity.OutboxMessage) error {
+	r.store.runBeforeCreate()
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.payments {
+		// The unique keys of (order_id, attempt) and of the live attempt of an order
+		if existing.OrderID == payment.OrderID && (existing.Attempt == payment.Attempt || !existing.AllowsNewAttempt()) {
+			return repository.ErrDuplicateKey
+		}
+	}
+	payment.Version = 1
+	r.store.payments = append(r.store.payments, *payment)
+	r.store.outbox = append(r.store.outbox, messages...)
+	return nil
+}
+
+func (r *fakePaymentRepository) Update(payment *entity.Payment, messages ...*entity.OutboxMessage) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i, existing := range r.store.payments {
+		if existing.ID != payment.ID {
+			continue
+		}
+		if existing.Version != payment.Version {
+			return &repository.ErrConcurrentModification{Entity: "payment", ID: payment.ID, Version: payment.Version}
+		}
+		payment.Version++
+		r.store.payments[i] = *payment
+		r.store.outbox = append(r.store.outbox, messages...)
+		return nil
+	}
+	return &repository.ErrNotFound{Message: "payment not found"}
+}
+
+func (r *fakePaymentRepository) FindByID(id string) (*entity.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, payment := range r.store.payments {
+		if payment.ID == id && !payment.IsDeleted() {
+			found := payment
+			return &found, nil
+		}
+	}
+	return nil, &repository.ErrNotFound{Message: "payment not found"}
+}
+
+func (r *fakePaymentRepository) FindByOrderID(orderID string) (*entity.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var last *entity.Payment
+	for _, payment := range r.store.payments {
+		if payment.OrderID == orderID && (last == nil || payment.Attempt > last.Attempt) {
+			found := payment
+			last = &found
+		}
+	}
+	if last == nil {
+		return nil, &repository.ErrNotFound{Message: "payment not found"}
+	}
+	return last, nil
+}
+
+type fakeRefundRepository struct {
+	store *fakeStore
+}
+
+func (r *fakeRefundRepository) Create(refund *entity.Refund) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.refunds = append(r.store.refunds, *refund)
+	return nil
+}
+
+func (r *fakeRefundRepository) Update(refund *entity.Refund) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i, existing := range r.store.refunds {
+		if existing.ID != refund.ID {
+			continue
+		}
+		if existing.Status != entity.RefundStatusPending {
+			return &repository.ErrConcurrentModification{Entity: "refund", ID: refund.ID}
+		}
+		r.store.refunds[i] = *refund
+		return nil
+	}
+	return &repository.ErrNotFound{Message: "refund not found"}
+}
+
+func (r *fakeRefundRepository) FindByPaymentID(paymentID string) ([]*entity.Refund, error) {
+	return r.find(func(refund entity.Refund) bool { return refund.PaymentID == paymentID }), nil
+}
+
+func (r *fakeRefundRepository) FindPendingBefore(before time.Time, now time.Time, limit int) ([]*entity.Refund, error) {
+	refunds := r.find(func(refund entity.Refund) bool {
+		return refund.Status == entity.RefundStatusPending && refund.CreatedAt.Before(before) &&
+			(refund.NextReconcileAt == nil || !refund.NextReconcileAt.After(now))
+	})
+	if len(refunds) > limit {
+		refunds = refunds[:limit]
+	}
+	return refunds, nil
+}
+
+func (r *fakeRefundRepository) find(match func(refund entity.Refund) bool) []*entity.Refund {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var refunds []*entity.Refund
+	for _, refund := range r.store.refunds {
+		if match(refund) {
+			found := refund
+			refunds = append(refunds, &found)
+		}
+	}
+	return refunds
+}
+
+type fakeProcessedMessageRepository struct {
+	store *fakeStore
+}
+
+func (r *fakeProcessedMessageRepository) Claim(message *entity.ProcessedMessage) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := message.Consumer + "/" + message.MessageID
+	if existing, ok := r.store.processed[key]; ok && (existing.Processed() || time.Now().Before(existing.LockedUntil)) {
+		return repository.ErrDuplicateKey
+	}
+	r.store.processed[key] = *message
+	return nil
+}
+
+func (r *fakeProcessedMessageRepository) Find(consumer string, messageID string) (*entity.ProcessedMessage, error) {
+	message, ok := r.store.processedMessage(consumer, messageID)
+	if !ok {
+		return nil, &repository.ErrNotFound{Message: "processed message not found"}
+	}
+	return &message, nil
+}
+
+func (r *fakeProcessedMessageRepository) MarkProcessed(consumer string, messageID string, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := consumer + "/" + messageID
+	message, ok := r.store.processed[key]
+	if !ok {
+		return &repository.ErrNotFound{Message: "processed message not found"}
+	}
+	message.Status = entity.ProcessedMessageStatusProcessed
+	message.ProcessedAt = &at
+	r.store.processed[key] = message
+	return nil
+}
+
+func (r *fakeProcessedMessageRepository) Release(consumer string, messageID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delete(r.store.processed, consumer+"/"+messageID)
+	return nil
+}
+
+func (r *fakeProcessedMessageRepository) DeleteCreatedBefore(before time.Time, limit int) (int64, error) {
+	return 0, errors.New("not implemented")
+}
@@ -1,26 +1,77 @@
 package usecase
 
 import (
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
 	"gateway-payments/internal/domain/entity"
 	"gateway-payments/internal/domain/repository"
+	"strings"
+	"time"
 )
 
+const (
+	defaultPaymentsPageLimit = 10
+	// MaxPaymentsPageLimit caps the page size of GET /payments
+	MaxPaymentsPageLimit = 100
+)
+
+// ErrInvalidQuery is returned when a listing parameter cannot be used.
+type ErrInvalidQuery struct {
+	Parameter string
+	Reason    string
+}
+
+func (e *ErrInvalidQuery) ErrorCode() apperr.Code {
+	return apperr.CodeInvalidParameter
+}
+
+func (e *ErrInvalidQuery) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Parameter, e.Reason)
+}
+
+// GetAllPaymentsInput holds the listing parameters as they were received. Amounts are
+// decimals in major units of Currency, which is required to filter by amount.
 type GetAllPaymentsInput struct {
+	Statuses    []string
+	Methods     []string
+	OrderID     string
+	Currency    string
+	MinAmount   string
+	MaxAmount   string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// IncludeDeleted also lists soft-deleted payments
+	IncludeDeleted bool
+	// Sort lists the sort fields separated by commas, each prefixed with "-" for
+	// descending order, e.g. "-created_at,amount". Defaults to "-created_at".
+	Sort  string
 	Page  int
 	Limit int
+	// Cursor continues a listing from a NextCursor or PrevCursor, instead of Page
+	Cursor string
 }
 
+// GetAllPaymentsOutput is a page of payments. Pages selected by cursor are not counted,
+// so Total is nil and Page is 0. The cursors are only set when the listing is sorted
+// by created_at alone, which is the order keyset pagination follows.
 type GetAllPaymentsOutput struct {
-	Payments []*entity.Payment
+	Payments   []*entity.Payment
+	Total      *int
+	Page       int
+	Limit      int
+	NextCursor string
+	PrevCursor string
 }
 
 type GetAllPayments struct {
-	Repo repository.PaymentRepository
+	Repo    repository.PaymentRepository
+	Cursors *PaymentCursors
 }
 
-func NewGetAllPaymentsUseCase(repo repository.PaymentRepository) *GetAllPayments {
+func NewGetAllPaymentsUseCase(repo repository.PaymentRepository, cursors *PaymentCursors) *GetAllPayments {
 	return &GetAllPayments{
-		Repo: repo,
+		Repo:    repo,
+		Cursors: cursors,
 	}
 }
 
@@ -29,13 +80,224 @@ func (gap *GetAllPayments) Execute(input GetAllPaymentsInput) (*GetAllPaymentsOu
 		input.Page = 1
 	}
 	if input.Limit <= 0 {
-		input.Limit = 10
+		input.Limit = defaultPaymentsPageLimit
+	}
+	if input.Limit > MaxPaymentsPageLimit {
+		input.Limit = MaxPaymentsPageLimit
+	}
+
+	filter, err := paymentFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	sort, err := paymentSort(input.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Cursor != "" {
+		return gap.executeFromCursor(input, filter, sort)
+	}
+
+	total, err := gap.Repo.Count(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &GetAllPaymentsOutput{
+		Payments: []*entity.Payment{},
+		Total:    &total,
+		Page:     input.Page,
+		Limit:    input.Limit,
+	}
+	if (input.Page-1)*input.Limit >= total {
+		return output, nil
+	}
+
+	output.Payments, err = gap.Repo.FindAll(repository.PaymentQuery{
+		Filter: filter,
+		Sort:   sort,
+		Page:   input.Page,
+		Limit:  input.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cursores permitem que clientes de page/limit passem para a paginação por chave
+	if descending, ok := keysetOrder(sort); ok && len(output.Payments) > 0 {
+		fingerprint := filterFingerprint(filter)
+		if input.Page*input.Limit < total {
+			output.NextCursor = gap.cursor(output.Payments[len(output.Payments)-1], descending, false, fingerprint)
+		}
+		if input.Page > 1 {
+			output.PrevCursor = gap.cursor(output.Payments[0], descending, true, fingerprint)
+		}
+	}
+
+	return output, nil
+}
+
+// executeFromCursor selects the page after, or before, the cursor position. One extra
+// payment is read to know whether the listing continues in that direction.
+func (gap *GetAllPayments) executeFromCursor(input GetAllPaymentsInput, filter repository.PaymentFilter, sort []repository.PaymentSort) (*GetAllPaymentsOutput, error) {
+	cursor, err := gap.Cursors.Decode(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := filterFingerprint(filter)
+	if cursor.Filter != fingerprint {
+		return nil, &ErrInvalidQuery{Parameter: "cursor", Reason: "the cursor belongs to a listing with other filters"}
+	}
+	if input.Sort != "" {
+		descending, ok := keysetOrder(sort)
+		if !ok || descending != cursor.Descending {
+			return nil, &ErrInvalidQuery{Parameter: "sort", Reason: "cursors only follow the created_at order of the listing that produced them"}
+		}
 	}
 
-	payments, err := gap.Repo.FindAll(input.Page, input.Limit)
+	// A página anterior é lida na ordem inversa e depois desinvertida
+	descending := cursor.Descending != cursor.Backward
+	payments, err := gap.Repo.FindAll(repository.PaymentQuery{
+		Filter: filter,
+		Sort:   []repository.PaymentSort{{Field: repository.PaymentSortCreatedAt, Descending: descending}},
+		Limit:  input.Limit + 1,
+		After:  &cursor.Key,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &GetAllPaymentsOutput{Payments: payments}, nil
+	more := len(payments) > input.Limit
+	if more {
+		payments = payments[:input.Limit]
+	}
+	if cursor.Backward {
+		for i, j := 0, len(payments)-1; i < j; i, j = i+1, j-1 {
+			payments[i], payments[j] = payments[j], payments[i]
+		}
+	}
+
+	output := &GetAllPaymentsOutput{
+		Payments: payments,
+		Limit:    input.Limit,
+	}
+	if len(payments) == 0 {
+		return output, nil
+	}
+	// The page the cursor came from lies on the other side of it
+	if more || cursor.Backward {
+		output.NextCursor = gap.cursor(payments[len(payments)-1], cursor.Descending, false, fingerprint)
+	}
+	if more || !cursor.Backward {
+		output.PrevCursor = gap.cursor(payments[0], cursor.Descending, true, fingerprint)
+	}
+
+	return output, nil
+}
+
+func (gap *GetAllPayments) cursor(payment *entity.Payment, descending, backward bool, fingerprint string) string {
+	return gap.Cursors.Encode(PaymentCursor{
+		Key:        repository.PaymentKey{CreatedAt: payment.CreatedAt, ID: payment.ID},
+		Descending: descending,
+		Backward:   backward,
+		Filter:     fingerprint,
+	})
+}
+
+// keysetOrder reports whether sort can be paginated by cursor, and in which direction.
+func keysetOrder(sort []repository.PaymentSort) (descending bool, ok bool) {
+	if len(sort) != 1 || sort[0].Field != repository.PaymentSortCreatedAt {
+		return false, false
+	}
+	return sort[0].Descending, true
+}
+
+func paymentFilter(input GetAllPaymentsInput) (repository.PaymentFilter, error) {
+	filter := repository.PaymentFilter{
+		OrderID:     strings.TrimSpace(input.OrderID),
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+
+		IncludeDeleted: input.IncludeDeleted,
+	}
+
+	for _, status := range input.Statuses {
+		normalized, err := entity.ParseStatus(status)
+		if err != nil {
+			return filter, err
+		}
+		filter.Statuses = append(filter.Statuses, normalized)
+	}
+	for _, method := range input.Methods {
+		if method = strings.TrimSpace(method); method != "" {
+			filter.Methods = append(filter.Methods, method)
+		}
+	}
+
+	if input.Currency != "" {
+		money, err := entity.NewMoney(0, input.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.Currency = money.Currency
+	}
+
+	// Valores em unidades menores só são comparáveis dentro da mesma moeda
+	if (input.MinAmount != "" || input.MaxAmount != "") && filter.Currency == "" {
+		return filter, &ErrInvalidQuery{Parameter: "amount range", Reason: "currency is required to filter by amount"}
+	}
+	if input.MinAmount != "" {
+		money, err := entity.ParseMoney(input.MinAmount, filter.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinAmount = &money.Amount
+	}
+	if input.MaxAmount != "" {
+		money, err := entity.ParseMoney(input.MaxAmount, filter.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MaxAmount = &money.Amount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, &ErrInvalidQuery{Parameter: "amount range", Reason: "amount_min is greater than amount_max"}
+	}
+
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return filter, &ErrInvalidQuery{Parameter: "created_at range", Reason: "created_from must be before created_to"}
+	}
+
+	return filter, nil
+}
+
+// paymentSort parses a sort expression such as "-created_at,amount".
+func paymentSort(expression string) ([]repository.PaymentSort, error) {
+	if strings.TrimSpace(expression) == "" {
+		return []repository.PaymentSort{{Field: repository.PaymentSortCreatedAt, Descending: true}}, nil
+	}
+
+	var sorts []repository.PaymentSort
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(expression, ",") {
+		key = strings.TrimSpace(key)
+		descending := strings.HasPrefix(key, "-")
+		field := strings.TrimPrefix(strings.TrimPrefix(key, "-"), "+")
+
+		switch field {
+		case repository.PaymentSortCreatedAt, repository.PaymentSortUpdatedAt, repository.PaymentSortAmount, repository.PaymentSortStatus:
+		default:
+			return nil, &ErrInvalidQuery{Parameter: "sort", Reason: fmt.Sprintf("cannot sort by %q", field)}
+		}
+		if seen[field] {
+			return nil, &ErrInvalidQuery{Parameter: "sort", Reason: fmt.Sprintf("%s is repeated", field)}
+		}
+		seen[field] = true
+
+		sorts = append(sorts, repository.PaymentSort{Field: field, Descending: descending})
+	}
+
+	return sorts, nil
 }
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+)
+
+type GetDeadLettersInput struct {
+	Status string // empty lists every status
+	Page   int
+	Limit  int
+}
+
+type GetDeadLetters struct {
+	Repo repository.DeadLetterRepository
+}
+
+func NewGetDeadLettersUseCase(repo repository.DeadLetterRepository) *GetDeadLetters {
+	return &GetDeadLetters{
+		Repo: repo,
+	}
+}
+
+func (gd *GetDeadLetters) Execute(input GetDeadLettersInput) ([]*entity.DeadLetter, error) {
+	if input.Page <= 0 {
+		input.Page = 1
+	}
+	if input.Limit <= 0 {
+		input.Limit = 10
+	}
+
+	return gd.Repo.FindAll(input.Status, input.Page, input.Limit)
+}
+
+type GetDeadLetter struct {
+	Repo repository.DeadLetterRepository
+}
+
+func NewGetDeadLetterUseCase(repo repository.DeadLetterRepository) *GetDeadLetter {
+	return &GetDeadLetter{
+		Repo: repo,
+	}
+}
+
+func (gd *GetDeadLetter) Execute(id string) (*entity.DeadLetter, error) {
+	return gd.Repo.FindByID(id)
+}
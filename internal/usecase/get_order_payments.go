@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"strings"
+)
+
+type GetOrderPaymentsInput struct {
+	OrderID        string
+	IncludeDeleted bool
+}
+
+// GetOrderPayments lists every payment attempt of an order, oldest first.
+type GetOrderPayments struct {
+	Repo repository.PaymentRepository
+}
+
+func NewGetOrderPaymentsUseCase(repo repository.PaymentRepository) *GetOrderPayments {
+	return &GetOrderPayments{
+		Repo: repo,
+	}
+}
+
+func (gop *GetOrderPayments) Execute(input GetOrderPaymentsInput) ([]*entity.Payment, error) {
+	orderID := strings.TrimSpace(input.OrderID)
+	if orderID == "" {
+		return nil, &ErrInvalidQuery{Parameter: "order_id", Reason: "must not be empty"}
+	}
+
+	payments, err := gop.Repo.FindAllByOrderID(orderID, input.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	// The gateway only knows orders through their payments
+	if len(payments) == 0 {
+		return nil, &repository.ErrNotFound{Message: fmt.Sprintf("no payments found for order ID %s", orderID)}
+	}
+
+	return payments, nil
+}
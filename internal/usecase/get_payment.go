@@ -6,7 +6,8 @@ import (
 )
 
 type GetPaymentInput struct {
-	ID string
+	ID             string
+	IncludeDeleted bool
 }
 
 type GetPayment struct {
@@ -20,7 +21,11 @@ func NewGetPaymentUseCase(repo repository.PaymentRepository) *GetPayment {
 }
 
 func (gp *GetPayment) Execute(input GetPaymentInput) (*entity.Payment, error) {
-	payment, err := gp.Repo.FindByID(input.ID)
+	find := gp.Repo.FindByID
+	if input.IncludeDeleted {
+		find = gp.Repo.FindByIDIncludingDeleted
+	}
+	payment, err := find(input.ID)
 	if err != nil {
 		return nil, err
 	}
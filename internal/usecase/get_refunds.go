@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+)
+
+type GetRefundsInput struct {
+	PaymentID string
+}
+
+type GetRefunds struct {
+	PaymentRepo repository.PaymentRepository
+	RefundRepo  repository.RefundRepository
+}
+
+func NewGetRefundsUseCase(paymentRepo repository.PaymentRepository, refundRepo repository.RefundRepository) *GetRefunds {
+	return &GetRefunds{
+		PaymentRepo: paymentRepo,
+		RefundRepo:  refundRepo,
+	}
+}
+
+func (gr *GetRefunds) Execute(input GetRefundsInput) ([]*entity.Refund, error) {
+	// Distinguishes an unknown payment from a payment without refunds
+	if _, err := gr.PaymentRepo.FindByID(input.PaymentID); err != nil {
+		return nil, err
+	}
+
+	return gr.RefundRepo.FindByPaymentID(input.PaymentID)
+}
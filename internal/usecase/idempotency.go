@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"time"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request body.
+	ErrIdempotencyKeyReused = apperr.New(apperr.CodeIdempotencyKeyReused, "idempotency key was already used with a different request")
+	// ErrIdempotencyKeyInProgress is returned while the original request for a key has not finished.
+	ErrIdempotencyKeyInProgress = apperr.New(apperr.CodeInProgress, "a request with this idempotency key is still being processed")
+)
+
+type IdempotencyInput struct {
+	Key         string
+	RequestHash string
+}
+
+// Idempotency reserves keys for requests. A key left in progress for longer than Lease,
+// because the process handling its request crashed, is handed to the next request.
+type Idempotency struct {
+	Repo  repository.IdempotencyRepository
+	Lease time.Duration
+}
+
+func NewIdempotencyUseCase(repo repository.IdempotencyRepository, lease time.Duration) *Idempotency {
+	return &Idempotency{
+		Repo:  repo,
+		Lease: lease,
+	}
+}
+
+// Begin reserves the key for a new request. When the key was already used with the
+// same fingerprint the stored record is returned so its response can be replayed.
+func (i *Idempotency) Begin(input IdempotencyInput) (*entity.IdempotencyKey, error) {
+	record := entity.NewIdempotencyKey(input.Key, input.RequestHash)
+
+	err := i.Repo.Create(record)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, err
+	}
+
+	existing, err := i.Repo.FindByKey(input.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading idempotency key %s: %w", input.Key, err)
+	}
+	if existing.RequestHash != input.RequestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		now := time.Now()
+		if !existing.Stale(now, i.Lease) {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		taken, err := i.Repo.TakeOver(input.Key, now.Add(-i.Lease), now)
+		if err != nil {
+			return nil, err
+		}
+		if !taken {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		return nil, nil
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response produced for the key.
+func (i *Idempotency) Complete(key string, statusCode int, body []byte) error {
+	return i.Repo.Complete(&entity.IdempotencyKey{
+		Key:          key,
+		StatusCode:   statusCode,
+		ResponseBody: body,
+	})
+}
+
+// Release frees the key so the client can retry after a failure that produced no payment.
+func (i *Idempotency) Release(key string) error {
+	return i.Repo.Delete(key)
+}
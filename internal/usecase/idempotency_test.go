@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeIdempotencyRepository struct {
+	mu   sync.Mutex
+	keys map[string]entity.IdempotencyKey
+}
+
+func (r *fakeIdempotencyRepository) Create(record *entity.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[record.Key]; ok {
+		return repository.ErrDuplicateKey
+	}
+	r.keys[record.Key] = *record
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) FindByKey(key string) (*entity.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.keys[key]
+	if !ok {
+		return nil, &repository.ErrNotFound{Message: "idempotency key not found"}
+	}
+	return &record, nil
+}
+
+func (r *fakeIdempotencyRepository) Complete(record *entity.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.keys[record.Key]
+	stored.StatusCode, stored.ResponseBody = record.StatusCode, record.ResponseBody
+	r.keys[record.Key] = stored
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) TakeOver(key string, staleBefore time.Time, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.keys[key]
+	if !ok || record.Completed() || !record.CreatedAt.Before(staleBefore) {
+		return false, nil
+	}
+	record.CreatedAt = now
+	r.keys[key] = record
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepository) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, key)
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) DeleteCreatedBefore(before time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for key, record := range r.keys {
+		if deleted < int64(limit) && record.CreatedAt.Before(before) {
+			delete(r.keys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestIdempotencyBegin(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  *entity.IdempotencyKey
+		hash      string
+		wantErr   error
+		wantReply bool
+	}{
+		{name: "new key", hash: "a"},
+		{name: "completed key is replayed", existing: &entity.IdempotencyKey{StatusCode: 201, RequestHash: "a", CreatedAt: time.Now()}, hash: "a", wantReply: true},
+		{name: "key reused with another request", existing: &entity.IdempotencyKey{StatusCode: 201, RequestHash: "a", CreatedAt: time.Now()}, hash: "b", wantErr: ErrIdempotencyKeyReused},
+		{name: "key in progress", existing: &entity.IdempotencyKey{RequestHash: "a", CreatedAt: time.Now()}, hash: "a", wantErr: ErrIdempotencyKeyInProgress},
+		{name: "abandoned key is taken over", existing: &entity.IdempotencyKey{RequestHash: "a", CreatedAt: time.Now().Add(-2 * time.Minute)}, hash: "a"},
+		{name: "abandoned key reused with another request", existing: &entity.IdempotencyKey{RequestHash: "a", CreatedAt: time.Now().Add(-2 * time.Minute)}, hash: "b", wantErr: ErrIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeIdempotencyRepository{keys: make(map[string]entity.IdempotencyKey)}
+			if tt.existing != nil {
+				tt.existing.Key = "key-1"
+				repo.keys["key-1"] = *tt.existing
+			}
+
+			stored, err := NewIdempotencyUseCase(repo, time.Minute).Begin(IdempotencyInput{Key: "key-1", RequestHash: tt.hash})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Begin() = %v, want %v", err, tt.wantErr)
+			}
+			if (stored != nil) != tt.wantReply {
+				t.Errorf("Begin() replayed %+v, want replay %v", stored, tt.wantReply)
+			}
+			if tt.wantErr == nil && !tt.wantReply {
+				if record := repo.keys["key-1"]; time.Since(record.CreatedAt) > time.Second {
+					t.Errorf("key created at %s, want it held by this request", record.CreatedAt)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyBeginTakesOverAbandonedKeyOnce(t *testing.T) {
+	repo := &fakeIdempotencyRepository{keys: map[string]entity.IdempotencyKey{
+		"key-1": {Key: "key-1", RequestHash: "a", CreatedAt: time.Now().Add(-2 * time.Minute)},
+	}}
+	idempotency := NewIdempotencyUseCase(repo, time.Minute)
+
+	if _, err := idempotency.Begin(IdempotencyInput{Key: "key-1", RequestHash: "a"}); err != nil {
+		t.Fatalf("first Begin() = %v", err)
+	}
+	if _, err := idempotency.Begin(IdempotencyInput{Key: "key-1", RequestHash: "a"}); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Errorf("second Begin() = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+)
+
+type SetLegalHoldInput struct {
+	ID     string
+	Hold   bool
+	Reason string
+	Actor  string
+}
+
+// SetLegalHold places or releases the legal hold of a payment. A payment under hold
+// cannot be deleted, and is never purged if it was deleted before the hold.
+type SetLegalHold struct {
+	Repo      repository.PaymentRepository
+	AuditRepo repository.AuditRepository
+}
+
+func NewSetLegalHoldUseCase(repo repository.PaymentRepository, auditRepo repository.AuditRepository) *SetLegalHold {
+	return &SetLegalHold{
+		Repo:      repo,
+		AuditRepo: auditRepo,
+	}
+}
+
+func (sl *SetLegalHold) Execute(input SetLegalHoldInput) (*entity.Payment, error) {
+	payment, err := sl.Repo.FindByIDIncludingDeleted(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.LegalHold == input.Hold {
+		return payment, nil
+	}
+
+	payment.LegalHold = input.Hold
+	if err := sl.Repo.Update(payment); err != nil {
+		return nil, err
+	}
+
+	action := AuditActionPaymentLegalHoldPlace
+	if !input.Hold {
+		action = AuditActionPaymentLegalHoldRelease
+	}
+	if err := sl.AuditRepo.Create(entity.NewAuditEntry(input.Actor, action, AuditTargetPayment, payment.ID, input.Reason)); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+var (
+	// ErrMessageAlreadyProcessed is returned for redeliveries of a message the consumer already handled.
+	ErrMessageAlreadyProcessed = apperr.New(apperr.CodeAlreadyProcessed, "message was already processed")
+	// ErrMessageInProgress is returned while another worker holds the claim on the message.
+	ErrMessageInProgress = apperr.New(apperr.CodeInProgress, "message is being processed by another worker")
+)
+
+// MessageDeduplication lets each consumer process a message ID at most once. The claim
+// is a row in processed_messages, so concurrent copies of a message are serialized by
+// the database; a claim left behind by a crashed worker expires after Lease.
+type MessageDeduplication struct {
+	Repo  repository.ProcessedMessageRepository
+	Lease time.Duration
+}
+
+func NewMessageDeduplicationUseCase(repo repository.ProcessedMessageRepository, lease time.Duration) *MessageDeduplication {
+	return &MessageDeduplication{
+		Repo:  repo,
+		Lease: lease,
+	}
+}
+
+// MessageConsumer is what a consumer plugs into MessageDeduplication.Handler.
+type MessageConsumer struct {
+	// Name scopes the deduplication records of the consumer
+	Name string
+	// Key identifies a message for deduplication; the message ID when nil
+	Key func(msg *messaging.Message) string
+	// Process handles a claimed message. Its writes should call markMessageProcessed in
+	// their transaction, so the message is only recorded as processed if they commit
+	Process func(ctx context.Context, msg *messaging.Message) error
+	// Settle acks, requeues or dead-letters the message according to the result of Process
+	Settle  func(msg *messaging.Message, err error)
+	Timeout time.Duration
+}
+
+// Handler returns the broker handler of consumer, which processes every delivery under
+// the consumer's claim on the message.
+func (d *MessageDeduplication) Handler(consumer MessageConsumer) messaging.Handler {
+	return func(msg *messaging.Message) {
+		ctx, cancel := context.WithTimeout(context.Background(), consumer.Timeout)
+		defer cancel()
+
+		key := msg.ID
+		if consumer.Key != nil {
+			key = consumer.Key(msg)
+		}
+		consumer.Settle(msg, d.Run(ctx, consumer.Name, key, msg, consumer.Process))
+	}
+}
+
+// Run claims the message identified by key for consumer and calls process. The message
+// is released when process fails, so a retry can process it again. Messages without a
+// key cannot be deduplicated and are always processed.
+func (d *MessageDeduplication) Run(ctx context.Context, consumer string, key string, msg *messaging.Message, process func(context.Context, *messaging.Message) error) error {
+	if key == "" {
+		return process(ctx, msg)
+	}
+
+	if err := d.claim(consumer, key); err != nil {
+		return err
+	}
+
+	if err := process(withMessageClaim(ctx, consumer, key), msg); err != nil {
+		if releaseErr := d.Repo.Release(consumer, key); releaseErr != nil {
+			// A claim that cannot be released expires after the lease
+			log.Printf("Error releasing message %s for %s: %v", key, consumer, releaseErr)
+		}
+		return err
+	}
+
+	// Handlers that wrote something already marked the message in their transaction;
+	// this covers the ones that had nothing to write
+	if err := d.Repo.MarkProcessed(consumer, key, time.Now()); err != nil {
+		log.Printf("Error marking message %s as processed for %s: %v", key, consumer, err)
+	}
+
+	return nil
+}
+
+type messageClaimKey struct{}
+
+// messageClaim is the claim held by the consumer processing a message.
+type messageClaim struct {
+	consumer  string
+	messageID string
+}
+
+func withMessageClaim(ctx context.Context, consumer, messageID string) context.Context {
+	return context.WithValue(ctx, messageClaimKey{}, messageClaim{consumer: consumer, messageID: messageID})
+}
+
+// markMessageProcessed marks the message being processed under ctx through repos, so
+// the mark commits or rolls back with the writes of the handler. Outside a consumer it
+// does nothing.
+func markMessageProcessed(ctx context.Context, repos repository.Repositories) error {
+	claim, ok := ctx.Value(messageClaimKey{}).(messageClaim)
+	if !ok {
+		return nil
+	}
+	return repos.ProcessedMessages().MarkProcessed(claim.consumer, claim.messageID, time.Now())
+}
+
+func (d *MessageDeduplication) claim(consumer string, messageID string) error {
+	err := d.Repo.Claim(entity.NewProcessedMessage(consumer, messageID, d.Lease))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, repository.ErrDuplicateKey) {
+		return err
+	}
+
+	existing, err := d.Repo.Find(consumer, messageID)
+	if err != nil {
+		var notFound *repository.ErrNotFound
+		if errors.As(err, &notFound) {
+			// The other claim was released in the meantime
+			return ErrMessageInProgress
+		}
+		return fmt.Errorf("error loading processed message %s: %w", messageID, err)
+	}
+	if existing.Processed() {
+		return ErrMessageAlreadyProcessed
+	}
+
+	return ErrMessageInProgress
+}
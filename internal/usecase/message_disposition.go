@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/messaging"
+)
+
+// messageDispositions lists the codes that are neither retried nor dead-lettered.
+var messageDispositions = map[apperr.Code]messaging.Disposition{
+	apperr.CodeAlreadyProcessed: messaging.DispositionAck,
+	apperr.CodeInProgress:       messaging.DispositionRequeue,
+}
+
+// messageDisposition maps a failure to handle a message to what the consumer does
+// with it: retryable codes of the catalog are retried, and the others can never
+// succeed and are dead-lettered.
+func messageDisposition(err error) (apperr.Code, messaging.Disposition) {
+	code := apperr.CodeOf(err)
+	if disposition, ok := messageDispositions[code]; ok {
+		return code, disposition
+	}
+	if apperr.Lookup(code).Retryable {
+		return code, messaging.DispositionRetry
+	}
+	return code, messaging.DispositionDeadLetter
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+	"gateway-payments/internal/domain/validation"
+	"testing"
+)
+
+func TestMessageDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		code        apperr.Code
+		disposition messaging.Disposition
+	}{
+		{name: "already processed", err: ErrMessageAlreadyProcessed, code: apperr.CodeAlreadyProcessed, disposition: messaging.DispositionAck},
+		{name: "claimed by another worker", err: ErrMessageInProgress, code: apperr.CodeInProgress, disposition: messaging.DispositionRequeue},
+		{name: "payment being processed", err: fmt.Errorf("error creating payment: %w", ErrPaymentInProgress), code: apperr.CodeInProgress, disposition: messaging.DispositionRequeue},
+		{name: "processor unavailable", err: fmt.Errorf("error authorizing: %w", processor.ErrUnavailable), code: apperr.CodeProcessorUnavailable, disposition: messaging.DispositionRetry},
+		{name: "concurrent modification", err: &repository.ErrConcurrentModification{Entity: "payment", ID: "p1"}, code: apperr.CodeConcurrentModification, disposition: messaging.DispositionRetry},
+		{name: "uncoded errors are internal and retried", err: errors.New("connection reset"), code: apperr.CodeInternal, disposition: messaging.DispositionRetry},
+		{name: "invalid amount", err: &entity.ErrInvalidAmount{Amount: "x", Reason: "not a decimal number"}, code: apperr.CodeInvalidAmount, disposition: messaging.DispositionDeadLetter},
+		{name: "unsupported currency", err: &entity.ErrUnsupportedCurrency{Currency: "XYZ"}, code: apperr.CodeUnsupportedCurrency, disposition: messaging.DispositionDeadLetter},
+		{name: "declined", err: &processor.ErrDeclined{Operation: "refund", Code: "do_not_honor"}, code: apperr.CodeProcessorDeclined, disposition: messaging.DispositionDeadLetter},
+		{name: "unreconciled charge", err: &ErrUnreconciledCharge{PaymentID: "p1", Err: errors.New("db down"), ReversalErr: errors.New("timeout")}, code: apperr.CodeUnreconciledCharge, disposition: messaging.DispositionDeadLetter},
+		{name: "schema violation", err: &ErrPoisonMessage{Err: &validation.ErrValidation{}}, code: apperr.CodeValidationFailed, disposition: messaging.DispositionDeadLetter},
+		{name: "poison messages are never retried", err: &ErrPoisonMessage{Err: errors.New("unexpected end of JSON input")}, code: apperr.CodeMalformedRequest, disposition: messaging.DispositionDeadLetter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, disposition := messageDisposition(tt.err)
+			if code != tt.code || disposition != tt.disposition {
+				t.Errorf("messageDisposition(%v) = %s, %s, want %s, %s", tt.err, code, disposition, tt.code, tt.disposition)
+			}
+		})
+	}
+}
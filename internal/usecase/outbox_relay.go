@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	outboxLease      = 30 * time.Second
+	outboxMaxBackoff = 5 * time.Minute
+)
+
+// OutboxRelayMetrics describes how far behind the relay is.
+type OutboxRelayMetrics struct {
+	Pending     int
+	LagSeconds  float64 // age of the oldest unsent message
+	SentTotal   int64
+	FailedTotal int64
+	// FailedByReason splits FailedTotal by publishFailureReason
+	FailedByReason map[string]int64
+}
+
+// Reasons a publish can fail, as reported in OutboxRelayMetrics.FailedByReason.
+const (
+	PublishFailureUnroutable     = "unroutable"
+	PublishFailureNacked         = "nacked"
+	PublishFailureConfirmTimeout = "confirm_timeout"
+	PublishFailureError          = "error"
+)
+
+// publishFailureReason classifies the errors returned by messaging.EventPublisher.
+func publishFailureReason(err error) string {
+	var unroutable *messaging.ErrUnroutable
+	switch {
+	case errors.As(err, &unroutable):
+		return PublishFailureUnroutable
+	case errors.Is(err, messaging.ErrNacked):
+		return PublishFailureNacked
+	case errors.Is(err, messaging.ErrConfirmTimeout):
+		return PublishFailureConfirmTimeout
+	}
+	return PublishFailureError
+}
+
+// OutboxRelay publishes the messages written to the outbox table and marks them as sent.
+// Failed publishes are retried with exponential backoff.
+type OutboxRelay struct {
+	Repo      repository.OutboxRepository
+	Broker    messaging.EventPublisher
+	Interval  time.Duration
+	BatchSize int
+
+	owner       string
+	sentTotal   atomic.Int64
+	failedTotal atomic.Int64
+
+	failuresMu sync.Mutex
+	failures   map[string]int64
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, publisher messaging.EventPublisher, interval time.Duration, batchSize int) *OutboxRelay {
+	hostname, _ := os.Hostname()
+	return &OutboxRelay{
+		Repo:      repo,
+		Broker:    publisher,
+		Interval:  interval,
+		BatchSize: batchSize,
+		owner:     hostname + "-" + uuid.NewString()[:8],
+		failures:  make(map[string]int64),
+	}
+}
+
+// Run drains the outbox every Interval until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log.Printf("Outbox relay started (interval %s, batch size %d)", r.Interval, r.BatchSize)
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := r.Dispatch(ctx)
+			if err != nil {
+				log.Printf("Error dispatching outbox messages: %v", err)
+			}
+			// Keep draining while full batches are being published
+			if err != nil || sent < r.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch publishes one batch of pending messages and returns how many were sent.
+func (r *OutboxRelay) Dispatch(ctx context.Context) (int, error) {
+	messages, err := r.Repo.Claim(r.owner, r.BatchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, message := range messages {
+		err := r.Broker.Publish(ctx, outboxBrokerMessage(message))
+		if err != nil {
+			reason := r.recordFailure(err)
+			retryAt := time.Now().Add(r.backoff(message.Attempts))
+			log.Printf("Error publishing outbox message %s (%s, %s), retrying at %s: %v", message.ID, message.RoutingKey, reason, retryAt.Format(time.RFC3339), err)
+			if err := r.Repo.MarkFailed(message.ID, err.Error(), retryAt); err != nil {
+				log.Printf("Error recording outbox failure for message %s: %v", message.ID, err)
+			}
+			continue
+		}
+
+		if err := r.Repo.MarkSent(message.ID, time.Now()); err != nil {
+			// The message will be published again once the lease expires
+			log.Printf("Error marking outbox message %s as sent: %v", message.ID, err)
+			continue
+		}
+		r.sentTotal.Add(1)
+		sent++
+	}
+
+	return sent, nil
+}
+
+// outboxBrokerMessage maps the envelope metadata of the event to message properties,
+// so consumers can route and trace it without decoding the body. Messages written
+// before the envelope existed are published as they are.
+func outboxBrokerMessage(message *entity.OutboxMessage) messaging.Message {
+	msg := messaging.Message{
+		ID:          message.ID,
+		Exchange:    message.Exchange,
+		RoutingKey:  message.RoutingKey,
+		ContentType: "application/json",
+		Body:        message.Payload,
+		Timestamp:   message.CreatedAt,
+	}
+
+	envelope, ok := event.PeekEnvelope(message.Payload)
+	if !ok {
+		return msg
+	}
+	msg.ID = envelope.ID
+	msg.Type = envelope.Type
+	msg.CorrelationID = envelope.CorrelationID
+	msg.AppID = envelope.Producer
+	msg.Timestamp = envelope.OccurredAt
+	msg.Headers = map[string]interface{}{
+		messaging.HeaderEventVersion: int32(envelope.Version),
+	}
+	if envelope.CausationID != "" {
+		msg.Headers[messaging.HeaderCausationID] = envelope.CausationID
+	}
+	return msg
+}
+
+func (r *OutboxRelay) Metrics() (*OutboxRelayMetrics, error) {
+	stats, err := r.Repo.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &OutboxRelayMetrics{
+		Pending:        stats.Pending,
+		SentTotal:      r.sentTotal.Load(),
+		FailedTotal:    r.failedTotal.Load(),
+		FailedByReason: make(map[string]int64),
+	}
+	r.failuresMu.Lock()
+	for reason, count := range r.failures {
+		metrics.FailedByReason[reason] = count
+	}
+	r.failuresMu.Unlock()
+	if stats.OldestPending != nil {
+		metrics.LagSeconds = time.Since(*stats.OldestPending).Seconds()
+	}
+
+	return metrics, nil
+}
+
+func (r *OutboxRelay) recordFailure(err error) string {
+	reason := publishFailureReason(err)
+	r.failedTotal.Add(1)
+	r.failuresMu.Lock()
+	r.failures[reason]++
+	r.failuresMu.Unlock()
+	return reason
+}
+
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.Interval
+	for i := 0; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxBackoff {
+		delay = outboxMaxBackoff
+	}
+	return delay
+}
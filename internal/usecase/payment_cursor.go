@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"gateway-payments/internal/domain/repository"
+	"strings"
+	"time"
+)
+
+// PaymentCursor is the position a listing continues from. It is bound to the filters
+// of the listing that produced it, so it cannot be replayed against other filters.
+type PaymentCursor struct {
+	Key repository.PaymentKey
+	// Descending is the sort order of the listing, by creation time
+	Descending bool
+	// Backward cursors select the page before Key instead of the page after it
+	Backward bool
+	Filter   string
+}
+
+type cursorPayload struct {
+	CreatedAt  time.Time `json:"c"`
+	ID         string    `json:"i"`
+	Descending bool      `json:"d,omitempty"`
+	Backward   bool      `json:"b,omitempty"`
+	Filter     string    `json:"f"`
+}
+
+// PaymentCursors encodes cursors as opaque tokens signed with HMAC-SHA256, so clients
+// cannot forge positions or edit the filters they are bound to.
+type PaymentCursors struct {
+	Secret []byte
+}
+
+func NewPaymentCursors(secret []byte) *PaymentCursors {
+	return &PaymentCursors{Secret: secret}
+}
+
+// Encode returns the token of cursor: the base64url JSON payload and its signature, separated by a dot.
+func (c *PaymentCursors) Encode(cursor PaymentCursor) string {
+	payload, _ := json.Marshal(cursorPayload{
+		CreatedAt:  cursor.Key.CreatedAt,
+		ID:         cursor.Key.ID,
+		Descending: cursor.Descending,
+		Backward:   cursor.Backward,
+		Filter:     cursor.Filter,
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(encoded))
+}
+
+func (c *PaymentCursors) Decode(token string) (PaymentCursor, error) {
+	invalid := &ErrInvalidQuery{Parameter: "cursor", Reason: "malformed or tampered cursor"}
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return PaymentCursor{}, invalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(encoded)) {
+		return PaymentCursor{}, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return PaymentCursor{}, invalid
+	}
+	var decoded cursorPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.ID == "" {
+		return PaymentCursor{}, invalid
+	}
+
+	return PaymentCursor{
+		Key:        repository.PaymentKey{CreatedAt: decoded.CreatedAt, ID: decoded.ID},
+		Descending: decoded.Descending,
+		Backward:   decoded.Backward,
+		Filter:     decoded.Filter,
+	}, nil
+}
+
+func (c *PaymentCursors) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.Secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// filterFingerprint identifies the normalized filter a cursor belongs to.
+func filterFingerprint(filter repository.PaymentFilter) string {
+	var buffer bytes.Buffer
+	json.NewEncoder(&buffer).Encode(filter)
+	sum := sha256.Sum256(buffer.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
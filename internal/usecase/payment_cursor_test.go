@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"errors"
+	"gateway-payments/internal/domain/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaymentCursorsRoundTrip(t *testing.T) {
+	cursors := NewPaymentCursors([]byte("secret"))
+	cursor := PaymentCursor{
+		Key:        repository.PaymentKey{CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC), ID: "payment-1"},
+		Descending: true,
+		Backward:   true,
+		Filter:     "0123456789abcdef",
+	}
+
+	decoded, err := cursors.Decode(cursors.Encode(cursor))
+	if err != nil {
+		t.Fatalf("Decode(Encode(cursor)) = %v", err)
+	}
+	if !decoded.Key.CreatedAt.Equal(cursor.Key.CreatedAt) || decoded.Key.ID != cursor.Key.ID ||
+		decoded.Descending != cursor.Descending || decoded.Backward != cursor.Backward || decoded.Filter != cursor.Filter {
+		t.Errorf("Decode(Encode(%+v)) = %+v", cursor, decoded)
+	}
+}
+
+func TestPaymentCursorsRejectTampering(t *testing.T) {
+	cursors := NewPaymentCursors([]byte("secret"))
+	token := cursors.Encode(PaymentCursor{
+		Key:    repository.PaymentKey{CreatedAt: time.Now().UTC(), ID: "payment-1"},
+		Filter: "0123456789abcdef",
+	})
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// The same payload with another filter, signed with the right secret, is what an
+	// attacker would need to produce
+	forged := func(json string) string {
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(json))
+		return encoded + "." + signature
+	}
+	flipped := []byte(payload)
+	flipped[len(flipped)/2] ^= 1
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "no signature", token: payload},
+		{name: "empty signature", token: payload + "."},
+		{name: "signature not base64", token: payload + ".!!!"},
+		{name: "truncated signature", token: payload + "." + signature[:len(signature)-2]},
+		{name: "edited payload", token: string(flipped) + "." + signature},
+		{name: "edited filter", token: forged(`{"c":"2024-05-01T12:00:00Z","i":"payment-1","f":"other"}`)},
+		{name: "signed with another secret", token: NewPaymentCursors([]byte("other")).Encode(PaymentCursor{Key: repository.PaymentKey{ID: "payment-1"}})},
+		{name: "signed payload that is not JSON", token: signedToken(cursors, "not json")},
+		{name: "signed payload without ID", token: signedToken(cursors, `{"c":"2024-05-01T12:00:00Z","f":""}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cursors.Decode(tt.token)
+			var invalid *ErrInvalidQuery
+			if !errors.As(err, &invalid) || invalid.Parameter != "cursor" {
+				t.Errorf("Decode(%q) = %v, want *ErrInvalidQuery for cursor", tt.token, err)
+			}
+		})
+	}
+}
+
+// signedToken signs an arbitrary payload, as a holder of the secret could.
+func signedToken(cursors *PaymentCursors, payload string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(cursors.sign(encoded))
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/event"
+)
+
+// eventMessage wraps data in an event envelope correlated with ctx and builds its
+// outbox message. The event ID doubles as the outbox and AMQP message ID.
+func eventMessage(ctx context.Context, aggregateID string, eventType string, data interface{}) (*entity.OutboxMessage, error) {
+	envelope, err := event.NewEnvelope(ctx, eventType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := entity.NewOutboxMessage(aggregateID, event.PaymentsExchange, eventType, envelope)
+	if err != nil {
+		return nil, err
+	}
+	message.ID = envelope.ID
+
+	return message, nil
+}
+
+// paymentProcessedMessage builds the outbox message that tells the rest of the system
+// about the payment outcome.
+func paymentProcessedMessage(ctx context.Context, payment *entity.Payment) (*entity.OutboxMessage, error) {
+	return eventMessage(ctx, payment.ID, event.TypePaymentProcessed, event.NewPaymentProcessed(payment))
+}
+
+func paymentRefundedMessage(ctx context.Context, payment *entity.Payment, refund *entity.Refund) (*entity.OutboxMessage, error) {
+	return eventMessage(ctx, payment.ID, event.TypePaymentRefunded, event.NewPaymentRefunded(payment, refund))
+}
+
+func paymentVoidedMessage(ctx context.Context, payment *entity.Payment) (*entity.OutboxMessage, error) {
+	return eventMessage(ctx, payment.ID, event.TypePaymentVoided, event.NewPaymentVoided(payment))
+}
+
+// paymentEventMessages returns the outbox messages announcing the payment's current status.
+func paymentEventMessages(ctx context.Context, payment *entity.Payment) ([]*entity.OutboxMessage, error) {
+	var message *entity.OutboxMessage
+	var err error
+	switch {
+	case payment.IsDecided():
+		message, err = paymentProcessedMessage(ctx, payment)
+	case payment.Status == entity.StatusVoided, payment.Status == entity.StatusExpired:
+		message, err = paymentVoidedMessage(ctx, payment)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error building %s event: %w", payment.Status, err)
+	}
+	return []*entity.OutboxMessage{message}, nil
+}
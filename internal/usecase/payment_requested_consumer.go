@@ -3,54 +3,236 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
 	"gateway-payments/internal/domain/event"
-	"gateway-payments/internal/infrastructure/broker"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/validation"
 	"log"
 	"time"
-
-	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// ErrPoisonMessage wraps failures that retrying cannot fix, such as malformed or invalid requests.
+type ErrPoisonMessage struct {
+	Err error
+}
+
+func (e *ErrPoisonMessage) Error() string {
+	return "poison message: " + e.Err.Error()
+}
+
+func (e *ErrPoisonMessage) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode is the code of the wrapped error when it has one. Poison messages are
+// never retried, so retryable codes are reported as malformed.
+func (e *ErrPoisonMessage) ErrorCode() apperr.Code {
+	if code := apperr.CodeOf(e.Err); !apperr.Lookup(code).Retryable {
+		return code
+	}
+	return apperr.CodeMalformedRequest
+}
+
+// PaymentRequestedConsumerName scopes the deduplication records of PaymentRequestedConsumer.
+const PaymentRequestedConsumerName = "payment.requested"
+
 type PaymentRequestedConsumer struct {
-	Broker        *broker.RabbitMQClient
+	Broker        messaging.EventSubscriber
+	Publisher     messaging.EventPublisher
 	CreatePayment *CreatePayment
+	Retry         messaging.RetryPolicy
+	Deduplication *MessageDeduplication
+	Validator     validation.Validator
 }
 
-func NewPaymentRequestedConsumer(broker *broker.RabbitMQClient, createPayment *CreatePayment) *PaymentRequestedConsumer {
+func NewPaymentRequestedConsumer(subscriber messaging.EventSubscriber, publisher messaging.EventPublisher, createPayment *CreatePayment, retry messaging.RetryPolicy, deduplication *MessageDeduplication, validator validation.Validator) *PaymentRequestedConsumer {
 	return &PaymentRequestedConsumer{
-		Broker:        broker,
+		Broker:        subscriber,
+		Publisher:     publisher,
 		CreatePayment: createPayment,
+		Retry:         retry,
+		Deduplication: deduplication,
+		Validator:     validator,
 	}
 }
 
-func (c *PaymentRequestedConsumer) StartConsuming(queueName, consumerName string) {
-	err := c.Broker.Consume(queueName, consumerName, c.HandleMessage)
+func (c *PaymentRequestedConsumer) StartConsuming(queueName, consumerName string, options messaging.SubscribeOptions) {
+	// Retries and replays keep the message ID, and are only skipped once a delivery succeeded
+	handler := c.Deduplication.Handler(MessageConsumer{
+		Name:    PaymentRequestedConsumerName,
+		Process: c.process,
+		Settle:  c.settle,
+		Timeout: 5 * time.Second,
+	})
+	err := c.Broker.Subscribe(queueName, consumerName, handler, options)
 	if err != nil {
 		log.Fatalf("Failed to start consuming messages: %v", err)
 	}
 	log.Printf("Started consuming messages from queue: %s", queueName)
 }
 
-func (c *PaymentRequestedConsumer) HandleMessage(d amqp.Delivery) {
+// settle acks msg once it was processed, and otherwise handles it according to the
+// disposition of the error.
+func (c *PaymentRequestedConsumer) settle(msg *messaging.Message, err error) {
+	if err == nil {
+		msg.Ack() // Ack, message processed successfully
+		return
+	}
+
+	code, disposition := messageDisposition(err)
+	switch disposition {
+	case messaging.DispositionAck:
+		log.Printf("Message %s not processed (%s), ignoring: %v", msg.ID, code, err)
+		msg.Ack()
+	case messaging.DispositionRequeue:
+		c.delay(msg, err, code)
+	default:
+		c.fail(msg, err, code, disposition)
+	}
+}
+
+func (c *PaymentRequestedConsumer) process(ctx context.Context, msg *messaging.Message) error {
+	log.Printf("Received a message from queue %s (attempt %d): %s", msg.RoutingKey, msg.Attempt(), msg.Body)
+
+	if err := c.validate(msg.Body); err != nil {
+		return &ErrPoisonMessage{Err: err}
+	}
+
+	// Accepts every envelope version up to the current one and legacy bare events
+	paymentRequestedEvent, envelope, err := event.DecodePaymentRequested(msg.Body, msg.ID)
+	if err != nil {
+		return &ErrPoisonMessage{Err: err}
+	}
+
+	// Events produced while creating the payment belong to the same flow as the request
+	metadata := envelope.Metadata()
+	if metadata.CorrelationID == "" {
+		metadata.CorrelationID = msg.CorrelationID
+	}
+	ctx = event.WithMetadata(ctx, metadata)
+
+	_, err = c.CreatePayment.Execute(ctx, CreatePaymentInput{
+		OrderID:  paymentRequestedEvent.OrderID,
+		Amount:   paymentRequestedEvent.Amount.String(),
+		Currency: paymentRequestedEvent.Currency,
+		Method:   paymentRequestedEvent.Method,
+
+		AuthorizeOnly: paymentRequestedEvent.Capture != nil && !*paymentRequestedEvent.Capture,
+		SourceToken:   paymentRequestedEvent.CardToken,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating payment for order %s: %w", paymentRequestedEvent.OrderID, err)
+	}
+
+	log.Printf("Payment for order %s processed and event published", paymentRequestedEvent.OrderID)
+	return nil
+}
+
+// validate checks the envelope, when there is one, and the event data against their schemas.
+func (c *PaymentRequestedConsumer) validate(body []byte) error {
+	data := body
+	if envelope, ok := event.PeekEnvelope(body); ok {
+		if err := c.Validator.Validate(event.EnvelopeSchema, body); err != nil {
+			return err
+		}
+		if envelope.Type != event.TypePaymentRequested {
+			return nil // DecodePaymentRequested reports the unexpected type
+		}
+		data = envelope.Data
+	}
+	return c.Validator.Validate(event.TypePaymentRequested, data)
+}
+
+// fail schedules a retry of msg or, for failures that cannot succeed and once the retries
+// are exhausted, moves it to the dead-letter queue with the failure recorded in its headers.
+func (c *PaymentRequestedConsumer) fail(msg *messaging.Message, cause error, code apperr.Code, disposition messaging.Disposition) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Printf("Received a message from queue %s: %s", d.RoutingKey, d.Body)
+	attempt := msg.Attempt()
+	if disposition == messaging.DispositionRetry {
+		if tier, ok := c.Retry.Next(attempt); ok {
+			log.Printf("Error processing message %s (attempt %d/%d), retrying in %s: %v", msg.ID, attempt, c.Retry.MaxAttempts(), tier.Delay, cause)
+			retry := c.copyMessage(msg, "", tier.Queue)
+			retry.Headers[messaging.HeaderAttempt] = int32(attempt + 1)
+			c.republish(ctx, msg, retry)
+			return
+		}
+	}
+
+	kind := messaging.FailureKindRetriesExceeded
+	if disposition == messaging.DispositionDeadLetter {
+		kind = messaging.FailureKindPoison
+	}
+	log.Printf("Error processing message %s (attempt %d, %s), moving it to the dead-letter queue: %v", msg.ID, attempt, kind, cause)
+
+	deadLetter := c.copyMessage(msg, c.Retry.DeadLetterExchange, c.Retry.DeadLetterRoutingKey)
+	deadLetter.Headers[messaging.HeaderAttempt] = int32(attempt)
+	deadLetter.Headers[messaging.HeaderFailureKind] = kind
+	deadLetter.Headers[messaging.HeaderFailureReason] = cause.Error()
+	deadLetter.Headers[messaging.HeaderErrorCode] = string(code)
+	deadLetter.Headers[messaging.HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	var invalid *validation.ErrValidation
+	if errors.As(cause, &invalid) {
+		violations, _ := json.Marshal(invalid.Violations)
+		deadLetter.Headers[messaging.HeaderValidationErrors] = string(violations)
+	}
+	c.republish(ctx, msg, deadLetter)
+}
+
+// delay sends msg back through the first retry tier without counting an attempt, so a
+// message claimed by another worker is tried again later without holding this worker.
+func (c *PaymentRequestedConsumer) delay(msg *messaging.Message, cause error, code apperr.Code) {
+	if len(c.Retry.Tiers) == 0 {
+		log.Printf("Message %s not processed (%s), requeueing: %v", msg.ID, code, cause)
+		msg.Nack(true)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	var paymentRequestedEvent event.PaymentRequested
-	if err := json.Unmarshal(d.Body, &paymentRequestedEvent); err != nil {
-		log.Printf("Error unmarshaling message: %v", err)
-		d.Nack(false, false) // Nack, don't requeue
+	tier := c.Retry.Tiers[0]
+	log.Printf("Message %s not processed (%s), trying again in %s: %v", msg.ID, code, tier.Delay, cause)
+	if err := c.Publisher.Publish(ctx, c.copyMessage(msg, "", tier.Queue)); err != nil {
+		// Rejecting would dead-letter a message that is still being processed
+		log.Printf("Error delaying message %s: %v", msg.ID, err)
+		msg.Nack(true)
 		return
 	}
+	msg.Ack()
+}
 
-	_, err := c.CreatePayment.Execute(ctx, paymentRequestedEvent)
-	if err != nil {
-		log.Printf("Error creating payment for order %s: %v", paymentRequestedEvent.OrderID, err)
-		d.Nack(false, false) // Nack, don't requeue
+// republish publishes next and acks msg. If next cannot be published, msg is
+// rejected so the queue's dead-letter exchange keeps it.
+func (c *PaymentRequestedConsumer) republish(ctx context.Context, msg *messaging.Message, next messaging.Message) {
+	if err := c.Publisher.Publish(ctx, next); err != nil {
+		log.Printf("Error republishing message %s to %s: %v", msg.ID, next.RoutingKey, err)
+		msg.Nack(false)
 		return
 	}
+	msg.Ack()
+}
 
-	log.Printf("Payment for order %s processed and event published", paymentRequestedEvent.OrderID)
-	d.Ack(false) // Ack, message processed successfully
+// copyMessage keeps the routing of the first delivery in the headers, since retried
+// messages come back through the default exchange, so they can be traced and replayed.
+func (c *PaymentRequestedConsumer) copyMessage(msg *messaging.Message, exchange, routingKey string) messaging.Message {
+	headers := make(map[string]interface{}, len(msg.Headers)+6)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	if _, ok := headers[messaging.HeaderOriginalRoutingKey]; !ok {
+		headers[messaging.HeaderOriginalExchange] = msg.Exchange
+		headers[messaging.HeaderOriginalRoutingKey] = msg.RoutingKey
+	}
+	return messaging.Message{
+		ID:          msg.ID,
+		Exchange:    exchange,
+		RoutingKey:  routingKey,
+		ContentType: msg.ContentType,
+		Headers:     headers,
+		Body:        msg.Body,
+		Timestamp:   msg.Timestamp,
+	}
 }
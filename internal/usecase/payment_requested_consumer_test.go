@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/infrastructure/broker"
+	"gateway-payments/internal/infrastructure/schema"
+	"testing"
+	"time"
+)
+
+// consumerTest runs PaymentRequestedConsumer against the in-memory broker and the fakes.
+type consumerTest struct {
+	store  *fakeStore
+	proc   *fakeProcessor
+	broker *broker.InMemoryBroker
+}
+
+func newConsumerTest(t *testing.T) *consumerTest {
+	t.Helper()
+	test := &consumerTest{store: newFakeStore(), proc: &fakeProcessor{}, broker: broker.NewInMemoryBroker()}
+	if err := test.broker.SetupTopology(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(test.broker.Close)
+
+	schemas, err := schema.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := NewPaymentRequestedConsumer(
+		test.broker,
+		test.broker,
+		newTestCreatePayment(test.store, test.proc),
+		broker.PaymentRequestedRetryPolicy(),
+		NewMessageDeduplicationUseCase(test.store.ProcessedMessages(), time.Minute),
+		schemas,
+	)
+	consumer.StartConsuming(broker.PaymentRequestedQueue, "test", messaging.SubscribeOptions{})
+	return test
+}
+
+// subscribe collects the messages reaching queue.
+func (c *consumerTest) subscribe(t *testing.T, queue string) <-chan *messaging.Message {
+	t.Helper()
+	deliveries := make(chan *messaging.Message, 10)
+	err := c.broker.Subscribe(queue, "test", func(msg *messaging.Message) {
+		msg.Ack()
+		deliveries <- msg
+	}, messaging.SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func (c *consumerTest) publish(t *testing.T, id string, data interface{}) {
+	t.Helper()
+	envelope, err := event.NewEnvelope(context.Background(), event.TypePaymentRequested, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.broker.Publish(context.Background(), messaging.Message{
+		ID:          id,
+		Exchange:    event.PaymentsExchange,
+		RoutingKey:  event.TypePaymentRequested,
+		ContentType: "application/json",
+		Body:        body,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor polls condition until it holds or a second has passed.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func receiveMessage(t *testing.T, deliveries <-chan *messaging.Message) *messaging.Message {
+	t.Helper()
+	select {
+	case msg := <-deliveries:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message delivered")
+		return nil
+	}
+}
+
+func paymentRequested(orderID string, amount string) map[string]interface{} {
+	return map[string]interface{}{"order_id": orderID, "amount": amount, "currency": "BRL", "method": "PIX"}
+}
+
+func TestPaymentRequestedConsumerCreatesPaymentOnce(t *testing.T) {
+	test := newConsumerTest(t)
+
+	test.publish(t, "message-1", paymentRequested("order-1", "100.00"))
+	waitFor(t, "the message to be processed", func() bool {
+		message, ok := test.store.processedMessage(PaymentRequestedConsumerName, "message-1")
+		return ok && message.Processed()
+	})
+
+	// A duplicate publish of the same message is acked without creating anything. The
+	// single worker handles the queue in order, so once message-2 is processed the
+	// duplicate was too
+	test.publish(t, "message-1", paymentRequested("order-1", "100.00"))
+	test.publish(t, "message-2", paymentRequested("order-2", "100.00"))
+	waitFor(t, "the next message to be processed", func() bool {
+		message, ok := test.store.processedMessage(PaymentRequestedConsumerName, "message-2")
+		return ok && message.Processed()
+	})
+
+	attempts := test.store.attempts("order-1")
+	if len(attempts) != 1 || attempts[0].Status != entity.StatusApproved {
+		t.Fatalf("order-1 has %+v, want one APPROVED attempt", attempts)
+	}
+	if authorized, _, _ := test.proc.calls(); len(authorized) != 2 {
+		t.Errorf("processor authorized %d times, want 2, one per order", len(authorized))
+	}
+	messages := test.store.outboxMessages()
+	if len(messages) != 2 {
+		t.Fatalf("outbox has %d messages, want 2", len(messages))
+	}
+	processed, ok := event.PeekEnvelope(messages[0].Payload)
+	if !ok || processed.Type != event.TypePaymentProcessed || processed.CausationID == "" {
+		t.Errorf("outbox payload = %s", messages[0].Payload)
+	}
+}
+
+func TestPaymentRequestedConsumerDeadLettersPoisonMessages(t *testing.T) {
+	test := newConsumerTest(t)
+	deadLetters := test.subscribe(t, "payments.dlq")
+
+	test.publish(t, "message-1", paymentRequested("order-1", "abc"))
+
+	msg := receiveMessage(t, deadLetters)
+	if msg.ID != "message-1" {
+		t.Errorf("dead-lettered %s, want message-1", msg.ID)
+	}
+	if kind := msg.Header(messaging.HeaderFailureKind); kind != messaging.FailureKindPoison {
+		t.Errorf("failure kind = %q, want %q", kind, messaging.FailureKindPoison)
+	}
+	if code := msg.Header(messaging.HeaderErrorCode); code != "validation_failed" {
+		t.Errorf("error code = %q, want validation_failed", code)
+	}
+	if msg.Header(messaging.HeaderValidationErrors) == "" {
+		t.Error("the schema violations are missing")
+	}
+	if msg.Header(messaging.HeaderOriginalRoutingKey) != event.TypePaymentRequested {
+		t.Errorf("original routing key = %q", msg.Header(messaging.HeaderOriginalRoutingKey))
+	}
+	if attempts := test.store.attempts("order-1"); len(attempts) != 0 {
+		t.Errorf("order-1 has %d attempts, want none", len(attempts))
+	}
+}
+
+func TestPaymentRequestedConsumerRetriesTransientFailures(t *testing.T) {
+	test := newConsumerTest(t)
+	test.proc.authorizeErr = processor.ErrUnavailable
+	retries := test.subscribe(t, broker.PaymentRequestedRetryPolicy().Tiers[0].Queue)
+
+	test.publish(t, "message-1", paymentRequested("order-1", "100.00"))
+
+	msg := receiveMessage(t, retries)
+	if msg.ID != "message-1" || msg.Attempt() != 2 {
+		t.Errorf("retried %s as attempt %d, want message-1 as attempt 2", msg.ID, msg.Attempt())
+	}
+	if _, ok := test.store.processedMessage(PaymentRequestedConsumerName, "message-1"); ok {
+		t.Error("the failed message is still claimed, so its retry would be skipped")
+	}
+}
+
+func TestPaymentRequestedConsumerDelaysMessagesClaimedByAnotherWorker(t *testing.T) {
+	test := newConsumerTest(t)
+	retries := test.subscribe(t, broker.PaymentRequestedRetryPolicy().Tiers[0].Queue)
+	if err := test.store.ProcessedMessages().Claim(entity.NewProcessedMessage(PaymentRequestedConsumerName, "message-1", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	test.publish(t, "message-1", paymentRequested("order-1", "100.00"))
+
+	msg := receiveMessage(t, retries)
+	if msg.ID != "message-1" || msg.Attempt() != 1 {
+		t.Errorf("delayed %s as attempt %d, want message-1 as attempt 1", msg.ID, msg.Attempt())
+	}
+	if attempts := test.store.attempts("order-1"); len(attempts) != 0 {
+		t.Errorf("order-1 has %d attempts, want none", len(attempts))
+	}
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// paymentProcessor returns the processor that authorized payment. Payments approved
+// manually have none, and follow-up operations on them only change the local record.
+func paymentProcessor(processors *processor.Registry, payment *entity.Payment) (processor.Processor, bool, error) {
+	if payment.Processor == "" {
+		return nil, false, nil
+	}
+	proc, ok := processors.Get(payment.Processor)
+	if !ok {
+		return nil, false, fmt.Errorf("payment %s was authorized by processor %q, which is not configured", payment.ID, payment.Processor)
+	}
+	return proc, true, nil
+}
+
+// ErrUnreconciledCharge is returned when money moved at the processor, the payment
+// could not be stored (Err) and reversing the operation failed too (ReversalErr).
+type ErrUnreconciledCharge struct {
+	PaymentID   string
+	Processor   string
+	Reference   string
+	Err         error
+	ReversalErr error
+}
+
+func (e *ErrUnreconciledCharge) ErrorCode() apperr.Code {
+	return apperr.CodeUnreconciledCharge
+}
+
+func (e *ErrUnreconciledCharge) Error() string {
+	return fmt.Sprintf("payment %s (reference %s at %s) was not stored: %v; reversing it at the processor failed: %v", e.PaymentID, e.Reference, e.Processor, e.Err, e.ReversalErr)
+}
+
+func (e *ErrUnreconciledCharge) Unwrap() error {
+	return e.Err
+}
+
+// reverseCharge undoes at the processor what was done there for payment, whose new
+// state could not be stored because of cause: an open authorization is voided and
+// captured money is refunded. It returns cause, or an ErrUnreconciledCharge if the
+// processor refused the reversal.
+func reverseCharge(ctx context.Context, proc processor.Processor, payment *entity.Payment, cause error) error {
+	var operation string
+	var result *processor.Result
+	var err error
+	switch payment.Status {
+	case entity.StatusAuthorized:
+		operation = "void"
+		result, err = proc.Void(ctx, processor.VoidRequest{
+			PaymentID: payment.ID,
+			Reference: payment.ProcessorReference,
+		})
+	case entity.StatusApproved, entity.StatusCaptured:
+		operation = "refund"
+		result, err = proc.Refund(ctx, processor.RefundRequest{
+			PaymentID: payment.ID,
+			RefundID:  uuid.NewString(),
+			Reference: payment.ProcessorReference,
+			Amount:    payment.Captured,
+		})
+	case entity.StatusVoided, entity.StatusExpired:
+		operation = "void"
+		err = errors.New("a released authorization cannot be placed again")
+	default:
+		return cause
+	}
+	if err == nil && !result.Approved {
+		err = processor.Declined(operation, result)
+	}
+
+	if err != nil {
+		unreconciled := &ErrUnreconciledCharge{
+			PaymentID:   payment.ID,
+			Processor:   payment.Processor,
+			Reference:   payment.ProcessorReference,
+			Err:         cause,
+			ReversalErr: err,
+		}
+		log.Printf("Charge needs manual reconciliation: %v", unreconciled)
+		return unreconciled
+	}
+	log.Printf("Payment %s could not be stored and was reversed (%s) at %s, reference %s: %v", payment.ID, operation, payment.Processor, payment.ProcessorReference, cause)
+	return cause
+}
+
+// maxProcessedSaves bounds how many times saveProcessed reloads a payment that keeps changing.
+const maxProcessedSaves = 3
+
+// saveProcessed stores payment after the processor carried out the operation that apply
+// made to it. When the payment changed concurrently, apply runs again on a fresh copy so
+// that unrelated changes, such as a legal hold, do not undo the operation. If it no
+// longer applies, or the payment cannot be stored, the operation is reversed at the processor.
+func saveProcessed(ctx context.Context, repo repository.PaymentRepository, proc processor.Processor, payment *entity.Payment, apply func(*entity.Payment) error) (*entity.Payment, error) {
+	for saves := 1; ; saves++ {
+		messages, err := paymentEventMessages(ctx, payment)
+		if err != nil {
+			return nil, reverseCharge(ctx, proc, payment, err)
+		}
+		err = repo.Update(payment, messages...)
+		if err == nil {
+			return payment, nil
+		}
+
+		var conflict *repository.ErrConcurrentModification
+		if !errors.As(err, &conflict) || saves == maxProcessedSaves {
+			return nil, reverseCharge(ctx, proc, payment, err)
+		}
+		current, findErr := repo.FindByID(payment.ID)
+		if findErr != nil {
+			return nil, reverseCharge(ctx, proc, payment, err)
+		}
+		if payment.Status == entity.StatusVoided && (current.Status == entity.StatusVoided || current.Status == entity.StatusExpired) {
+			// The hold was released twice; the payment already says so
+			return current, nil
+		}
+		if applyErr := apply(current); applyErr != nil {
+			return nil, reverseCharge(ctx, proc, payment, fmt.Errorf("%w; payment is now %s", err, current.Status))
+		}
+		payment = current
+	}
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// PurgeDeletedPayments permanently deletes payments soft-deleted longer than Retention
+// ago. Payments under legal hold are kept.
+type PurgeDeletedPayments struct {
+	Repo      repository.PaymentRepository
+	Retention time.Duration
+	BatchSize int
+}
+
+func NewPurgeDeletedPaymentsUseCase(repo repository.PaymentRepository, retention time.Duration, batchSize int) *PurgeDeletedPayments {
+	return &PurgeDeletedPayments{
+		Repo:      repo,
+		Retention: retention,
+		BatchSize: batchSize,
+	}
+}
+
+// Run purges eligible payments every interval until ctx is cancelled.
+func (p *PurgeDeletedPayments) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Deleted payments purge started (retention %s, interval %s)", p.Retention, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Execute(ctx)
+		if err != nil {
+			log.Printf("Error purging deleted payments: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted payments", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Deleted payments purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute purges eligible payments in batches and returns how many were purged.
+func (p *PurgeDeletedPayments) Execute(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-p.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := p.Repo.PurgeDeletedBefore(before, p.BatchSize)
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < int64(p.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
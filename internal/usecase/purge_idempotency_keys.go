@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// PurgeIdempotencyKeys deletes idempotency keys older than TTL. A request retried with
+// a purged key is handled as a new one.
+type PurgeIdempotencyKeys struct {
+	Repo      repository.IdempotencyRepository
+	TTL       time.Duration
+	BatchSize int
+}
+
+func NewPurgeIdempotencyKeysUseCase(repo repository.IdempotencyRepository, ttl time.Duration, batchSize int) *PurgeIdempotencyKeys {
+	return &PurgeIdempotencyKeys{
+		Repo:      repo,
+		TTL:       ttl,
+		BatchSize: batchSize,
+	}
+}
+
+// Run purges expired keys every interval until ctx is cancelled.
+func (p *PurgeIdempotencyKeys) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Idempotency keys cleanup started (TTL %s, interval %s)", p.TTL, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.Execute(ctx)
+		if err != nil {
+			log.Printf("Error purging idempotency keys: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Purged %d idempotency keys", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Idempotency keys cleanup stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute deletes expired keys in batches and returns how many were deleted.
+func (p *PurgeIdempotencyKeys) Execute(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-p.TTL)
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := p.Repo.DeleteCreatedBefore(before, p.BatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(p.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
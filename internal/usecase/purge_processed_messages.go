@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// PurgeProcessedMessages deletes deduplication records older than TTL. A message
+// redelivered after that is processed again, so TTL must exceed the longest retry delay.
+type PurgeProcessedMessages struct {
+	Repo      repository.ProcessedMessageRepository
+	TTL       time.Duration
+	BatchSize int
+}
+
+func NewPurgeProcessedMessagesUseCase(repo repository.ProcessedMessageRepository, ttl time.Duration, batchSize int) *PurgeProcessedMessages {
+	return &PurgeProcessedMessages{
+		Repo:      repo,
+		TTL:       ttl,
+		BatchSize: batchSize,
+	}
+}
+
+// Run purges expired records every interval until ctx is cancelled.
+func (p *PurgeProcessedMessages) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Processed messages cleanup started (TTL %s, interval %s)", p.TTL, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.Execute(ctx)
+		if err != nil {
+			log.Printf("Error purging processed messages: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Purged %d processed messages", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Processed messages cleanup stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute deletes expired records in batches and returns how many were deleted.
+func (p *PurgeProcessedMessages) Execute(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-p.TTL)
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := p.Repo.DeleteCreatedBefore(before, p.BatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(p.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
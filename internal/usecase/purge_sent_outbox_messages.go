@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// PurgeSentOutboxMessages deletes outbox messages published longer than Retention ago.
+// Unsent messages are kept however old they are.
+type PurgeSentOutboxMessages struct {
+	Repo      repository.OutboxRepository
+	Retention time.Duration
+	BatchSize int
+}
+
+func NewPurgeSentOutboxMessagesUseCase(repo repository.OutboxRepository, retention time.Duration, batchSize int) *PurgeSentOutboxMessages {
+	return &PurgeSentOutboxMessages{
+		Repo:      repo,
+		Retention: retention,
+		BatchSize: batchSize,
+	}
+}
+
+// Run purges sent messages every interval until ctx is cancelled.
+func (p *PurgeSentOutboxMessages) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Outbox cleanup started (retention %s, interval %s)", p.Retention, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.Execute(ctx)
+		if err != nil {
+			log.Printf("Error purging sent outbox messages: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Purged %d sent outbox messages", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox cleanup stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute deletes sent messages in batches and returns how many were deleted.
+func (p *PurgeSentOutboxMessages) Execute(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-p.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := p.Repo.DeleteSentBefore(before, p.BatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(p.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
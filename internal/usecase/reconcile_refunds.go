@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"time"
+)
+
+// ReconcileRefunds settles refunds left PENDING for longer than After, because the
+// processor did not answer or the answer could not be stored, by asking it again.
+type ReconcileRefunds struct {
+	Repo         repository.RefundRepository
+	CreateRefund *CreateRefund
+	After        time.Duration
+	BatchSize    int
+}
+
+func NewReconcileRefundsUseCase(repo repository.RefundRepository, createRefund *CreateRefund, after time.Duration, batchSize int) *ReconcileRefunds {
+	return &ReconcileRefunds{
+		Repo:         repo,
+		CreateRefund: createRefund,
+		After:        after,
+		BatchSize:    batchSize,
+	}
+}
+
+// Run reconciles pending refunds every interval until ctx is cancelled.
+func (rr *ReconcileRefunds) Run(ctx context.Context, interval time.Duration) {
+	log.Printf("Refund reconciliation started (after %s, interval %s)", rr.After, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reconciled, err := rr.Execute(ctx)
+		if err != nil {
+			log.Printf("Error reconciling refunds: %v", err)
+		} else if reconciled > 0 {
+			log.Printf("Reconciled %d refunds", reconciled)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Refund reconciliation stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute reconciles one batch of pending refunds and returns how many got a final status.
+func (rr *ReconcileRefunds) Execute(ctx context.Context) (int, error) {
+	now := time.Now()
+	refunds, err := rr.Repo.FindPendingBefore(now.Add(-rr.After), now, rr.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	reconciled := 0
+	for _, refund := range refunds {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := rr.CreateRefund.Reconcile(ctx, refund); err != nil {
+			rr.postpone(refund, now, err)
+			continue
+		}
+		reconciled++
+	}
+
+	return reconciled, nil
+}
+
+// postpone records a failed reconciliation of refund, so the next attempt waits and
+// the batch moves on to other refunds.
+func (rr *ReconcileRefunds) postpone(refund *entity.Refund, now time.Time, cause error) {
+	delay := retryBackoff(refund.ReconcileAttempts + 1)
+	refund.ReconcileFailed(now.Add(delay))
+	log.Printf("Error reconciling refund %s of payment %s (attempt %d), trying again in %s: %v", refund.ID, refund.PaymentID, refund.ReconcileAttempts, delay, cause)
+	if err := rr.Repo.Update(refund); err != nil {
+		log.Printf("Error postponing the reconciliation of refund %s: %v", refund.ID, err)
+	}
+}
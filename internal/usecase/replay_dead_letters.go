@@ -0,0 +1,198 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/apperr"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/messaging"
+	"gateway-payments/internal/domain/repository"
+	"log"
+	"strings"
+	"time"
+)
+
+// Audit actions recorded for the dead-letter queue.
+const (
+	AuditActionDeadLetterReplay = "dlq.replay"
+	AuditActionDeadLetterPurge  = "dlq.purge"
+	AuditTargetDeadLetter       = "dead_letter"
+)
+
+// deadLetterPageSize is how many messages are read per query when acting on all of them.
+const deadLetterPageSize = 100
+
+// ErrNoDeadLettersSelected is returned when neither IDs nor All were given.
+var ErrNoDeadLettersSelected = apperr.New(apperr.CodeInvalidParameter, "select the dead letters by ID or set all")
+
+// DeadLetterActionInput selects the dead letters to act on: the given IDs, or every
+// message (with All) in the status the action applies to.
+type DeadLetterActionInput struct {
+	IDs   []string
+	All   bool
+	Actor string
+}
+
+type DeadLetterActionFailure struct {
+	ID    string
+	Error string
+}
+
+type DeadLetterActionOutput struct {
+	Succeeded []string
+	Failed    []DeadLetterActionFailure
+}
+
+func (o *DeadLetterActionOutput) fail(id string, err error) {
+	o.Failed = append(o.Failed, DeadLetterActionFailure{ID: id, Error: err.Error()})
+}
+
+type ReplayDeadLetters struct {
+	Repo      repository.DeadLetterRepository
+	AuditRepo repository.AuditRepository
+	Publisher messaging.EventPublisher
+}
+
+func NewReplayDeadLettersUseCase(repo repository.DeadLetterRepository, auditRepo repository.AuditRepository, publisher messaging.EventPublisher) *ReplayDeadLetters {
+	return &ReplayDeadLetters{
+		Repo:      repo,
+		AuditRepo: auditRepo,
+		Publisher: publisher,
+	}
+}
+
+// Execute publishes the selected messages again to their original exchange and routing
+// key, with the failure headers removed so they get a fresh set of attempts. With All,
+// only messages that were not replayed yet are selected.
+func (rd *ReplayDeadLetters) Execute(ctx context.Context, input DeadLetterActionInput) (*DeadLetterActionOutput, error) {
+	ids, err := selectDeadLetters(rd.Repo, input, entity.DeadLetterStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &DeadLetterActionOutput{Succeeded: make([]string, 0), Failed: make([]DeadLetterActionFailure, 0)}
+	for _, id := range ids {
+		if err := rd.replay(ctx, id, input.Actor); err != nil {
+			log.Printf("Error replaying dead letter %s: %v", id, err)
+			output.fail(id, err)
+			continue
+		}
+		output.Succeeded = append(output.Succeeded, id)
+	}
+
+	return output, nil
+}
+
+func (rd *ReplayDeadLetters) replay(ctx context.Context, id, actor string) error {
+	deadLetter, err := rd.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]interface{}, len(deadLetter.Headers)+1)
+	for key, value := range deadLetter.Headers {
+		if messaging.IsFailureHeader(key) {
+			continue
+		}
+		headers[key] = value
+	}
+	headers[messaging.HeaderReplayedFrom] = deadLetter.ID
+
+	err = rd.Publisher.Publish(ctx, messaging.Message{
+		ID:          deadLetter.MessageID,
+		Exchange:    deadLetter.Exchange,
+		RoutingKey:  deadLetter.RoutingKey,
+		ContentType: deadLetter.ContentType,
+		Headers:     headers,
+		Body:        deadLetter.Body,
+		Timestamp:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing to %s: %w", deadLetter.RoutingKey, err)
+	}
+
+	deadLetter.MarkReplayed(time.Now())
+	if err := rd.Repo.Update(deadLetter); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("replayed to exchange %q with routing key %q (replay %d)", deadLetter.Exchange, deadLetter.RoutingKey, deadLetter.ReplayCount)
+	return rd.AuditRepo.Create(entity.NewAuditEntry(actor, AuditActionDeadLetterReplay, AuditTargetDeadLetter, deadLetter.ID, details))
+}
+
+type PurgeDeadLetters struct {
+	Repo      repository.DeadLetterRepository
+	AuditRepo repository.AuditRepository
+}
+
+func NewPurgeDeadLettersUseCase(repo repository.DeadLetterRepository, auditRepo repository.AuditRepository) *PurgeDeadLetters {
+	return &PurgeDeadLetters{
+		Repo:      repo,
+		AuditRepo: auditRepo,
+	}
+}
+
+// Execute deletes the selected messages. With All, every stored message is deleted.
+func (pd *PurgeDeadLetters) Execute(input DeadLetterActionInput) (*DeadLetterActionOutput, error) {
+	ids, err := selectDeadLetters(pd.Repo, input, "")
+	if err != nil {
+		return nil, err
+	}
+
+	output := &DeadLetterActionOutput{Succeeded: make([]string, 0), Failed: make([]DeadLetterActionFailure, 0)}
+	for _, id := range ids {
+		if err := pd.purge(id, input.Actor); err != nil {
+			log.Printf("Error purging dead letter %s: %v", id, err)
+			output.fail(id, err)
+			continue
+		}
+		output.Succeeded = append(output.Succeeded, id)
+	}
+
+	return output, nil
+}
+
+func (pd *PurgeDeadLetters) purge(id, actor string) error {
+	deadLetter, err := pd.Repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := pd.Repo.Delete(id); err != nil {
+		return err
+	}
+
+	// The audit entry keeps enough to know what was thrown away
+	details := fmt.Sprintf("purged %s message %q from routing key %q (%s: %s)", deadLetter.Status, deadLetter.MessageID, deadLetter.RoutingKey, deadLetter.FailureKind, deadLetter.FailureReason)
+	return pd.AuditRepo.Create(entity.NewAuditEntry(actor, AuditActionDeadLetterPurge, AuditTargetDeadLetter, id, details))
+}
+
+// selectDeadLetters resolves the input to a list of IDs, reading every page up front
+// so that acting on the messages does not shift the pages still to be read.
+func selectDeadLetters(repo repository.DeadLetterRepository, input DeadLetterActionInput, status string) ([]string, error) {
+	if !input.All {
+		ids := make([]string, 0, len(input.IDs))
+		for _, id := range input.IDs {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil, ErrNoDeadLettersSelected
+		}
+		return ids, nil
+	}
+
+	ids := make([]string, 0)
+	for page := 1; ; page++ {
+		deadLetters, err := repo.FindAll(status, page, deadLetterPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, deadLetter := range deadLetters {
+			ids = append(ids, deadLetter.ID)
+		}
+		if len(deadLetters) < deadLetterPageSize {
+			return ids, nil
+		}
+	}
+}
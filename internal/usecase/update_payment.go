@@ -2,61 +2,69 @@ package usecase
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"gateway-payments/internal/domain/event"
+	"gateway-payments/internal/domain/entity"
 	"gateway-payments/internal/domain/repository"
-	"gateway-payments/internal/infrastructure/broker"
-	"time"
+	"strings"
 )
 
+// processorTransitions are the statuses a processor-backed payment only reaches through
+// its processor, so a PUT cannot mark them without authorizing, capturing or voiding there.
+var processorTransitions = map[string]string{
+	entity.StatusAuthorized: "authorizations come from the processor",
+	entity.StatusCaptured:   "use POST /payments/{id}/capture",
+	entity.StatusVoided:     "use POST /payments/{id}/void",
+}
+
 type UpdatePaymentInput struct {
 	ID     string
 	Status string
+	Reason string
 }
 
 type UpdatePayment struct {
-	Repo   repository.PaymentRepository
-	Broker *broker.RabbitMQClient
+	Repo repository.PaymentRepository
 }
 
-func NewUpdatePaymentUseCase(repo repository.PaymentRepository, broker *broker.RabbitMQClient) *UpdatePayment {
+func NewUpdatePaymentUseCase(repo repository.PaymentRepository) *UpdatePayment {
 	return &UpdatePayment{
-		Repo:   repo,
-		Broker: broker,
+		Repo: repo,
 	}
 }
 
 func (up *UpdatePayment) Execute(ctx context.Context, input UpdatePaymentInput) error {
 	payment, err := up.Repo.FindByID(input.ID)
 	if err != nil {
-		return errors.New("payment not found")
+		return err
 	}
 
-	// Atualiza o status
-	payment.Status = input.Status
+	status := strings.ToUpper(input.Status)
+	if reason, ok := processorTransitions[status]; ok && payment.Processor != "" {
+		return &entity.ErrInvalidTransition{PaymentID: payment.ID, From: payment.Status, To: status, Reason: reason}
+	}
 
-	err = up.Repo.Save(payment)
+	// Atualiza o status respeitando as transições permitidas
+	err = payment.TransitionTo(input.Status, input.Reason)
 	if err != nil {
 		return err
 	}
 
 	// --- O PULO DO GATO ---
-	// Se o status for alterado para algo final (APPROVED ou REJECTED), avisamos o resto do sistema
-	if payment.Status == "APPROVED" || payment.Status == "REJECTED" {
-		paymentProcessedEvent := event.PaymentProcessed{
-			Event:       "payment.processed",
-			OrderID:     payment.OrderID,
-			Status:      payment.Status,
-			ProcessedAt: time.Now(),
-		}
-
-		// Publica na fila para que o ecommerce-api receba e atualize o pedido
-		err = up.Broker.Publish(ctx, "payments.exchange", "payment.processed", paymentProcessedEvent)
-		if err != nil {
-			return fmt.Errorf("error publishing payment.processed event: %w", err)
-		}
-		fmt.Printf("Status do pagamento %s atualizado e enviado para a fila: %s\n", payment.ID, payment.Status)
+	// Se o status for alterado para algo final (APPROVED, CAPTURED, REJECTED ou VOIDED), avisamos o resto do sistema.
+	// O evento vai para a outbox na mesma transação, para que o ecommerce-api receba e atualize o pedido.
+	messages, err := paymentEventMessages(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	// Falha com ErrConcurrentModification se o consumer ou outra requisição alterou o pagamento
+	err = up.Repo.Update(payment, messages...)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) > 0 {
+		fmt.Printf("Status do pagamento %s atualizado e enviado para a outbox: %s\n", payment.ID, payment.Status)
 	}
 
 	return nil
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gateway-payments/internal/domain/entity"
+	"testing"
+)
+
+func TestUpdatePaymentLeavesProcessorStatusesToTheProcessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor string
+		from      string
+		to        string
+		wantErr   bool
+	}{
+		{name: "capture at the processor", processor: "fake", from: entity.StatusAuthorized, to: "captured", wantErr: true},
+		{name: "void at the processor", processor: "fake", from: entity.StatusAuthorized, to: entity.StatusVoided, wantErr: true},
+		{name: "authorize at the processor", processor: "fake", from: entity.StatusPending, to: entity.StatusAuthorized, wantErr: true},
+		{name: "stuck processor payment is decided by hand", processor: "fake", from: entity.StatusPending, to: entity.StatusRejected},
+		{name: "manual capture", from: entity.StatusAuthorized, to: entity.StatusCaptured},
+		{name: "manual void", from: entity.StatusAuthorized, to: entity.StatusVoided},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			payment := entity.NewPayment("payment-1", "order-1", entity.Money{Amount: 10000, Currency: "BRL"}, "PIX")
+			payment.Status = tt.from
+			payment.Processor = tt.processor
+			store.seed(payment)
+
+			err := NewUpdatePaymentUseCase(store.Payments()).Execute(context.Background(), UpdatePaymentInput{ID: "payment-1", Status: tt.to})
+
+			stored := store.attempts("order-1")[0]
+			if tt.wantErr {
+				var invalid *entity.ErrInvalidTransition
+				if !errors.As(err, &invalid) {
+					t.Fatalf("Execute() = %v, want *entity.ErrInvalidTransition", err)
+				}
+				if stored.Status != tt.from || len(store.outboxMessages()) != 0 {
+					t.Errorf("payment moved to %s with %d events", stored.Status, len(store.outboxMessages()))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() = %v", err)
+			}
+			if stored.Status == tt.from {
+				t.Errorf("payment stayed %s", stored.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"gateway-payments/internal/domain/entity"
+	"gateway-payments/internal/domain/processor"
+	"gateway-payments/internal/domain/repository"
+)
+
+type VoidPaymentInput struct {
+	ID     string
+	Reason string
+}
+
+type VoidPayment struct {
+	Repo       repository.PaymentRepository
+	Processors *processor.Registry
+}
+
+func NewVoidPaymentUseCase(repo repository.PaymentRepository, processors *processor.Registry) *VoidPayment {
+	return &VoidPayment{
+		Repo:       repo,
+		Processors: processors,
+	}
+}
+
+func (vp *VoidPayment) Execute(ctx context.Context, input VoidPaymentInput) (*entity.Payment, error) {
+	payment, err := vp.Repo.FindByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := voidPayment(ctx, vp.Processors, payment, input.Reason); err != nil {
+		return nil, err
+	}
+
+	proc, ok, err := paymentProcessor(vp.Processors, payment)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// The hold is already released at the processor, so the void is stored even if the payment changed meanwhile
+		return saveProcessed(ctx, vp.Repo, proc, payment, func(current *entity.Payment) error {
+			return current.Void(input.Reason)
+		})
+	}
+
+	messages, err := paymentEventMessages(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := vp.Repo.Update(payment, messages...); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// voidPayment releases the authorization at the processor and voids the payment.
+func voidPayment(ctx context.Context, processors *processor.Registry, payment *entity.Payment, reason string) error {
+	if err := payment.Void(reason); err != nil {
+		return err
+	}
+	return releaseAuthorization(ctx, processors, payment)
+}
+
+// releaseAuthorization asks the processor to release the hold of a voided or expired payment.
+func releaseAuthorization(ctx context.Context, processors *processor.Registry, payment *entity.Payment) error {
+	proc, ok, err := paymentProcessor(processors, payment)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	result, err := proc.Void(ctx, processor.VoidRequest{
+		PaymentID: payment.ID,
+		Reference: payment.ProcessorReference,
+	})
+	if err != nil {
+		return fmt.Errorf("error voiding payment %s: %w", payment.ID, err)
+	}
+	if !result.Approved && result.DeclineCode != processor.DeclineAlreadyReleased {
+		return processor.Declined("void", result)
+	}
+	return nil
+}